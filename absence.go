@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//absences are days off such as vacation or sick leave, recorded in .horolog/absences
+//in the same format as the calendar. They are not logs, so they never count towards
+//summaries, but overtime does not expect any work on them.
+func absencesPath(dir string) string {
+	return filepath.Join(treeRoot(dir), stateDirName, "absences")
+}
+
+func absenceCommand(args []string) {
+	_, positional := parseOptions(args)
+	if len(positional) < 2 {
+		daysOff := map[string]string{}
+		dir := "."
+		if len(positional) == 1 {
+			dir = positional[0]
+		}
+		if err := loadCalendar(absencesPath(dir), daysOff); err != nil {
+			panic(err)
+		}
+		var days []string
+		for day := range daysOff {
+			days = append(days, day)
+		}
+		sort.Strings(days)
+		for _, day := range days {
+			fmt.Println(day, daysOff[day])
+		}
+		return
+	}
+
+	kind, period := positional[0], positional[1]
+	dir := "."
+	if len(positional) > 2 {
+		dir = positional[2]
+	}
+	if strings.ContainsAny(kind, "#\n") {
+		panic(errors.New("Invalid Absence: " + kind))
+	}
+	from, to, err := parsePeriod(period)
+	if err != nil {
+		panic(err)
+	}
+	if from == never || to == never {
+		panic(errors.New("Absences need a start and an end, e.g. 2024-07-01..2024-07-12"))
+	}
+	last := to.AddDate(0, 0, -1)
+	entry := from.Format("2006-01-02")
+	if last.After(from) {
+		entry += ".." + last.Format("2006-01-02")
+	}
+
+	defer lockTree(dir)()
+	f, err := os.OpenFile(absencesPath(dir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(entry + " " + kind + "\n"); err != nil {
+		panic(err)
+	}
+	audit(dir, "absence", entry, kind)
+	inform("Recorded", kind, "on", entry)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//the accounting formats map each log's task to a service item (the service_item setting,
+//or else the task's path) and its customer (the client setting of the task or an
+//ancestor), so that time can be billed in bookkeeping software without re-entering it
+
+//iifField removes the tabs and newlines which would break a field of an IIF file
+func iifField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+//exportIIF writes time activities in QuickBooks Desktop's IIF format (File > Utilities >
+//Import > IIF Files), with the duration as hours:minutes
+func exportIIF(w io.Writer, rows []exportRow) error {
+	_, err := fmt.Fprint(w, "!TIMERHDR\tVER\tREL\tCOMPANYNAME\tIMPORTEDBEFORE\tFROMTIMER\tCOMPANYCREATETIME\n"+
+		"TIMERHDR\t8\t0\t\tN\tY\t0\n"+
+		"!TIMEACT\tDATE\tJOB\tEMP\tITEM\tPITEM\tDURATION\tPROJ\tNOTE\tXFERTOPAYROLL\tBILLINGSTATUS\n")
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		minutes := (r.Seconds + 30) / 60
+		status := "0"
+		if r.settings.billable {
+			status = "1"
+		}
+		_, err = fmt.Fprintf(w, "TIMEACT\t%s\t%s\t%s\t%s\t\t%d:%02d\t\t%s\tN\t%s\n",
+			r.Start.Format("01/02/2006"), iifField(r.settings.client), iifField(r.Author), iifField(r.settings.service),
+			minutes/60, minutes%60, iifField(strings.SplitN(strings.TrimSpace(r.Note), "\n", 2)[0]), status)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//exportQuickBooksCSV writes time activities with the columns QuickBooks Online's time
+//import expects, with decimal hours
+func exportQuickBooksCSV(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Date", "Customer", "Employee", "Service Item", "Hours", "Rate", "Description", "Billable"})
+	for _, r := range rows {
+		billable := "No"
+		if r.settings.billable {
+			billable = "Yes"
+		}
+		cw.Write([]string{
+			r.Start.Format("01/02/2006"),
+			r.settings.client,
+			r.Author,
+			r.settings.service,
+			strconv.FormatFloat(time.Duration(r.Seconds*int64(time.Second)).Hours(), 'f', 2, 64),
+			strconv.FormatFloat(r.settings.rate, 'f', 2, 64),
+			strings.TrimSpace(r.Note),
+			billable,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//germanDecimal writes a number with a decimal comma, as lexoffice expects
+func germanDecimal(f float64) string {
+	return strings.Replace(strconv.FormatFloat(f, 'f', 2, 64), ".", ",", 1)
+}
+
+//exportLexoffice writes invoice items for lexoffice's CSV import, separated by
+//semicolons with German dates and decimal commas, with the hours as the quantity
+func exportLexoffice(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.Write([]string{"Datum", "Kunde", "Leistung", "Beschreibung", "Menge", "Einheit", "Einzelpreis", "Währung"})
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Start.Format("02.01.2006"),
+			r.settings.client,
+			r.settings.service,
+			strings.TrimSpace(r.Note),
+			germanDecimal(time.Duration(r.Seconds * int64(time.Second)).Hours()),
+			"Stunden",
+			germanDecimal(r.settings.rate),
+			r.settings.currency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//timeclockAccount turns a task's path into an account name, with colons between levels,
+//and without the double spaces which would end it early
+func timeclockAccount(task string) string {
+	if task == "." {
+		return "time"
+	}
+	return strings.Join(strings.Fields(strings.Replace(task, "/", ":", -1)), " ")
+}
+
+//exportTimeclock writes clock-in and clock-out lines in the timeclock format read by
+//hledger and ledger-cli, with each task as an account and the first line of the note as
+//the description, in local time as the format has no time zones
+func exportTimeclock(w io.Writer, rows []exportRow) error {
+	for _, r := range rows {
+		in := "i " + r.Start.Local().Format("2006/01/02 15:04:05") + " " + timeclockAccount(r.Task)
+		if note := strings.Fields(strings.SplitN(strings.TrimSpace(r.Note), "\n", 2)[0]); len(note) > 0 {
+			in += "  " + strings.Join(note, " ")
+		}
+		if _, err := fmt.Fprintln(w, in+"\no "+r.End.Local().Format("2006/01/02 15:04:05")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//serve accepts activity events, such as the domain of the active browser tab from an
+//extension, and records them as provisional logs in the tasks that rules map the domains
+//to, e.g.
+//	[activity.acme]
+//	domains = "acme.com, *.acme.atlassian.net"
+//	task = "clients/acme"
+//which review --provisional then walks through
+
+type activityRule struct {
+	name    string
+	domains []string
+	task    string
+}
+
+func loadActivityRules(c config) []activityRule {
+	var answer []activityRule
+	for key, value := range c {
+		if strings.HasPrefix(key, "activity.") && strings.HasSuffix(key, ".domains") && strings.Count(key, ".") == 2 {
+			name := strings.Split(key, ".")[1]
+			var domains []string
+			for _, d := range strings.Split(value, ",") {
+				if d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "*.")); d != "" {
+					domains = append(domains, d)
+				}
+			}
+			answer = append(answer, activityRule{name, domains, c.get("activity."+name+".task", "")})
+		}
+	}
+	sort.Slice(answer, func(i, j int) bool { return answer[i].name < answer[j].name })
+	return answer
+}
+
+//matches reports whether the domain is one of the rule's, or beneath one
+func (r activityRule) matches(domain string) bool {
+	for _, d := range r.domains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+//an activityRun is a provisional log which grows while events keep arriving for its rule
+type activityRun struct {
+	path  string
+	start time.Time
+	last  time.Time
+	lines []string
+}
+
+//activityRecorder turns events into provisional logs beneath root. Events closer together
+//than gap extend the same log, and each counts for at least a minute.
+type activityRecorder struct {
+	mu    sync.Mutex
+	root  task
+	rules []activityRule
+	gap   time.Duration
+	runs  map[string]*activityRun
+}
+
+func newActivityRecorder(root task) *activityRecorder {
+	gap, err := parseDuration(root.setting("activity_gap", "5m"))
+	if err != nil {
+		panic(errors.New("Invalid activity_gap: " + err.Error()))
+	}
+	return &activityRecorder{root: root, rules: loadActivityRules(loadGlobalConfig()), gap: gap, runs: map[string]*activityRun{}}
+}
+
+//provisional reports whether a log is still waiting to be reviewed
+func provisional(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	_, ok := log(path).frontMatter()["provisional"]
+	return ok
+}
+
+//record adds an event, returning the log it went into, or "" if no rule matched
+func (a *activityRecorder) record(domain, title string, at time.Time) (string, error) {
+	domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+	var rule *activityRule
+	for i := range a.rules {
+		if a.rules[i].matches(domain) {
+			rule = &a.rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return "", nil
+	}
+	dir := rule.task
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(a.root.path(), dir)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t, err := loadOrCreateTask(dir)
+	if err != nil {
+		return "", err
+	}
+	defer lockTree(t.path())()
+
+	//a log which has been reviewed, moved or deleted since is left alone
+	run := a.runs[rule.name]
+	fresh := run == nil || at.Before(run.last) || at.Sub(run.last) > a.gap || !provisional(run.path)
+	start := at
+	if !fresh {
+		start = run.start
+	}
+	//checked before the run changes, so that an event in a locked period is simply refused
+	if _, err := t.unlocked(start, at.Add(time.Minute), false); err != nil {
+		return "", err
+	}
+	if fresh {
+		run = &activityRun{start: at}
+		a.runs[rule.name] = run
+	}
+	run.last = at
+	line := domain
+	if title != "" {
+		line += " " + title
+	}
+	if len(run.lines) == 0 || run.lines[len(run.lines)-1] != line {
+		run.lines = append(run.lines, line)
+	}
+	note := addFrontMatter([]byte(strings.Join(run.lines, "\n")+"\n"), "provisional", "activity")
+	path := t.logPath(run.start, at.Add(time.Minute))
+	if path == run.path {
+		_, err = rewriteLog(path, note, false)
+	} else {
+		_, err = writeNewLog(path, note, false)
+	}
+	if err != nil {
+		return "", err
+	}
+	if run.path == "" {
+		audit(t.path(), "activity", treePath(path))
+	} else if run.path != path {
+		removeLog(run.path, false)
+	}
+	run.path = path
+	return path, nil
+}
+
+//activityEvent is what an extension posts to /activity. Domain may be left out if URL is
+//given, and At defaults to now, and cannot be more than activitySkew after it.
+type activityEvent struct {
+	Domain string    `json:"domain"`
+	URL    string    `json:"url"`
+	Title  string    `json:"title"`
+	At     time.Time `json:"at"`
+}
+
+//activitySkew allows for the clock of whatever sends events being a little ahead
+const activitySkew = time.Minute
+
+//ServeHTTP accepts events as JSON, which web pages cannot post to another origin without
+//asking first, and turns away anything sent from a web page, so that only extensions
+//and local programs can record activity
+func (a *activityRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if origin := r.Header.Get("Origin"); strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://") {
+		http.Error(w, "Activity cannot be sent from web pages", http.StatusForbidden)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, "Use Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var e activityEvent
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "Invalid Event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.Domain == "" {
+		if u, err := url.Parse(e.URL); err == nil {
+			e.Domain = u.Hostname()
+		}
+	}
+	if e.Domain == "" {
+		http.Error(w, "Missing domain or url", http.StatusBadRequest)
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	if e.At.After(time.Now().Add(activitySkew)) {
+		http.Error(w, "Invalid Event: at is in the future", http.StatusBadRequest)
+		return
+	}
+	e.At = e.At.Local()
+	path, err := a.record(e.Domain, e.Title, e.At)
+	if errors.Is(err, errLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if path == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(map[string]string{"task": treePath(filepath.Dir(path)), "log": treePath(path)})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+//amend records a log in the task for a period which has already passed, returning whether
+//a locked period was forced
+func (t task) amend(start, end time.Time, note []byte, force bool) (log, bool, error) {
+	p := t.logPath(start, end)
+	forced, err := writeNewLog(p, note, force)
+	if err != nil {
+		return log(""), false, err
+	}
+	l, err := loadLog(p)
+	return l, forced, err
+}
+
+func amendCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if len(positional) == 0 {
+		panic(errors.New("No task specified"))
+	}
+	path := positional[0]
+	action := "amend"
+	if opts.has("break") {
+		path = task(path).breakTask().path()
+		action = "break"
+	}
+	t, err := loadOrCreateTask(path)
+	if err != nil {
+		panic(err)
+	}
+	arg := strings.Join(positional[1:], " ")
+	if dur, err := parseDuration(arg); err == nil && dur < 0 {
+		if opts.has("from") {
+			panic(errors.New("Invalid Duration: " + arg + " (a negative duration takes time off the logs ending at --end, or now, so cannot be used with --from)"))
+		}
+		until := time.Now()
+		if opts.has("end") {
+			if until, err = parseDate(opts.get("end", "")); err != nil {
+				panic(err)
+			}
+		}
+		defer lockTree(t.path())()
+		t.takeOff(-dur, until, opts.has("force"))
+		return
+	}
+	start, end, err := amendInterval(opts, arg)
+	if err != nil {
+		panic(err)
+	}
+
+	var note []byte
+	if context := sessionContext(t, opts); context != "" {
+		note = addFrontMatter(nil, "context", context)
+	}
+	defer lockTree(t.path())()
+	l, forced, err := t.amend(start, end, note, opts.has("force"))
+	if err != nil {
+		panic(err)
+	}
+	if forced {
+		action += " --force"
+	}
+	audit(t.path(), action, treePath(l.path()))
+	if isBreak(l) {
+		inform(l.start(), l.duration(), "\t\t", breakLabel(l))
+	} else {
+		inform(l.start(), l.duration(), "\t\t", l.dir())
+	}
+}
+
+//a timeCut is the time to take off a log
+type timeCut struct {
+	l        log
+	from, to time.Time
+}
+
+//takeOff takes time off the task's own logs ending before until, for negative amends. The
+//latest log loses the time up to until (or its end, if it ended before), and is shortened,
+//split around the time taken off or removed; if it has less than dur, the rest is taken off
+//the logs before it. Nothing is changed unless the logs have enough time between them.
+func (t task) takeOff(dur time.Duration, until time.Time, force bool) {
+	dur = dur.Round(time.Second)
+	until = until.Truncate(time.Second)
+	ls := t.logsMatching(func(l log) bool { return l.start().Before(until) && l.end().After(l.start()) })
+	latest := func(l log) time.Time {
+		if l.end().After(until) {
+			return until
+		}
+		return l.end()
+	}
+	sort.SliceStable(ls, func(i, j int) bool { return latest(ls[i]).After(latest(ls[j])) })
+
+	var cuts []timeCut
+	remaining := dur
+	for _, l := range ls {
+		if remaining <= 0 {
+			break
+		}
+		to := latest(l)
+		from := to.Add(-remaining)
+		if from.Before(l.start()) {
+			from = l.start()
+		}
+		cuts = append(cuts, timeCut{l, from, to})
+		remaining -= to.Sub(from)
+	}
+	if remaining > 0 {
+		panic(errors.New("Not Enough Time: " + t.path() + " only has " + formatHoursMinutes(dur-remaining) + " logged before " + until.Format("2006-01-02 15:04") + " to take " + formatHoursMinutes(dur) + " off"))
+	}
+	//every log is checked before any is changed
+	forced := false
+	for _, c := range cuts {
+		forced = t.checkUnlocked(c.l.start(), c.l.end(), force) || forced
+	}
+	action := "amend"
+	if forced {
+		action += " --force"
+	}
+
+	for _, c := range cuts {
+		var kept []string
+		before := t.authoredLogPath(c.l.start(), c.from, c.l.author())
+		after := t.authoredLogPath(c.to, c.l.end(), c.l.author())
+		switch {
+		case c.from.After(c.l.start()) && c.l.end().After(c.to):
+			//split, with the note in both, as with sessions split around sleep
+			if _, err := writeNewLog(after, []byte(c.l.text()), force); err != nil {
+				panic(err)
+			}
+			if _, err := renameLog(c.l.path(), before, force); err != nil {
+				panic(err)
+			}
+			kept = []string{treePath(before), treePath(after)}
+		case c.from.After(c.l.start()):
+			if _, err := renameLog(c.l.path(), before, force); err != nil {
+				panic(err)
+			}
+			kept = []string{treePath(before)}
+		case c.l.end().After(c.to):
+			if _, err := renameLog(c.l.path(), after, force); err != nil {
+				panic(err)
+			}
+			kept = []string{treePath(after)}
+		default:
+			if _, err := removeLog(c.l.path(), force); err != nil {
+				panic(err)
+			}
+		}
+		audit(t.path(), action, append([]string{treePath(c.l.path())}, kept...)...)
+		inform(c.from, -c.to.Sub(c.from), "\t\t", c.l.dir())
+	}
+}
+
+//amendInterval works out what to amend from a clock interval, or from a duration and the --from and --end options
+func amendInterval(opts options, arg string) (start, end time.Time, err error) {
+	if start, end, ok := parseInterval(arg); ok {
+		return start, end, nil
+	}
+
+	var dur time.Duration
+	if arg != "" {
+		if dur, err = parseDuration(arg); err != nil {
+			return never, never, err
+		}
+	}
+	end = time.Now()
+	if opts.has("end") {
+		if end, err = parseDate(opts.get("end", "")); err != nil {
+			return never, never, err
+		}
+	}
+	switch {
+	case opts.has("from"):
+		if start, err = parseDate(opts.get("from", "")); err != nil {
+			return never, never, err
+		}
+		if arg != "" && !opts.has("end") {
+			end = start.Add(dur)
+		}
+	case arg != "":
+		start = end.Add(-dur)
+	default:
+		return never, never, errors.New("No duration specified")
+	}
+	return start, end, nil
+}
+
+//parseInterval parses clock times such as 09:00-11:30, optionally preceded by a day
+//(2024-01-05 09:00-11:30, yesterday 9am-1pm). Intervals ending before they start
+//are taken to cross midnight.
+func parseInterval(arg string) (start, end time.Time, ok bool) {
+	words := strings.Fields(strings.ToLower(arg))
+	if len(words) == 0 {
+		return never, never, false
+	}
+	startEnd := strings.Split(words[len(words)-1], "-")
+	if len(startEnd) != 2 {
+		return never, never, false
+	}
+	startH, startM, startS, ok1 := parseClock(startEnd[0])
+	endH, endM, endS, ok2 := parseClock(startEnd[1])
+	if !ok1 || !ok2 {
+		return never, never, false
+	}
+
+	day := time.Now()
+	if len(words) > 1 {
+		var err error
+		day, err = parseDate(strings.Join(words[:len(words)-1], " "))
+		if err != nil {
+			return never, never, false
+		}
+	}
+	start = time.Date(day.Year(), day.Month(), day.Day(), startH, startM, startS, 0, time.Local)
+	end = time.Date(day.Year(), day.Month(), day.Day(), endH, endM, endS, 0, time.Local)
+	if end.Before(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//importATimeTracker reads the database ATimeTracker backs up to the SD card (timetracker.db),
+//in which each range of time belongs to a task and is recorded in milliseconds since 1970,
+//using the sqlite3 command
+func importATimeTracker(path string, opts options) ([]importedLog, error) {
+	if path == "-" {
+		return nil, errors.New("ATimeTracker databases cannot be read from stdin")
+	}
+	query := "SELECT r.start, r.end, t.name FROM ranges r JOIN tasks t ON t._id = r.task_id WHERE r.end IS NOT NULL ORDER BY r.start"
+	out, err := exec.Command("sqlite3", "-readonly", "-csv", expandHome(path), query).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, errors.New("sqlite3 could not read " + path + ": " + strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return nil, errors.New("importing from ATimeTracker requires sqlite3: " + err.Error())
+	}
+	rows, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var answer []importedLog
+	for _, row := range rows {
+		if len(row) != 3 {
+			return nil, errors.New("Invalid ATimeTracker Range: " + strings.Join(row, ","))
+		}
+		start, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		end, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, importedLog{
+			task:  row[2],
+			start: time.Unix(0, start*int64(time.Millisecond)),
+			end:   time.Unix(0, end*int64(time.Millisecond)),
+		})
+	}
+	return answer, nil
+}
+
+//importSimpleTimeTracker reads the CSV export of Simple Time Tracker, using its column
+//names (activity name, time started, time ended, comment, categories and record tags).
+//Activities become tasks, and categories and tags are added to the note as #tags.
+func importSimpleTimeTracker(path string, opts options) ([]importedLog, error) {
+	r, err := openImport(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"activity name", "time started", "time ended"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New("Not a Simple Time Tracker export: " + path + " has no " + name + " column")
+		}
+	}
+
+	var answer []importedLog
+	for i, row := range rows[1:] {
+		get := func(name string) string {
+			if c, ok := columns[name]; ok && c < len(row) {
+				return strings.TrimSpace(row[c])
+			}
+			return ""
+		}
+		l := importedLog{task: strings.Replace(get("activity name"), "/", "-", -1), note: get("comment")}
+		if l.start, err = importTime(get("time started"), opts); err != nil {
+			return nil, errors.New("Invalid time started on row " + strconv.Itoa(i+2) + ": " + get("time started"))
+		}
+		if l.end, err = importTime(get("time ended"), opts); err != nil {
+			return nil, errors.New("Invalid time ended on row " + strconv.Itoa(i+2) + ": " + get("time ended"))
+		}
+		var tags []string
+		for _, tag := range strings.Split(get("categories")+","+get("record tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, "#"+strings.Replace(tag, " ", "-", -1))
+			}
+		}
+		if len(tags) > 0 {
+			if l.note != "" {
+				l.note += "\n"
+			}
+			l.note += strings.Join(tags, " ")
+		}
+		answer = append(answer, l)
+	}
+	return answer, nil
+}
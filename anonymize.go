@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"./format"
+)
+
+//anonymizer hashes names consistently, so that anonymized exports keep the structure
+//of the tree. With a salt, names cannot be recovered by hashing likely candidates.
+type anonymizer []byte
+
+func (a anonymizer) hash(s string) string {
+	if s == "" || s == "." {
+		return s
+	}
+	mac := hmac.New(sha256.New, a)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+//path hashes each level of a slash separated path
+func (a anonymizer) path(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = a.hash(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+//anonymize hashes the task, author and anything else which could name a client or
+//person, and leaves out notes and front matter, keeping the times
+func (a anonymizer) anonymize(rows []exportRow) []exportRow {
+	answer := make([]exportRow, len(rows))
+	for i, r := range rows {
+		r.Task = a.path(r.Task)
+		r.Author = a.hash(r.Author)
+		r.Note = ""
+		r.Meta = nil
+		r.Group = a.hash(r.Group)
+		ext := filepath.Ext(r.Filename)
+		r.Filename = format.EncodeName(r.Start, r.End, r.Author, false) + ext
+		r.settings.client = a.hash(r.settings.client)
+		r.settings.service = a.path(r.settings.service)
+		answer[i] = r
+	}
+	return answer
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//the audit log is an append-only record of changes made to the tree, in
+//.horolog/audit.log, with one tab separated line per change:
+//	time user action details...
+//where the details of changes to logs are their paths relative to the root of the tree
+func audit(dir, action string, details ...string) {
+	user := os.Getenv("USER")
+	if t, err := loadTask(dir); err == nil {
+		user = t.user()
+	}
+	fields := append([]string{time.Now().Format(time.RFC3339), user, action}, details...)
+	f, err := os.OpenFile(filepath.Join(stateDir(dir), "audit.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(fields, "\t") + "\n")
+	if err != nil {
+		panic(err)
+	}
+}
+
+//treePath returns path relative to the root of its tree
+func treePath(path string) string {
+	root, err := filepath.Abs(treeRoot(filepath.Dir(path)))
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+//historyCommand shows the audit log entries for a task and its subtasks
+func historyCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "all"))
+	if err != nil {
+		panic(err)
+	}
+	prefix := treePath(t.path())
+
+	b, err := ioutil.ReadFile(filepath.Join(treeRoot(dir), stateDirName, "audit.log"))
+	if err != nil && !os.IsNotExist(err) {
+		panic(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		when, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil || (from != never && when.Before(from)) || (to != never && !when.Before(to)) {
+			continue
+		}
+		matches := prefix == "."
+		for _, detail := range fields[3:] {
+			if detail == prefix || strings.HasPrefix(detail, prefix+"/") {
+				matches = true
+			}
+		}
+		if matches {
+			fmt.Fprintln(w, strings.Join(fields, "\t"))
+		}
+	}
+	w.Flush()
+}
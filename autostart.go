@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//autostart rules have watch track a task while files change in a directory, such as a
+//workspace open in an editor. They are kept in the config directory, one per line:
+//path, task and how long after the last change to stop, separated by tabs.
+
+type autostartRule struct {
+	path  string
+	task  string
+	after time.Duration
+}
+
+func autostartPath() string {
+	return filepath.Join(configDir(), "autostart")
+}
+
+func loadAutostartRules() []autostartRule {
+	f, err := os.Open(autostartPath())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var answer []autostartRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		after, err := parseDuration(fields[2])
+		if err != nil {
+			debug("skipping autostart rule with invalid inactivity:", scanner.Text())
+			continue
+		}
+		answer = append(answer, autostartRule{fields[0], fields[1], after})
+	}
+	return answer
+}
+
+func saveAutostartRules(rules []autostartRule) error {
+	if err := os.MkdirAll(configDir(), 0755); err != nil {
+		return err
+	}
+	var text string
+	for _, r := range rules {
+		text += r.path + "\t" + r.task + "\t" + r.after.String() + "\n"
+	}
+	return writeFileAtomic(autostartPath(), []byte(text), 0644)
+}
+
+//lastChange returns when a file in dir was last changed, leaving out hidden directories
+//(such as .git) and node_modules, which change without anyone working
+func lastChange(dir string) time.Time {
+	var last time.Time
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+		return nil
+	})
+	return last
+}
+
+//autostartSession is a session watch started for a rule, which runs horolog <task> -
+//until its stdin is closed
+type autostartSession struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	exited chan struct{}
+}
+
+func startAutostart(r autostartRule) (*autostartSession, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, "--quiet", r.task, "-")
+	cmd.Stderr = os.Stderr
+	ownProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	a := &autostartSession{cmd, stdin, make(chan struct{})}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			debug("autostart session in", r.task, "failed:", err)
+		}
+		close(a.exited)
+	}()
+	io.WriteString(stdin, "Files changed in "+r.path+"\n")
+	return a, nil
+}
+
+//stop ends the session, leaving out the time since the last change
+func (a *autostartSession) stop(last time.Time) {
+	if s, err := loadSession(a.cmd.Process.Pid); err == nil && last.After(s.start) {
+		if err := s.addPause(last, time.Now()); err != nil {
+			debug("cannot leave out the time since", last.Format("15:04:05")+":", err)
+		}
+	}
+	a.stdin.Close()
+	<-a.exited
+}
+
+//watchAutostart starts and stops sessions for the autostart rules (reread each time, so
+//that new rules apply at once) until ctx is done, then stops them and closes done
+func watchAutostart(every time.Duration, done chan struct{}) {
+	defer close(done)
+	running := map[autostartRule]*autostartSession{}
+	lasts := map[autostartRule]time.Time{}
+	defer func() {
+		for r, a := range running {
+			a.stop(lasts[r])
+		}
+	}()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		rules := map[autostartRule]bool{}
+		for _, r := range loadAutostartRules() {
+			rules[r] = true
+			last := lastChange(r.path)
+			lasts[r] = last
+			active := time.Since(last) < r.after
+			a, ok := running[r]
+			if ok {
+				select {
+				case <-a.exited:
+					ok = false
+					delete(running, r)
+				default:
+				}
+			}
+			switch {
+			case active && !ok:
+				tracked := false
+				for _, s := range loadSessions() {
+					tracked = tracked || s.task == r.task
+				}
+				if tracked {
+					continue
+				}
+				debug("files changed in", r.path+", tracking", r.task)
+				a, err := startAutostart(r)
+				if err != nil {
+					debug("cannot track", r.task+":", err)
+					continue
+				}
+				running[r] = a
+			case !active && ok:
+				debug("no changes in", r.path, "since", last.Format("15:04:05")+", stopping", r.task)
+				a.stop(last)
+				delete(running, r)
+			}
+		}
+		//rules which have been removed stop at once
+		for r, a := range running {
+			if !rules[r] {
+				a.stop(time.Now())
+				delete(running, r)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//autostartCommand adds, removes or lists autostart rules
+func autostartCommand(args []string) {
+	opts, _ := parseOptions(args)
+	rules := loadAutostartRules()
+	switch {
+	case opts.has("remove"):
+		path, err := filepath.Abs(expandHome(opts.get("remove", "")))
+		if err != nil {
+			panic(err)
+		}
+		var kept []autostartRule
+		for _, r := range rules {
+			if r.path != path {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == len(rules) {
+			panic(errors.New("No autostart rule for " + path))
+		}
+		if err := saveAutostartRules(kept); err != nil {
+			panic(err)
+		}
+		inform("Removed the autostart rule for", path)
+	case opts.has("when-path"):
+		path, err := filepath.Abs(expandHome(opts.get("when-path", "")))
+		if err != nil {
+			panic(err)
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			panic(errors.New("Not a directory: " + path))
+		}
+		if !opts.has("task") {
+			panic(errors.New("Missing --task"))
+		}
+		t, err := loadOrCreateTask(opts.get("task", ""))
+		if err != nil {
+			panic(err)
+		}
+		task, err := filepath.Abs(t.path())
+		if err != nil {
+			panic(err)
+		}
+		after, err := parseDuration(opts.get("after", "15m"))
+		if err != nil || after <= 0 {
+			panic(errors.New("Invalid --after: " + opts.get("after", "")))
+		}
+		var kept []autostartRule
+		for _, r := range rules {
+			if r.path != path {
+				kept = append(kept, r)
+			}
+		}
+		if err := saveAutostartRules(append(kept, autostartRule{path, task, after})); err != nil {
+			panic(err)
+		}
+		inform("watch will track", task, "while files change in", path+", until", after, "after the last change")
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, r := range rules {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.path, r.task, r.after)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+//everyLog is a filter which matches every log
+func everyLog(log) bool { return true }
+
+//a benchStage is one part of horolog's work which bench times. prepare does anything
+//which should not be timed, and returns the work to time, which returns what it counted.
+type benchStage struct {
+	name    string
+	counts  string
+	prepare func(t task) func() int
+}
+
+var benchStages = []benchStage{
+	{"scan", "logs", func(t task) func() int {
+		return func() int { return len(t.recursiveLogsMatching(everyLog)) }
+	}},
+	{"stream", "logs", func(t task) func() int {
+		return func() int {
+			count := 0
+			t.forEachLog(everyLog, func(log) error {
+				count++
+				return nil
+			})
+			return count
+		}
+	}},
+	{"read notes", "bytes", func(t task) func() int {
+		return func() int {
+			size := 0
+			for _, l := range t.recursiveLogsMatching(everyLog) {
+				size += len(l.text())
+			}
+			return size
+		}
+	}},
+	{"tree load", "tasks", func(t task) func() int {
+		return func() int { return len(loadTree(t).tasks()) }
+	}},
+	{"tree refresh", "changed", func(t task) func() int {
+		tr := loadTree(t)
+		return func() int { return len(tr.refresh()) }
+	}},
+	{"tree totals", "tasks", func(t task) func() int {
+		tr := loadTree(t)
+		from, to, _ := parsePeriod("this-month")
+		return func() int { return len(tr.totals(from, to)) }
+	}},
+	{"summary", "bytes", func(t task) func() int {
+		return func() int { return len(summaryText(everyLog, options{}, []string{t.path()})) }
+	}},
+	{"report", "logs", func(t task) func() int {
+		tmpl, err := loadReportTemplate("")
+		if err != nil {
+			panic(err)
+		}
+		return func() int {
+			data := newReportData(t, "all", never, never)
+			if err := tmpl.Execute(ioutil.Discard, data); err != nil {
+				panic(err)
+			}
+			return len(data.Logs)
+		}
+	}},
+}
+
+//benchCommand times each stage of horolog's work on a tree, so that performance can be
+//compared between versions, machines and filesystems, optionally writing CPU and memory
+//profiles for go tool pprof
+func benchCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	runs := 3
+	if opts.has("runs") {
+		if runs, err = strconv.Atoi(opts.get("runs", "")); err != nil || runs < 1 {
+			panic(errors.New("Invalid --runs: " + opts.get("runs", "")))
+		}
+	}
+	if opts.has("cpuprofile") {
+		f, err := os.Create(opts.get("cpuprofile", ""))
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			panic(err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Stage\tCount\tFirst\tBest\tMean")
+	for _, stage := range benchStages {
+		var first, best, sum time.Duration
+		count := 0
+		for i := 0; i < runs; i++ {
+			checkCanceled()
+			work := stage.prepare(t)
+			start := time.Now()
+			count = work()
+			elapsed := time.Since(start)
+			if i == 0 {
+				first, best = elapsed, elapsed
+			}
+			if elapsed < best {
+				best = elapsed
+			}
+			sum += elapsed
+		}
+		fmt.Fprintf(w, "%s\t%d %s\t%s\t%s\t%s\n", stage.name, count, stage.counts, first.Round(time.Microsecond), best.Round(time.Microsecond), (sum / time.Duration(runs)).Round(time.Microsecond))
+	}
+	w.Flush()
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	fmt.Printf("Allocated %.1f MB in total, %s %s/%s, %d CPUs\n", float64(m.TotalAlloc)/(1<<20), runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+	if opts.has("memprofile") {
+		f, err := os.Create(opts.get("memprofile", ""))
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//status is where a task is on the board, from the status in its own .horolog.conf
+//(statuses are not inherited by subtasks)
+func (t task) status() string {
+	return strings.ToLower(strings.TrimSpace(t.config().get("status", "")))
+}
+
+//statusLine matches a status setting before the first [section] of a config file
+var statusLine = regexp.MustCompile(`(?m)^[ \t]*status[ \t]*=.*$`)
+
+//setStatus moves a task to another column of the board, rewriting the status in its
+//.horolog.conf, or adding one at the top
+func (t task) setStatus(status string) {
+	path := filepath.Join(t.path(), taskConfigName)
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		panic(err)
+	}
+	line := "status = \"" + status + "\""
+	text := string(b)
+	head := text
+	if i := strings.Index(text, "\n["); i >= 0 {
+		head = text[:i+1]
+	} else if strings.HasPrefix(text, "[") {
+		head = ""
+	}
+	if loc := statusLine.FindStringIndex(head); loc != nil {
+		text = text[:loc[0]] + line + text[loc[1]:]
+	} else {
+		text = line + "\n" + text
+	}
+	if err := writeFileAtomic(path, []byte(text), 0644); err != nil {
+		panic(err)
+	}
+}
+
+//a boardCard is a task on the board with the time logged in it and its subtasks
+type boardCard struct {
+	name  string
+	total time.Duration
+}
+
+//boardColumns returns the columns of the board, from --columns or the board_columns
+//setting, followed by any other statuses used beneath t
+func boardColumns(t task, opts options, cards map[string][]boardCard) []string {
+	var answer []string
+	seen := map[string]bool{}
+	for _, c := range strings.Split(opts.get("columns", t.setting("board_columns", "todo,doing,done")), ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && !seen[c] {
+			answer = append(answer, c)
+			seen[c] = true
+		}
+	}
+	var extra []string
+	for c := range cards {
+		if !seen[c] {
+			extra = append(extra, c)
+		}
+	}
+	sort.Strings(extra)
+	return append(answer, extra...)
+}
+
+//boardCommand shows the tasks beneath a task which have a status as cards in a column
+//for each status, or with --set moves the task to another column
+func boardCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	if opts.has("set") {
+		status := strings.ToLower(strings.TrimSpace(opts.get("set", "")))
+		if status == "" || strings.ContainsAny(status, "\"\n#") {
+			panic(errors.New("Invalid --set: " + opts.get("set", "")))
+		}
+		defer lockTree(t.path())()
+		t.setStatus(status)
+		audit(t.path(), "status", treePath(t.path()), status)
+		inform(t.path(), "is now", status)
+		return
+	}
+
+	cards := map[string][]boardCard{}
+	for _, t2 := range append([]task{t}, t.recursiveSubtasks()...) {
+		status := t2.status()
+		if status == "" {
+			continue
+		}
+		rel, err := filepath.Rel(t.path(), t2.path())
+		if err != nil {
+			rel = t2.path()
+		}
+		cards[status] = append(cards[status], boardCard{filepath.ToSlash(rel), t2.durationMatching(everyLog)})
+	}
+	if len(cards) == 0 {
+		inform("No tasks have a status (use horolog board <task> --set=todo)")
+		return
+	}
+
+	columns := boardColumns(t, opts, cards)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	rows := 0
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		var total time.Duration
+		for _, card := range cards[c] {
+			total += card.total
+		}
+		header[i] = fmt.Sprintf("%s (%d, %s)", strings.ToUpper(c), len(cards[c]), formatHoursMinutes(total))
+		if len(cards[c]) > rows {
+			rows = len(cards[c])
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for r := 0; r < rows; r++ {
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			if r < len(cards[c]) {
+				cells[i] = cards[c][r].name + " " + formatHoursMinutes(cards[c][r].total)
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+}
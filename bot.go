@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//the bot lets the timer be started and stopped, and time checked, by messaging it on
+//Telegram or Matrix. Like rpc, it owns the sessions it starts, so they show up in status,
+//prompt and so on, and are logged when it stops.
+
+type botMessage struct {
+	chat string
+	text string
+}
+
+//a botTransport waits for messages, and replies to them
+type botTransport interface {
+	receive(ctx context.Context) ([]botMessage, error)
+	send(chat, text string) error
+}
+
+//botRequest makes a request to a chat service, decoding its JSON answer
+func botRequest(ctx context.Context, method, u string, header http.Header, body io.Reader, answer interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(resp.Status + ": " + strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(resp.Body).Decode(answer)
+}
+
+//telegramBot uses the Bot API's long polling, answering only the allowed chats
+type telegramBot struct {
+	api     string
+	token   string
+	chats   map[string]bool
+	offset  int64
+	started bool
+}
+
+type telegramUpdates struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      []struct {
+		UpdateID int64 `json:"update_id"`
+		Message  *struct {
+			Chat struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+			Text string `json:"text"`
+		} `json:"message"`
+	} `json:"result"`
+}
+
+func (b *telegramBot) receive(ctx context.Context) ([]botMessage, error) {
+	//messages sent while the bot was not running are skipped, rather than acted on late
+	timeout, offset := "30", strconv.FormatInt(b.offset, 10)
+	if !b.started {
+		timeout, offset = "0", "-1"
+	}
+	var updates telegramUpdates
+	u := b.api + "/bot" + b.token + "/getUpdates?timeout=" + timeout + "&offset=" + offset
+	if err := botRequest(ctx, "GET", u, nil, nil, &updates); err != nil {
+		return nil, err
+	}
+	if !updates.OK {
+		return nil, errors.New("Telegram Error: " + updates.Description)
+	}
+	var answer []botMessage
+	for _, update := range updates.Result {
+		b.offset = update.UpdateID + 1
+		if update.Message == nil || !b.started {
+			continue
+		}
+		chat := strconv.FormatInt(update.Message.Chat.ID, 10)
+		if !b.chats[chat] {
+			debug("ignoring telegram chat", chat)
+			b.send(chat, "This chat ("+chat+") is not in bot.telegram_chats")
+			continue
+		}
+		answer = append(answer, botMessage{chat, update.Message.Text})
+	}
+	b.started = true
+	return answer, nil
+}
+
+func (b *telegramBot) send(chat, text string) error {
+	form := url.Values{"chat_id": {chat}, "text": {text}}
+	var answer telegramUpdates
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	return botRequest(context.Background(), "POST", b.api+"/bot"+b.token+"/sendMessage", header, strings.NewReader(form.Encode()), &answer)
+}
+
+//matrixBot uses the client-server API's sync, answering only the allowed user in its room
+type matrixBot struct {
+	homeserver string
+	token      string
+	room       string
+	user       string
+	since      string
+}
+
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []struct {
+					Type    string `json:"type"`
+					Sender  string `json:"sender"`
+					Content struct {
+						MsgType string `json:"msgtype"`
+						Body    string `json:"body"`
+					} `json:"content"`
+				} `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+func (b *matrixBot) header() http.Header {
+	return http.Header{"Authorization": {"Bearer " + b.token}, "Content-Type": {"application/json"}}
+}
+
+func (b *matrixBot) receive(ctx context.Context) ([]botMessage, error) {
+	//as with telegram, the first sync only finds where to start
+	query := url.Values{"timeout": {"0"}}
+	if b.since != "" {
+		query = url.Values{"timeout": {"30000"}, "since": {b.since}}
+	}
+	var sync matrixSync
+	if err := botRequest(ctx, "GET", b.homeserver+"/_matrix/client/v3/sync?"+query.Encode(), b.header(), nil, &sync); err != nil {
+		return nil, err
+	}
+	first := b.since == ""
+	b.since = sync.NextBatch
+	if first {
+		return nil, nil
+	}
+	var answer []botMessage
+	for _, e := range sync.Rooms.Join[b.room].Timeline.Events {
+		if e.Type == "m.room.message" && e.Content.MsgType == "m.text" && e.Sender == b.user {
+			answer = append(answer, botMessage{b.room, e.Content.Body})
+		}
+	}
+	return answer, nil
+}
+
+func (b *matrixBot) send(chat, text string) error {
+	body, _ := json.Marshal(map[string]string{"msgtype": "m.notice", "body": text})
+	txn := "horolog" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	u := b.homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(chat) + "/send/m.room.message/" + txn
+	var answer struct{}
+	return botRequest(context.Background(), "PUT", u, b.header(), bytes.NewReader(body), &answer)
+}
+
+//chooseBotTransport uses --via, or else whichever service is configured
+func chooseBotTransport(root task, via string) (botTransport, error) {
+	setting := func(key string) string { return root.setting("bot."+key, "") }
+	if via == "" && setting("telegram_token") != "" {
+		via = "telegram"
+	}
+	if via == "" && setting("matrix_token") != "" {
+		via = "matrix"
+	}
+	switch via {
+	case "telegram":
+		chats := map[string]bool{}
+		for _, chat := range strings.Split(setting("telegram_chats"), ",") {
+			if chat = strings.TrimSpace(chat); chat != "" {
+				chats[chat] = true
+			}
+		}
+		if setting("telegram_token") == "" {
+			return nil, errors.New("Missing bot.telegram_token")
+		}
+		return &telegramBot{api: root.setting("bot.telegram_api", "https://api.telegram.org"), token: setting("telegram_token"), chats: chats}, nil
+	case "matrix":
+		b := &matrixBot{strings.TrimSuffix(setting("matrix_homeserver"), "/"), setting("matrix_token"), setting("matrix_room"), setting("matrix_user"), ""}
+		if b.homeserver == "" || b.token == "" || b.room == "" || b.user == "" {
+			return nil, errors.New("Missing bot.matrix_homeserver, matrix_token, matrix_room or matrix_user")
+		}
+		return b, nil
+	case "":
+		return nil, errors.New("No bot configured (see [bot] in Configuration)")
+	}
+	return nil, errors.New("Invalid --via: " + via + " (use telegram or matrix)")
+}
+
+//a chatBot answers messages about the tree beneath root
+type chatBot struct {
+	root task
+	rpc  rpcServer
+}
+
+const botHelp = `start <task> [note]: start logging the task
+note <text>: add to the note
+stop: log the session (stop discard to throw it away)
+status: what is being logged
+today? (or yesterday?, this-week? or any period): time logged`
+
+//describe is a short description of a session for a reply
+func (b *chatBot) describe(st rpcStatus) string {
+	text := st.Task + " " + formatHoursMinutes(time.Duration(st.Seconds)*time.Second)
+	if st.Paused {
+		text += " (paused)"
+	}
+	if !st.Own {
+		text += " (not started here)"
+	}
+	return text
+}
+
+//summary lists the time logged in each top level task in the period
+func (b *chatBot) summary(period string) string {
+	from, to, err := parsePeriod(period)
+	if err != nil {
+		return err.Error()
+	}
+	lines := rollUp(b.root.summaryLines(between(from, to)), b.root, 1)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].logs.duration() > lines[j].logs.duration() })
+	var total time.Duration
+	var text string
+	for _, line := range lines {
+		if line.logs.duration() == 0 {
+			continue
+		}
+		total += line.logs.duration()
+		text += "\n" + treePath(line.task.path()) + " " + formatHoursMinutes(line.logs.duration())
+	}
+	return "Total " + formatHoursMinutes(total) + text
+}
+
+//handle answers a message, turning errors into the answer
+func (b *chatBot) handle(text string) (answer string) {
+	defer func() {
+		if r := recover(); r != nil {
+			answer = fmt.Sprint(r)
+		}
+	}()
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return botHelp
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), words[0]))
+	switch command := strings.ToLower(strings.TrimPrefix(words[0], "/")); {
+	case command == "start" && len(words) > 1:
+		st, err := b.rpc.start(rpcStartParams{Task: importTaskPath(b.root.path(), words[1])})
+		if err != nil {
+			return err.Error()
+		}
+		if note := strings.TrimSpace(strings.TrimPrefix(rest, words[1])); note != "" {
+			if _, err := b.rpc.note(rpcNoteParams{Text: note + "\n", Append: true}); err != nil {
+				return err.Error()
+			}
+		}
+		return "Started " + b.describe(st.(rpcStatus))
+	case command == "note" && rest != "":
+		if _, err := b.rpc.note(rpcNoteParams{Text: rest + "\n", Append: true}); err != nil {
+			return err.Error()
+		}
+		return "Noted"
+	case command == "stop":
+		action := strings.ToLower(rest)
+		st, err := b.rpc.stop(rpcStopParams{Action: action})
+		if err != nil {
+			return err.Error()
+		}
+		if action == "discard" {
+			return "Discarded " + b.describe(st.(rpcStatus))
+		}
+		return "Logged " + b.describe(st.(rpcStatus))
+	case command == "status" || command == "?":
+		st, err := b.rpc.status()
+		if err != nil {
+			return err.Error()
+		}
+		if !st.(rpcStatus).Running {
+			return "Nothing is being logged"
+		}
+		return b.describe(st.(rpcStatus))
+	case len(words) == 1 && strings.HasSuffix(command, "?"):
+		return b.summary(strings.TrimSuffix(command, "?"))
+	}
+	return botHelp
+}
+
+//botCommand runs the bot until it is interrupted, then logs any session it started
+func botCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	root, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	transport, err := chooseBotTransport(root, opts.get("via", ""))
+	if err != nil {
+		panic(err)
+	}
+	b := &chatBot{root: root}
+	defer func() {
+		if b.rpc.session != nil {
+			if _, err := b.rpc.stop(rpcStopParams{}); err != nil {
+				panic(err)
+			}
+		}
+	}()
+	sdNotify("READY=1")
+	inform("Answering messages about", root.path())
+	for ctx.Err() == nil {
+		messages, err := transport.receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			debug("cannot receive messages:", err)
+			select {
+			case <-time.After(30 * time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, m := range messages {
+			debug("message:", m.text)
+			if err := transport.send(m.chat, b.handle(m.text)); err != nil {
+				debug("cannot reply:", err)
+			}
+		}
+	}
+	sdNotify("STOPPING=1")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//breaks such as lunch are recorded like logs, but in .horolog/breaks beneath the root of
+//the tree (under the path of the task they were recorded in) so that they are not counted
+//as work. They still appear in timelines, and count as breaks in compliance reports.
+func (t task) breakTask() task {
+	return task(filepath.Join(treeRoot(t.path()), stateDirName, "breaks", treePath(t.path())))
+}
+
+func isBreak(l log) bool {
+	return strings.Contains(filepath.ToSlash(l.path()), stateDirName+"/breaks/")
+}
+
+//breakLabel is the task a break was recorded in, relative to the root of the tree
+func breakLabel(l log) string {
+	path := filepath.ToSlash(filepath.Dir(l.path()))
+	return "(break) " + path[strings.Index(path, stateDirName+"/breaks/")+len(stateDirName+"/breaks/"):] + "/"
+}
+
+//recordedBreaks returns the breaks recorded in the task and its subtasks which match f
+func (t task) recordedBreaks(f filter) logs {
+	bt := t.breakTask()
+	if _, err := loadTask(bt.path()); err != nil {
+		return nil
+	}
+	return bt.recursiveLogsMatching(f)
+}
+
+//a workday is the time worked on one day, and the breaks between its logs
+type workday struct {
+	day    time.Time
+	worked time.Duration
+	breaks time.Duration
+}
+
+//dailyBreaks merges the overlapping logs of each day, counting the gaps between them
+//which are at least minGap long as breaks, along with any recorded breaks
+func dailyBreaks(ls logs, minGap time.Duration) []workday {
+	sort.Sort(logsByStart(ls))
+	var answer []workday
+	var pauses []interval
+	var end time.Time
+	finishDay := func() {
+		if len(answer) > 0 {
+			answer[len(answer)-1].breaks = coveredTime(pauses)
+		}
+		pauses = nil
+	}
+	for _, l := range ls {
+		start := l.start()
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+		if len(answer) == 0 || !answer[len(answer)-1].day.Equal(day) {
+			finishDay()
+			answer = append(answer, workday{day: day})
+			end = start
+		}
+		if isBreak(l) {
+			pauses = append(pauses, interval{l.start(), l.end()})
+			continue
+		}
+		if gap := start.Sub(end); gap >= minGap && gap > 0 {
+			pauses = append(pauses, interval{end, start})
+		}
+		if start.Before(end) {
+			start = end
+		}
+		if l.end().After(start) {
+			answer[len(answer)-1].worked += l.end().Sub(start)
+			end = l.end()
+		}
+	}
+	finishDay()
+	return answer
+}
+
+type interval struct {
+	start time.Time
+	end   time.Time
+}
+
+//coveredTime is the time covered by at least one of the intervals
+func coveredTime(intervals []interval) time.Duration {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+	var answer time.Duration
+	var end time.Time
+	for _, i := range intervals {
+		if i.start.After(end) {
+			end = i.start
+		}
+		if i.end.After(end) {
+			answer += i.end.Sub(end)
+			end = i.end
+		}
+	}
+	return answer
+}
+
+//breaksCommand reports days on which more than --max-work was worked with less than
+//--min-break of breaks in total, exiting with status 1 if there are any
+func breaksCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "this-month"))
+	if err != nil {
+		panic(err)
+	}
+	maxWork, minBreak, minGap := 6*time.Hour, 30*time.Minute, 15*time.Minute
+	if opts.has("max-work") {
+		maxWork = opts.duration("max-work")
+	}
+	if opts.has("min-break") {
+		minBreak = opts.duration("min-break")
+	}
+	if opts.has("min-gap") {
+		minGap = opts.duration("min-gap")
+	}
+
+	problems := 0
+	ls := append(t.recursiveLogsMatching(between(from, to)), t.recordedBreaks(between(from, to))...)
+	for _, wd := range dailyBreaks(ls, minGap) {
+		compliant := wd.worked <= maxWork || wd.breaks >= minBreak
+		if !compliant {
+			problems++
+		}
+		if !compliant || opts.has("all") {
+			fmt.Println(wd.day.Format("2006-01-02 Mon"), "worked", formatHoursMinutes(wd.worked), "with", formatHoursMinutes(wd.breaks), "of breaks")
+		}
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
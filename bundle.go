@@ -0,0 +1,407 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//a bundle is a gzipped tarball of a task's directory, for backups and for moving logs
+//between machines. Its first entry is a manifest in sha256sum format, with comments
+//recording the format version, task and when it was created, e.g.
+//	# horolog bundle 1
+//	# task clients
+//	# created 2024-01-08T10:00:00+01:00
+//	9f86d081...  acme/2024-01-08 10:00:00+01:00=>2024-01-08 11:00:00+01:00.txt
+//followed by every file it lists, with paths relative to the task. Bundles of the
+//root of a tree also contain its .horolog directory.
+const (
+	bundleManifestName = "horolog-bundle.sha256"
+	bundleVersion      = 1
+)
+
+//unbundled are the files in .horolog which only make sense on the machine they are on
+var unbundled = map[string]bool{
+	stateDirName + "/lock":  true,
+	stateDirName + "/cache": true,
+}
+
+//mergedRecords are the files in .horolog which are appended to by each machine, and whose
+//lines are merged on restore
+var mergedRecords = map[string]bool{
+	stateDirName + "/audit.log":        true,
+	stateDirName + "/locks":            true,
+	stateDirName + "/milestones":       true,
+	stateDirName + "/copies":           true,
+	stateDirName + "/integrity.sha256": true,
+	stateDirName + "/absences":         true,
+}
+
+//bundleFiles returns the paths, relative to dir, of the files to bundle, leaving out
+//hidden directories other than the tree's .horolog, and its lock and cache
+func bundleFiles(dir string) ([]string, error) {
+	var answer []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		checkCanceled()
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			if rel != "." && strings.HasPrefix(fi.Name(), ".") && rel != stateDirName && fi.Name() != logsDirName || unbundled[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.Mode().IsRegular() && !unbundled[rel] {
+			answer = append(answer, rel)
+		}
+		return nil
+	})
+	sort.Strings(answer)
+	return answer, err
+}
+
+func bundleCreate(dir, out string) {
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	defer lockTree(dir)()
+	files, err := bundleFiles(t.path())
+	if err != nil {
+		panic(err)
+	}
+	if task(treeRoot(dir)).path() != t.path() {
+		//the tree's state is only bundled with its root, where it would be restored
+		var own []string
+		for _, f := range files {
+			if !strings.HasPrefix(f, stateDirName+"/") {
+				own = append(own, f)
+			}
+		}
+		files = own
+	}
+
+	manifest := "# horolog bundle " + strconv.Itoa(bundleVersion) + "\n"
+	manifest += "# task " + treePath(t.path()) + "\n"
+	manifest += "# created " + time.Now().Format(time.RFC3339) + "\n"
+	for _, f := range files {
+		hash, err := hashFile(filepath.Join(t.path(), f))
+		if err != nil {
+			panic(err)
+		}
+		manifest += hash + "  " + f + "\n"
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	add := func(name string, b []byte, mode os.FileMode, modTime time.Time) {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: int64(mode.Perm()), Size: int64(len(b)), ModTime: modTime, Typeflag: tar.TypeReg})
+		if err == nil {
+			_, err = tw.Write(b)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	add(bundleManifestName, []byte(manifest), 0644, time.Now())
+	for _, f := range files {
+		path := filepath.Join(t.path(), f)
+		fi, err := os.Stat(path)
+		if err != nil {
+			panic(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		add(f, b, fi.Mode(), fi.ModTime())
+	}
+	if err := tw.Close(); err != nil {
+		panic(err)
+	}
+	if err := gz.Close(); err != nil {
+		panic(err)
+	}
+
+	if out == "-" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := writeFileAtomic(out, buf.Bytes(), 0600); err != nil {
+		panic(err)
+	}
+	inform("Bundled", len(files), "files from", t.path(), "in", out)
+}
+
+//a bundledFile is a file read from a bundle, which has been checked against its manifest
+type bundledFile struct {
+	name    string
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+//readBundle reads a bundle, checking that it contains exactly the files in its manifest
+func readBundle(r io.Reader) ([]bundledFile, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.New("Invalid Bundle: " + err.Error())
+	}
+	tr := tar.NewReader(gz)
+	hashes := map[string]string{}
+	var answer []bundledFile
+	for first := true; ; first = false {
+		checkCanceled()
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("Invalid Bundle: " + err.Error())
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.New("Invalid Bundle: " + err.Error())
+		}
+		if first {
+			if h.Name != bundleManifestName {
+				return nil, errors.New("Invalid Bundle: no manifest")
+			}
+			if hashes, err = parseBundleManifest(b); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		name := filepath.ToSlash(filepath.Clean(h.Name))
+		if h.Typeflag != tar.TypeReg || filepath.IsAbs(h.Name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, errors.New("Invalid Bundle: unexpected entry " + h.Name)
+		}
+		want, ok := hashes[name]
+		if !ok {
+			return nil, errors.New("Invalid Bundle: " + name + " is not in the manifest")
+		}
+		sum := sha256.Sum256(b)
+		if hex.EncodeToString(sum[:]) != want {
+			return nil, errors.New("Invalid Bundle: " + name + " does not match its checksum")
+		}
+		delete(hashes, name)
+		answer = append(answer, bundledFile{name, b, os.FileMode(h.Mode).Perm(), h.ModTime})
+	}
+	for name := range hashes {
+		return nil, errors.New("Invalid Bundle: " + name + " is missing")
+	}
+	return answer, nil
+}
+
+func parseBundleManifest(b []byte) (map[string]string, error) {
+	hashes := map[string]string{}
+	version := 0
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# horolog bundle "):
+			version, _ = strconv.Atoi(strings.TrimPrefix(line, "# horolog bundle "))
+		case strings.HasPrefix(line, "#") || line == "":
+		default:
+			hashPath := strings.SplitN(line, "  ", 2)
+			if len(hashPath) != 2 {
+				return nil, errors.New("Invalid Bundle: bad manifest line " + line)
+			}
+			hashes[hashPath[1]] = hashPath[0]
+		}
+	}
+	if version < 1 || version > bundleVersion {
+		return nil, errors.New("Unsupported Bundle: version " + strconv.Itoa(version) + " (this horolog reads up to " + strconv.Itoa(bundleVersion) + ")")
+	}
+	return hashes, scanner.Err()
+}
+
+//mergeLines appends the lines of add which are not already in existing, for mergedRecords
+func mergeLines(existing, add []byte) []byte {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(existing), "\n") {
+		seen[line] = true
+	}
+	merged := existing
+	if len(merged) > 0 && !bytes.HasSuffix(merged, []byte("\n")) {
+		merged = append(merged, '\n')
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(add), "\n"), "\n") {
+		if !seen[line] {
+			merged = append(merged, line+"\n"...)
+			seen[line] = true
+		}
+	}
+	return merged
+}
+
+func bundleRestore(in, dir string, opts options) {
+	r, err := openImport(in)
+	if err != nil {
+		panic(err)
+	}
+	files, err := readBundle(r)
+	r.Close()
+	if err != nil {
+		panic(err)
+	}
+	dryRun := opts.has("dry-run")
+	if !dryRun {
+		createTask(dir)
+		defer lockTree(dir)()
+	}
+
+	//nothing is written if any log would be overwritten, or written in a locked period,
+	//unless --force is given
+	type change struct {
+		f      bundledFile
+		path   string
+		data   []byte
+		exists bool
+		merged bool
+	}
+	var changes []change
+	var conflicts, locked []string
+	unchanged, forced := 0, false
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.name))
+		if unbundled[f.name] || strings.HasPrefix(f.name, stateDirName+"/cache/") {
+			//from bundles made before these were left out
+			continue
+		}
+		if f.name == stateDirName+"/version" {
+			//logs are restored as they are, so the bundle must be in the tree's own version
+			if err := checkBundleVersion(dir, f.data); err != nil {
+				panic(err)
+			}
+			continue
+		}
+		c := change{f: f, path: path, data: f.data}
+		existing, err := ioutil.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			panic(err)
+		case bytes.Equal(existing, f.data):
+			unchanged++
+			continue
+		case mergedRecords[f.name]:
+			c.data = mergeLines(existing, f.data)
+		case !opts.has("force"):
+			conflicts = append(conflicts, path)
+			continue
+		default:
+			c.exists = true
+		}
+		c.merged = mergedRecords[f.name]
+		if !c.merged {
+			lockForced, err := logUnlocked(path, opts.has("force"))
+			if err != nil {
+				locked = append(locked, path)
+				continue
+			}
+			forced = forced || lockForced
+		}
+		changes = append(changes, c)
+	}
+	if len(conflicts) > 0 {
+		for _, path := range conflicts {
+			inform("differs:", path)
+		}
+		panic(errors.New("Bundle Conflicts: " + strconv.Itoa(len(conflicts)) + " files differ from the bundle (use --force to overwrite them)"))
+	}
+	if len(locked) > 0 {
+		for _, path := range locked {
+			inform("locked:", path)
+		}
+		panic(fmt.Errorf("%w: %d logs in the bundle are in locked periods (use --force to restore them anyway)", errLocked, len(locked)))
+	}
+
+	for _, c := range changes {
+		if dryRun {
+			inform("restore:", c.path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+			panic(err)
+		}
+		var err error
+		switch {
+		case c.merged || log(c.path).start() == never || log(c.path).end() == never:
+			err = writeFileAtomic(c.path, c.data, c.f.mode)
+		case c.exists:
+			_, err = rewriteLog(c.path, c.data, opts.has("force"))
+		default:
+			_, err = writeNewLog(c.path, c.data, opts.has("force"))
+		}
+		if err != nil {
+			panic(err)
+		}
+		os.Chtimes(c.path, c.f.modTime, c.f.modTime)
+	}
+	if !dryRun {
+		action := "restore"
+		if forced {
+			action += " --force"
+		}
+		audit(dir, action, in, strconv.Itoa(len(changes)))
+		inform("Restored", len(changes), "files in", filepath.Clean(dir)+",", unchanged, "already up to date")
+	}
+}
+
+//checkBundleVersion returns an error unless a bundled .horolog/version is the version of
+//dir's tree
+func checkBundleVersion(dir string, data []byte) error {
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || v < 1 {
+		return errors.New("Invalid Bundle: invalid format version " + strings.TrimSpace(string(data)))
+	}
+	have, err := treeVersion(dir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v > have:
+		return fmt.Errorf("Bundle Version: the bundle is in format version %d, but %s is in %d (upgrade horolog or migrate the tree first)", v, treeRoot(dir), have)
+	case v < have:
+		return fmt.Errorf("Bundle Version: the bundle is in format version %d, but %s is in %d (restore it into a new tree and migrate that first)", v, treeRoot(dir), have)
+	}
+	return nil
+}
+
+func bundleCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if len(positional) < 2 || (positional[0] != "create" && positional[0] != "restore") {
+		panic(errors.New("Usage: horolog bundle create <file or -> [task], or horolog bundle restore <file or -> [task]"))
+	}
+	dir := "."
+	if len(positional) > 2 {
+		dir = positional[2]
+	}
+	if positional[0] == "create" {
+		bundleCreate(dir, positional[1])
+	} else {
+		bundleRestore(positional[1], dir, opts)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//cacheTTL is how long the output of a summary or query may be reused, from --cache=30s
+//or the cache_ttl setting, or 0 if it should not be cached
+func cacheTTL(t task, opts options) time.Duration {
+	arg := t.setting("cache_ttl", "")
+	if opts.has("cache") {
+		arg = opts.get("cache", "")
+	}
+	if arg == "" {
+		return 0
+	}
+	ttl, err := parseDuration(arg)
+	if err != nil {
+		panic(errors.New("Invalid --cache: " + err.Error()))
+	}
+	return ttl
+}
+
+//treeFingerprint changes whenever a log or task is added, removed or renamed beneath
+//one of the dirs (each of which changes the modification time of a directory), or a
+//config or one of horolog's records changes
+func treeFingerprint(dirs []string) string {
+	h := sha256.New()
+	stamp := func(path string) {
+		if fi, err := os.Stat(path); err == nil {
+			fmt.Fprintln(h, path, fi.ModTime().UnixNano())
+		}
+	}
+	stamp(filepath.Join(configDir(), "config"))
+	for _, dir := range dirs {
+		state := filepath.Join(treeRoot(dir), stateDirName)
+		if files, err := ioutil.ReadDir(state); err == nil {
+			for _, fi := range files {
+				//the lock and the cache itself change without changing any results
+				if fi.Name() != "lock" && fi.Name() != "cache" {
+					stamp(filepath.Join(state, fi.Name()))
+				}
+			}
+		}
+		filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return nil
+			}
+			if path != dir && (strings.HasPrefix(fi.Name(), ".") || task(filepath.Dir(path)).ignores(fi.Name(), true)) {
+				return filepath.SkipDir
+			}
+			stamp(path)
+			stamp(filepath.Join(path, taskConfigName))
+			stamp(filepath.Join(path, ignoreFileName))
+			stamp(filepath.Join(path, logsDirName))
+			return nil
+		})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//cachedOutput returns the output of compute for this command line, reusing the output
+//kept in .horolog/cache if it is less than ttl old and the trees beneath dirs have not
+//changed since. The ttl limits how stale relative periods (e.g. --summary=48h) and
+//notes edited in place (which change no directory) can become.
+func cachedOutput(dirs []string, ttl time.Duration, compute func() string) string {
+	if ttl <= 0 {
+		return compute()
+	}
+	wd, _ := os.Getwd()
+	key := sha256.Sum256([]byte(wd + "\x00" + strings.Join(os.Args[1:], "\x00")))
+	path := filepath.Join(stateDir(dirs[0], "cache"), hex.EncodeToString(key[:8]))
+	fingerprint := treeFingerprint(dirs)
+
+	if b, err := ioutil.ReadFile(path); err == nil {
+		parts := strings.SplitN(string(b), "\n", 3)
+		if len(parts) == 3 && parts[0] == fingerprint {
+			if created, err := strconv.ParseInt(parts[1], 10, 64); err == nil && time.Since(time.Unix(0, created)) < ttl {
+				debug("using cached output", path)
+				return parts[2]
+			}
+		}
+	}
+	output := compute()
+	entry := fingerprint + "\n" + strconv.FormatInt(time.Now().UnixNano(), 10) + "\n" + output
+	if err := writeFileAtomic(path, []byte(entry), 0600); err != nil {
+		debug("cannot cache output:", err)
+	}
+	return output
+}
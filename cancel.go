@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+//ctx is canceled when horolog is interrupted (with Ctrl-C or SIGTERM), so that long
+//scans stop between directories, and files being written are left untouched, rather
+//than the process dying part way through
+var ctx = context.Background()
+
+//stopInterrupts restores the default handling of interrupts, which sessions rely on,
+//as Ctrl-C is the usual way to abandon one
+var stopInterrupts = func() {}
+
+func catchInterrupts() {
+	ctx, stopInterrupts = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+//checkCanceled panics if horolog has been interrupted
+func checkCanceled() {
+	if ctx.Err() != nil {
+		panic(errors.New("Interrupted"))
+	}
+}
+
+//writeFileAtomic writes a file by renaming a temporary file in the same directory over
+//it, so that it is either written completely or not at all
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	checkCanceled()
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
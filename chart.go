@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//a chartBar is the time logged in one day, week or month
+type chartBar struct {
+	start      time.Time
+	value      time.Duration
+	milestones []string
+}
+
+//bucketStart returns the start of the day, week (Monday) or month containing t
+func bucketStart(t time.Time, by string) time.Time {
+	switch by {
+	case "week":
+		return weekStart(t)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func nextBucket(t time.Time, by string) time.Time {
+	switch by {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+//chartBars totals the logs by the day, week or month they started in, including the
+//empty ones between the first and the last, or throughout the period if it has both ends
+func chartBars(ls logs, by string, from, to time.Time) []chartBar {
+	if by != "day" && by != "week" && by != "month" {
+		panic(errors.New("Invalid --by: " + by + " (use day, week or month)"))
+	}
+	totals := map[time.Time]time.Duration{}
+	var first, last time.Time
+	for _, l := range ls {
+		b := bucketStart(l.start(), by)
+		totals[b] += l.duration()
+		if first == never || b.Before(first) {
+			first = b
+		}
+		if b.After(last) {
+			last = b
+		}
+	}
+	if from != never && to != never {
+		first, last = bucketStart(from, by), bucketStart(to.Add(-time.Nanosecond), by)
+	}
+	if first == never {
+		return nil
+	}
+	var answer []chartBar
+	for b := first; !b.After(last); b = nextBucket(b, by) {
+		answer = append(answer, chartBar{start: b, value: totals[b]})
+	}
+	return answer
+}
+
+//asciiChart draws a horizontal bar for each bucket, in eighths of a character, followed by
+//any milestones reached in it
+func asciiChart(bars []chartBar, width int) string {
+	var max time.Duration
+	for _, b := range bars {
+		if b.value > max {
+			max = b.value
+		}
+	}
+	eighths := []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+	answer := ""
+	for _, b := range bars {
+		n := 0
+		if max > 0 {
+			n = int(float64(b.value) / float64(max) * float64(width*8))
+		}
+		bar := strings.Repeat("█", n/8) + eighths[n%8]
+		answer += b.start.Format("2006-01-02") + " " + bar + strings.Repeat(" ", width-len([]rune(bar))) + " " + formatHoursMinutes(b.value)
+		for _, m := range b.milestones {
+			answer += " ◆ " + m
+		}
+		answer += "\n"
+	}
+	return answer
+}
+
+//chartScale returns a round number of hours for the gridlines of a chart whose tallest bar is max
+func chartScale(max time.Duration) float64 {
+	hours := max.Hours()
+	for _, step := range []float64{0.25, 0.5, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000} {
+		if hours/step <= 6 {
+			return step
+		}
+	}
+	return math.Ceil(hours / 6)
+}
+
+//a canvas is an image which charts are drawn on, with text in the block font bigText uses
+type canvas struct {
+	*image.RGBA
+}
+
+func (c canvas) rect(x0, y0, x1, y1 int, col color.Color) {
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			c.Set(x, y, col)
+		}
+	}
+}
+
+//text draws s with its top left corner at x, y, with each pixel of the font scale pixels square
+func (c canvas) text(x, y, scale int, s string, col color.Color) {
+	for _, r := range s {
+		glyph, ok := bigGlyphs[r]
+		if !ok {
+			x += 4 * scale
+			continue
+		}
+		width := 0
+		for row, line := range glyph {
+			for i, px := range []rune(line) {
+				if px != ' ' {
+					c.rect(x+i*scale, y+row*scale, x+(i+1)*scale, y+(row+1)*scale, col)
+				}
+			}
+			if n := len([]rune(line)); n > width {
+				width = n
+			}
+		}
+		x += (width + 1) * scale
+	}
+}
+
+func textWidth(s string, scale int) int {
+	width := 0
+	for _, r := range s {
+		glyph, ok := bigGlyphs[r]
+		if !ok {
+			width += 4 * scale
+			continue
+		}
+		width += (len([]rune(glyph[0])) + 1) * scale
+	}
+	return width
+}
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartInk        = color.RGBA{60, 60, 60, 255}
+	chartGrid       = color.RGBA{225, 225, 225, 255}
+	chartColor      = color.RGBA{74, 144, 217, 255}
+	chartMilestone  = color.RGBA{217, 83, 79, 255}
+)
+
+//pngChart draws the bars vertically, with gridlines labelled in hours and every few
+//bars labelled with the date they start
+func pngChart(w io.Writer, bars []chartBar) error {
+	const scale, height, left, bottom, top = 2, 320, 60, 30, 15
+	barWidth := 600 / len(bars)
+	if barWidth < 4 {
+		barWidth = 4
+	} else if barWidth > 60 {
+		barWidth = 60
+	}
+	width := left + len(bars)*barWidth + 20
+	c := canvas{image.NewRGBA(image.Rect(0, 0, width, height))}
+	c.rect(0, 0, width, height, chartBackground)
+
+	var max time.Duration
+	for _, b := range bars {
+		if b.value > max {
+			max = b.value
+		}
+	}
+	step := chartScale(max)
+	ceiling := math.Ceil(max.Hours()/step) * step
+	if ceiling == 0 {
+		ceiling = step
+	}
+	plot := height - bottom - top
+	y := func(hours float64) int { return height - bottom - int(hours/ceiling*float64(plot)) }
+	for h := 0.0; h <= ceiling; h += step {
+		c.rect(left, y(h), width-10, y(h)+1, chartGrid)
+		label := strconv.FormatFloat(h, 'f', -1, 64) + "h"
+		if strings.Contains(label, ".") {
+			label = formatHoursMinutes(time.Duration(h * float64(time.Hour)))
+		}
+		c.text(left-8-textWidth(label, scale), y(h)-5*scale/2, scale, label, chartInk)
+	}
+
+	labelEvery := int(math.Ceil(float64(textWidth("2006-01-02", scale)+10) / float64(barWidth)))
+	for i, b := range bars {
+		x := left + i*barWidth
+		c.rect(x+1, y(b.value.Hours()), x+barWidth-1, height-bottom, chartColor)
+		if len(b.milestones) > 0 {
+			c.rect(x+barWidth/2-1, top, x+barWidth/2+1, height-bottom, chartMilestone)
+		}
+		if i%labelEvery == 0 && x+textWidth("2006-01-02", scale) < width {
+			c.text(x, height-bottom+8, scale, b.start.Format("2006-01-02"), chartInk)
+		}
+	}
+	c.rect(left, height-bottom, width-10, height-bottom+1, chartInk)
+	return png.Encode(w, c)
+}
+
+//chartCommand plots the time logged in a task over a period, by day, week or month, or
+//as SVG, when in the week it was logged (a heatmap) or how it was shared between subtasks
+func chartCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", ""))
+	if err != nil {
+		panic(err)
+	}
+	ls := t.recursiveLogsMatching(between(from, to))
+	if len(ls) == 0 {
+		panic(errors.New("Nothing to chart in " + t.path()))
+	}
+
+	ms, err := loadMilestones(t)
+	if err != nil {
+		panic(err)
+	}
+	bars := func() []chartBar {
+		by := opts.get("by", "week")
+		return withMilestones(chartBars(ls, by, from, to), ms, by)
+	}
+
+	var b bytes.Buffer
+	kind, format := opts.get("type", "bars"), opts.get("format", "ascii")
+	switch kind + "/" + format {
+	case "bars/ascii":
+		width := 50
+		if opts.has("width") {
+			if width, err = strconv.Atoi(opts.get("width", "")); err != nil || width < 1 {
+				panic(errors.New("Invalid --width: " + opts.get("width", "")))
+			}
+		}
+		fmt.Print(asciiChart(bars(), width))
+		return
+	case "bars/png":
+		err = pngChart(&b, bars())
+	case "bars/svg":
+		err = svgBars(&b, bars())
+	case "heatmap/svg":
+		err = svgHeatmap(&b, ls)
+	case "pie/svg":
+		err = svgPie(&b, taskSlices(t, between(from, to)))
+	default:
+		panic(errors.New("Invalid --type and --format: " + kind + " as " + format + " (use bars as ascii, png or svg, or heatmap or pie as svg)"))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if !opts.has("out") {
+		if src, err := os.Stdout.Stat(); err == nil && src.Mode()&os.ModeCharDevice != 0 && format == "png" {
+			panic(errors.New("Not writing a PNG to the terminal, use --out=chart.png"))
+		}
+		os.Stdout.Write(b.Bytes())
+		return
+	}
+	if err := writeFileAtomic(opts.get("out", ""), b.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+//checkCommand exits 0 if the time logged in a task during a period is within
+//the given thresholds, and 1 otherwise, for use from cron jobs and shell prompts
+func checkCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := opts.get("task", ".")
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	if !opts.has("min") && !opts.has("max") {
+		panic(errors.New("No threshold specified, use --min= and/or --max="))
+	}
+	from, to, err := parsePeriod(opts.get("period", "today"))
+	if err != nil {
+		panic(err)
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	total := t.durationMatching(between(from, to))
+	ok := true
+	if opts.has("min") && total < opts.duration("min") {
+		ok = false
+	}
+	if opts.has("max") && total > opts.duration("max") {
+		ok = false
+	}
+	if verbosity > 0 {
+		fmt.Println(t.path() + ": " + total.String())
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
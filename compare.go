@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+//periodTotals is the time in each task (relative to t) during the period, rolled up to depth if it is not negative
+func periodTotals(t task, period string, depth int) (map[string]time.Duration, error) {
+	from, to, err := parsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	lines := t.summaryLines(between(from, to))
+	if depth >= 0 {
+		lines = rollUp(lines, t, depth)
+	}
+	totals := map[string]time.Duration{}
+	for _, line := range lines {
+		rel, err := filepath.Rel(t.path(), line.task.path())
+		if err != nil {
+			return nil, err
+		}
+		totals[filepath.ToSlash(rel)] += line.logs.duration()
+	}
+	return totals, nil
+}
+
+func compareCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	a, b := opts.get("a", "last-week"), opts.get("b", "this-week")
+	depth := -1
+	if opts.has("depth") {
+		depth, err = strconv.Atoi(opts.get("depth", ""))
+		if err != nil || depth < 0 {
+			panic(errors.New("Invalid --depth: " + opts.get("depth", "")))
+		}
+	}
+	totalsA, err := periodTotals(t, a, depth)
+	if err != nil {
+		panic(err)
+	}
+	totalsB, err := periodTotals(t, b, depth)
+	if err != nil {
+		panic(err)
+	}
+
+	var names []string
+	for name := range totalsA {
+		names = append(names, name)
+	}
+	for name := range totalsB {
+		if _, ok := totalsA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Task\t"+a+"\t"+b+"\tChange")
+	var sumA, sumB time.Duration
+	for _, name := range names {
+		durA, inA := totalsA[name]
+		durB, inB := totalsB[name]
+		sumA += durA
+		sumB += durB
+		switch {
+		case !inA:
+			fmt.Fprintln(w, name+"\t-\t"+durB.String()+"\tnew")
+		case !inB:
+			fmt.Fprintln(w, name+"\t"+durA.String()+"\t-\tdropped")
+		default:
+			fmt.Fprintln(w, name+"\t"+durA.String()+"\t"+durB.String()+"\t"+formatChange(durB-durA))
+		}
+	}
+	fmt.Fprintln(w, "Total\t"+sumA.String()+"\t"+sumB.String()+"\t"+formatChange(sumB-sumA))
+	w.Flush()
+}
+
+//formatChange formats a difference in time with an explicit sign
+func formatChange(d time.Duration) string {
+	if d > 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//config files contain lines of key = value, where values may be quoted and
+//# starts a comment. A [section] line prefixes the keys which follow it, so
+//	[smtp]
+//	host = "mail.example.com"
+//sets smtp.host
+type config map[string]string
+
+//the global config lives in $XDG_CONFIG_HOME/horolog/config (usually ~/.config/horolog/config)
+func configDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "horolog")
+}
+
+//expandHome replaces a leading ~/ in a path from the config with the home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+//taskConfigName is the file in a task's directory which overrides the global config for it and its subtasks
+const taskConfigName = ".horolog.conf"
+
+var globalConfig config
+
+func loadGlobalConfig() config {
+	if globalConfig == nil {
+		globalConfig = loadConfig(filepath.Join(configDir(), "config"))
+	}
+	return globalConfig
+}
+
+//loadConfig returns an empty config if the file does not exist
+func loadConfig(path string) config {
+	c := config{}
+	f, err := os.Open(path)
+	if err != nil {
+		return c
+	}
+	defer f.Close()
+	debug("reading config", path)
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1:len(line)-1]) + "."
+			continue
+		}
+		keyValue := strings.SplitN(line, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		c[section+strings.TrimSpace(keyValue[0])] = unquote(strings.TrimSpace(keyValue[1]))
+	}
+	return c
+}
+
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func (c config) lookup(key string) (string, bool) {
+	value, ok := c[key]
+	return value, ok
+}
+
+func (c config) get(key, def string) string {
+	if value, ok := c[key]; ok {
+		return value
+	}
+	return def
+}
+
+//config returns the settings in the task's own .horolog.conf
+func (t task) config() config {
+	return loadConfig(filepath.Join(t.path(), taskConfigName))
+}
+
+//setting looks up a key in the task's .horolog.conf, then in those of the directories
+//above it, and finally in the global config
+func (t task) setting(key, def string) string {
+	dir, err := filepath.Abs(t.path())
+	if err != nil {
+		dir = t.path()
+	}
+	for {
+		if value, ok := loadConfig(filepath.Join(dir, taskConfigName)).lookup(key); ok {
+			return value
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return loadGlobalConfig().get(key, def)
+}
+
+//splitCommand splits a command line such as code --wait "my file" into arguments,
+//honouring quotes and backslashes the way a shell would
+func splitCommand(command string) []string {
+	var args []string
+	var arg []rune
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			arg = append(arg, r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg = append(arg, r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, string(arg))
+				arg = nil
+				inArg = false
+			}
+		default:
+			arg = append(arg, r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+//user is the author recorded in new logs in the task, from the user setting or $USER.
+//Setting user to an empty string stops logs being attributed at all.
+func (t task) user() string {
+	return t.setting("user", os.Getenv("USER"))
+}
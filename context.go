@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+)
+
+//sessionContext is where a session takes place, e.g. office, home or train, from
+//--context or else the context setting (usually set in each machine's config)
+func sessionContext(t task, opts options) string {
+	if opts.has("context") {
+		return opts.get("context", "")
+	}
+	return t.setting("context", "")
+}
+
+//addFrontMatter sets a key in a note's front matter, adding front matter to the note if
+//it has none, unless the key has already been given (e.g. typed into the note)
+func addFrontMatter(note []byte, key, value string) []byte {
+	fm, body := parseFrontMatter(string(note))
+	if _, ok := fm[key]; ok {
+		return note
+	}
+	line := key + ": " + value + "\n"
+	if len(fm) == 0 {
+		return []byte("---\n" + line + "---\n" + string(note))
+	}
+	//the front matter ends where the body begins
+	header := strings.TrimSuffix(string(note), body)
+	end := strings.LastIndex(strings.TrimSuffix(header, "\n"), "\n") + 1
+	return []byte(header[:end] + line + header[end:] + body)
+}
+
+//removeFrontMatter removes a key from a note's front matter, and the front matter if that
+//was all there was
+func removeFrontMatter(note []byte, key string) []byte {
+	fm, body := parseFrontMatter(string(note))
+	if _, ok := fm[key]; !ok {
+		return note
+	}
+	if len(fm) == 1 {
+		return []byte(body)
+	}
+	header := strings.SplitAfter(strings.TrimSuffix(string(note), body), "\n")
+	var kept []string
+	for _, line := range header {
+		if name := strings.SplitN(line, ":", 2)[0]; strings.ToLower(strings.TrimSpace(name)) != key {
+			kept = append(kept, line)
+		}
+	}
+	return []byte(strings.Join(kept, "") + body)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//logs which count towards more than one task are linked to the log they came from in
+//.horolog/copies, with one tab separated line per copy:
+//	copy|split path original
+//where paths are relative to the root of the tree. A copy duplicates the whole log,
+//so it can be left out of totals with --no-copies, whereas a split takes its time
+//from the original.
+func copiesPath(dir string) string {
+	return filepath.Join(treeRoot(dir), stateDirName, "copies")
+}
+
+//loadCopies returns the tree relative paths of the logs which are copies of others
+func loadCopies(dir string) (map[string]bool, error) {
+	answer := map[string]bool{}
+	f, err := os.Open(copiesPath(dir))
+	if os.IsNotExist(err) {
+		return answer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) == 3 && fields[0] == "copy" {
+			answer[fields[1]] = true
+		}
+	}
+	return answer, scanner.Err()
+}
+
+//notCopies leaves out logs which were copied from another task, so that time is only counted once
+func notCopies() filter {
+	copies := map[string]map[string]bool{}
+	return func(l log) bool {
+		root := treeRoot(l.dir())
+		if _, ok := copies[root]; !ok {
+			c, err := loadCopies(root)
+			if err != nil {
+				panic(err)
+			}
+			copies[root] = c
+		}
+		return !copies[root][treePath(l.path())]
+	}
+}
+
+//parseShare parses a share of a log such as 50% or 0.5
+func parseShare(arg string) (float64, error) {
+	share, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+	if err == nil && strings.HasSuffix(arg, "%") {
+		share /= 100
+	}
+	if err != nil || share <= 0 || share >= 1 {
+		return 0, errors.New("Invalid Share: " + arg + " (expected e.g. 50% or 0.5)")
+	}
+	return share, nil
+}
+
+func copyCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if len(positional) != 2 {
+		panic(errors.New("Usage: horolog copy <log> <task> [--split=50%]"))
+	}
+	l, err := loadLog(positional[0])
+	if err != nil {
+		panic(err)
+	}
+	t, err := loadOrCreateTask(positional[1])
+	if err != nil {
+		panic(err)
+	}
+	note, err := ioutil.ReadFile(l.path())
+	if err != nil {
+		panic(err)
+	}
+	original := task(strings.TrimSuffix(l.dir(), "/"))
+	defer lockTree(t.path())()
+	force := opts.has("force")
+	start, end := l.start(), l.end()
+
+	kind := "copy"
+	if opts.has("split") {
+		share, err := parseShare(opts.get("split", ""))
+		if err != nil {
+			panic(err)
+		}
+		kind = "split"
+		//the original keeps the start of its time, and the copy takes the rest
+		start = end.Add(-time.Duration(float64(l.duration()) * share)).Round(time.Second)
+	}
+	forced := original.checkUnlocked(l.start(), l.end(), force)
+	forced = t.checkUnlocked(start, end, force) || forced
+	suffix := ""
+	if forced {
+		suffix = " --force"
+	}
+
+	copied := t.authoredLogPath(start, end, l.author())
+	if _, err := os.Stat(copied); err == nil {
+		panic(errors.New("Log Already Exists: " + copied))
+	}
+	if kind == "split" {
+		shortened := original.authoredLogPath(l.start(), start, l.author())
+		if _, err := renameLog(l.path(), shortened, force); err != nil {
+			panic(err)
+		}
+		audit(original.path(), "split"+suffix, treePath(l.path()), treePath(shortened))
+		l = log(shortened)
+	}
+	if _, err := writeNewLog(copied, note, force); err != nil {
+		panic(err)
+	}
+	audit(t.path(), kind+suffix, treePath(copied))
+
+	f, err := os.OpenFile(copiesPath(t.path()), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(kind + "\t" + treePath(copied) + "\t" + treePath(l.path()) + "\n"); err != nil {
+		panic(err)
+	}
+	inform(start, end.Sub(start), "\t\t", t.path()+"/")
+}
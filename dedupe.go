@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+//sync tools keep both versions of a file changed on two machines, naming the other one e.g.
+//	name.sync-conflict-20240108-101500-ABCDEFG.txt (Syncthing)
+//	name (conflicted copy 2024-01-08).txt (Dropbox, Nextcloud)
+//	name (conflict copy).txt
+var syncConflictPattern = regexp.MustCompile(`(\.sync-conflict-\d{8}-\d{6}(-[A-Z0-9]+)?| \([^()]*conflict(ed)? copy[^()]*\))(\.[^.]*)?$`)
+
+//syncConflictOriginal returns the name of the file a sync conflict copy was made from
+func syncConflictOriginal(name string) (string, bool) {
+	m := syncConflictPattern.FindStringSubmatchIndex(name)
+	if m == nil {
+		return name, false
+	}
+	ext := ""
+	if m[8] >= 0 {
+		ext = name[m[8]:m[9]]
+	}
+	return name[:m[0]] + ext, true
+}
+
+func sameContents(a, b string) (bool, error) {
+	ba, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	bb, err := ioutil.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ba, bb), nil
+}
+
+//dedupeDir resolves the sync conflict copies in a task's directory, and removes logs which
+//are byte for byte the same as another with the same times, returning how many conflicts
+//differ from their original and need to be merged by hand. Duplicates in locked periods
+//are only removed with force.
+func dedupeDir(t task, dryRun, force bool) (conflicts int) {
+	files, err := ioutil.ReadDir(t.path())
+	if err != nil {
+		panic(err)
+	}
+	removed := map[string]bool{}
+	remove := func(path, kept string) {
+		inform("duplicate:", path, "(keeping "+filepath.Base(kept)+")")
+		removed[path] = true
+		if dryRun {
+			return
+		}
+		//a duplicate has the times of the log it duplicates, even if its own name is that of a conflict copy
+		action := "dedupe"
+		if t.checkUnlocked(log(kept).start(), log(kept).end(), force) {
+			action += " --force"
+		}
+		if _, err := removeLog(path, force); err != nil {
+			panic(err)
+		}
+		audit(t.path(), action, treePath(path), treePath(kept))
+	}
+
+	var names []string
+	for _, fi := range files {
+		if fi.Mode().IsRegular() {
+			names = append(names, fi.Name())
+		}
+	}
+	present := map[string]bool{}
+	for _, name := range names {
+		present[name] = true
+	}
+	var remaining []string
+	conflicted := map[string]bool{}
+	for _, name := range names {
+		checkCanceled()
+		path := filepath.Join(t.path(), name)
+		original, ok := syncConflictOriginal(name)
+		if !ok {
+			remaining = append(remaining, name)
+			continue
+		}
+		originalPath := filepath.Join(t.path(), original)
+		if !present[original] {
+			//the original was removed or renamed on one machine, so the copy takes its place
+			inform("renamed:", path, "to", original)
+			if !dryRun {
+				forced, err := renameLog(path, originalPath, force)
+				if err != nil {
+					panic(err)
+				}
+				action := "dedupe"
+				if forced {
+					action += " --force"
+				}
+				audit(t.path(), action, treePath(path), treePath(originalPath))
+			}
+			present[original] = true
+			remaining = append(remaining, original)
+			continue
+		}
+		same, err := sameContents(path, originalPath)
+		if err != nil {
+			panic(err)
+		}
+		if same {
+			remove(path, originalPath)
+		} else {
+			fmt.Println("conflict:", path, "differs from", original)
+			conflicted[original] = true
+			conflicts++
+		}
+	}
+
+	//logs of the same times with the same note, e.g. copied under both a portable and a
+	//standard name, are the same log, and the oldest file is kept. Originals of conflicts
+	//are left for whoever merges them.
+	byTimes := map[[2]int64][]log{}
+	for _, name := range remaining {
+		if conflicted[name] {
+			continue
+		}
+		if l, err := loadLog(filepath.Join(t.path(), name)); err == nil {
+			key := [2]int64{l.start().Unix(), l.end().Unix()}
+			byTimes[key] = append(byTimes[key], l)
+		}
+	}
+	for _, ls := range byTimes {
+		if len(ls) < 2 {
+			continue
+		}
+		modTime := func(l log) int64 {
+			if fi, err := os.Stat(l.path()); err == nil {
+				return fi.ModTime().UnixNano()
+			}
+			return 0
+		}
+		sort.Slice(ls, func(i, j int) bool {
+			if mi, mj := modTime(ls[i]), modTime(ls[j]); mi != mj {
+				return mi < mj
+			}
+			return ls[i].path() < ls[j].path()
+		})
+		for i := 1; i < len(ls); i++ {
+			for _, kept := range ls[:i] {
+				if removed[kept.path()] {
+					continue
+				}
+				same, err := sameContents(ls[i].path(), kept.path())
+				if err != nil {
+					panic(err)
+				}
+				if same && ls[i].author() == kept.author() {
+					remove(ls[i].path(), kept.path())
+					break
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+//dedupeCommand cleans up the duplicates left by sync tools in a task and its subtasks,
+//exiting with status 1 if there are conflicts which differ from their original
+func dedupeCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	dryRun, force := opts.has("dry-run"), opts.has("force")
+	if !dryRun {
+		defer lockTree(dir)()
+	}
+	conflicts := 0
+	for _, t2 := range append([]task{t}, t.recursiveSubtasks()...) {
+		conflicts += dedupeDir(t2, dryRun, force)
+		if fi, err := os.Stat(t2.path() + "/" + logsDirName); err == nil && fi.IsDir() {
+			conflicts += dedupeDir(task(t2.path()+"/"+logsDirName), dryRun, force)
+		}
+	}
+	if conflicts > 0 {
+		os.Exit(1)
+	}
+}
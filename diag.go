@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"./format"
+)
+
+//verbosity is 0 with --quiet, 2 with --verbose and 1 otherwise. Both options, and
+//--strict, are accepted by every command, and are taken from the arguments before
+//they are parsed.
+var verbosity = 1
+
+//strict makes files which cannot be read as logs an error, rather than skipping them
+var strict bool
+
+//skipped are the files and directories which could not be read, with the reason
+var skipped = map[string]error{}
+
+//skippedMu guards skipped while several trees are read at once
+var skippedMu sync.Mutex
+
+func takeVerbosity(args []string) []string {
+	var answer []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(answer, args[i:]...)
+		}
+		switch arg {
+		case "--verbose":
+			verbosity = 2
+		case "--quiet":
+			verbosity = 0
+		case "--strict":
+			strict = true
+		default:
+			answer = append(answer, arg)
+		}
+	}
+	return answer
+}
+
+//debug prints a diagnostic about what horolog is doing to stderr, with --verbose
+func debug(a ...interface{}) {
+	if verbosity >= 2 {
+		fmt.Fprintln(os.Stderr, append([]interface{}{"horolog:"}, a...)...)
+	}
+}
+
+//inform prints a message which is not the result of a command, such as a
+//confirmation that something was recorded, unless --quiet is given
+func inform(a ...interface{}) {
+	if verbosity >= 1 {
+		fmt.Println(a...)
+	}
+}
+
+//skip records a file or directory in the tree which could not be read
+func skip(path string, err error) {
+	if strict {
+		panic(err)
+	}
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+	if _, ok := skipped[path]; !ok {
+		debug("skipping", err)
+		skipped[path] = err
+	}
+}
+
+//logNameStart is the date at the start of every log's name
+var logNameStart = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}`)
+
+//looksLikeLog reports whether a file which could not be loaded was probably meant to be a
+//log, rather than something else kept alongside them such as a README or, where a task
+//doubles as a project directory, the project's files
+func looksLikeLog(name string) bool {
+	return logNameStart.MatchString(name) || strings.Contains(name, format.TimeDelimiter)
+}
+
+//warnSkipped tells the user how many files were left out of the results
+func warnSkipped() {
+	if len(skipped) > 0 && verbosity >= 1 {
+		fmt.Fprintln(os.Stderr, "horolog:", len(skipped), "files skipped (see --verbose, or use --strict to stop at the first)")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//digestNotes is how many of the longest logs have their notes included in a digest
+const digestNotes = 10
+
+//digestText is the summary of the period followed by the notes of its longest logs
+func digestText(f filter, opts options, positional []string) string {
+	text := summaryText(f, opts, positional)
+	var ls logs
+	for _, r := range summaryRoots(opts, positional) {
+		ls = append(ls, r.task.recursiveLogsMatching(f)...)
+	}
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i].duration() > ls[j].duration() })
+	if len(ls) > digestNotes {
+		ls = ls[:digestNotes]
+	}
+
+	text += "Top notes:\n\n"
+	for _, l := range ls {
+		note := strings.TrimSpace(l.text())
+		if note == "" {
+			continue
+		}
+		text += l.start().Format("Mon 2006-01-02 15:04") + " " + l.duration().String() + " " + filepath.Clean(l.dir()) + "\n"
+		text += "\t" + strings.Replace(note, "\n", "\n\t", -1) + "\n\n"
+	}
+	return text
+}
+
+//sendMail sends a plain text email using the [smtp] section of the config:
+//host, port (default 587), username, password and from
+func sendMail(to, subject, body string) error {
+	c := loadGlobalConfig()
+	host := c.get("smtp.host", "")
+	if host == "" {
+		return errors.New("No SMTP server configured, set host in the [smtp] section of the config")
+	}
+	from := c.get("smtp.from", c.get("smtp.username", os.Getenv("USER")))
+	var auth smtp.Auth
+	if username := c.get("smtp.username", ""); username != "" {
+		auth = smtp.PlainAuth("", username, c.get("smtp.password", ""), host)
+	}
+
+	msg := "From: " + from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + strings.Replace(body, "\n", "\r\n", -1)
+	return smtp.SendMail(host+":"+c.get("smtp.port", "587"), auth, from, strings.Split(to, ","), []byte(msg))
+}
+
+func digestCommand(args []string) {
+	opts, positional := parseOptions(args)
+	period := opts.get("period", "last-week")
+	from, to, err := parsePeriod(period)
+	if err != nil {
+		panic(err)
+	}
+	text := digestText(between(from, to), opts, positional)
+
+	if !opts.has("mailto") {
+		fmt.Print(text)
+		return
+	}
+	err = sendMail(opts.get("mailto", ""), "horolog digest: "+period, text)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+//estimate is the time the task was expected to take, from the estimate in its own
+//.horolog.conf (estimates are not inherited by subtasks), and whether it has one
+func (t task) estimate() (time.Duration, bool) {
+	value, ok := t.config().lookup("estimate")
+	if !ok {
+		return 0, false
+	}
+	d, err := parseDuration(value)
+	if err != nil {
+		panic(errors.New("Invalid estimate in " + t.path() + ": " + err.Error()))
+	}
+	return d, true
+}
+
+//variance formats how far actual was from an estimate, as a percentage of the estimate
+func variance(actual, estimate time.Duration) string {
+	if estimate == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.0f%%", 100*float64(actual-estimate)/float64(estimate))
+}
+
+//estimateReportCommand compares the time logged in each task which has an estimate (with
+//its subtasks) to the estimate, so that future estimates can be corrected
+func estimateReportCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "all"))
+	if err != nil {
+		panic(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	var sumEstimate, sumActual time.Duration
+	count := 0
+	for _, t2 := range append([]task{t}, t.recursiveSubtasks()...) {
+		estimate, ok := t2.estimate()
+		if !ok {
+			continue
+		}
+		actual := t2.durationMatching(between(from, to))
+		rel, err := filepath.Rel(t.path(), t2.path())
+		if err != nil {
+			rel = t2.path()
+		}
+		if count == 0 {
+			fmt.Fprintln(w, "Task\tEstimate\tActual\tDifference\tVariance")
+		}
+		count++
+		fmt.Fprintln(w, filepath.ToSlash(rel)+"\t"+estimate.String()+"\t"+actual.String()+"\t"+formatChange(actual-estimate)+"\t"+variance(actual, estimate))
+		//estimates of subtasks are usually part of their parent's, so only the outermost count towards the total
+		if !t2.hasEstimateAbove(t) {
+			sumEstimate += estimate
+			sumActual += actual
+		}
+	}
+	if count == 0 {
+		inform("No tasks have an estimate (set estimate = \"12h\" in a task's .horolog.conf)")
+		return
+	}
+	fmt.Fprintln(w, "Total\t"+sumEstimate.String()+"\t"+sumActual.String()+"\t"+formatChange(sumActual-sumEstimate)+"\t"+variance(sumActual, sumEstimate))
+	w.Flush()
+	if sumEstimate > 0 {
+		fmt.Printf("\nTasks took %.2f times their estimates\n", float64(sumActual)/float64(sumEstimate))
+	}
+}
+
+//hasEstimateAbove reports whether a task between t and root (not including either) has an estimate
+func (t task) hasEstimateAbove(root task) bool {
+	for path := parentPath(t.path(), root.path()); path != "" && path != root.path(); path = parentPath(path, root.path()) {
+		if _, ok := task(path).estimate(); ok {
+			return true
+		}
+	}
+	_, ok := root.estimate()
+	return ok && t != root
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//an exportRow is one log, as it appears in every export format
+type exportRow struct {
+	Task     string            `json:"task"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Seconds  int64             `json:"duration_seconds"`
+	Author   string            `json:"author"`
+	Note     string            `json:"note"`
+	Filename string            `json:"filename"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Group    string            `json:"group,omitempty"`
+
+	//settings are those of the log's task, for the accounting formats
+	settings exportSettings
+}
+
+//exportSettings are the settings of a log's task used by the accounting formats
+type exportSettings struct {
+	client   string
+	service  string
+	rate     float64
+	currency string
+	billable bool
+}
+
+//an exporter writes rows in some format
+type exporter func(w io.Writer, rows []exportRow) error
+
+var exporters = map[string]exporter{
+	"csv":            exportCSV,
+	"json":           exportJSON,
+	"sql":            exportSQL,
+	"iif":            exportIIF,
+	"quickbooks-csv": exportQuickBooksCSV,
+	"lexoffice":      exportLexoffice,
+	"timeclock":      exportTimeclock,
+	"grid":           exportGrid,
+}
+
+//registerExporter adds an export format. horolog is a command rather than a library, so
+//formats of one's own (e.g. for an in-house ERP) are added in a file of their own which
+//registers them in init, perhaps behind a build tag so that they are only built with
+//go build -tags erp, rather than by changing the formats here. Programs which cannot be
+//built into horolog can be plugins instead (see plugins).
+func registerExporter(name string, e exporter) {
+	if _, ok := exporters[name]; ok {
+		panic(errors.New("Export format registered twice: " + name))
+	}
+	exporters[name] = e
+}
+
+//groupRows repeats each row for each group its log is in (see groupings), ordered by group
+func groupRows(t task, rows []exportRow, name string) []exportRow {
+	g := grouping(name)
+	var answer []exportRow
+	for _, r := range rows {
+		keys := g(log(filepath.Join(t.path(), r.Task, r.Filename)))
+		if len(keys) == 0 {
+			keys = []string{""}
+		}
+		for _, key := range keys {
+			r.Group = key
+			answer = append(answer, r)
+		}
+	}
+	sort.SliceStable(answer, func(i, j int) bool { return answer[i].Group < answer[j].Group })
+	return answer
+}
+
+func exportRows(t task, f filter) ([]exportRow, error) {
+	ls := t.recursiveLogsMatching(f)
+	sort.Sort(logsByStart(ls))
+	var rows []exportRow
+	settings := map[string]exportSettings{}
+	for _, l := range ls {
+		rel, err := filepath.Rel(t.path(), l.dir())
+		if err != nil {
+			return nil, err
+		}
+		s, ok := settings[l.dir()]
+		if !ok {
+			lt := task(strings.TrimSuffix(l.dir(), "/"))
+			s = exportSettings{
+				client:   lt.setting("client", ""),
+				service:  lt.setting("service_item", filepath.ToSlash(rel)),
+				currency: strings.ToUpper(lt.setting("currency", "EUR")),
+				billable: lt.setting("billable", "false") == "true",
+			}
+			if s.rate, err = strconv.ParseFloat(lt.setting("rate", "0"), 64); err != nil {
+				return nil, errors.New("Invalid rate for " + lt.path() + ": " + lt.setting("rate", ""))
+			}
+			settings[l.dir()] = s
+		}
+		fm, note := parseFrontMatter(l.text())
+		if b := fm.get("billable"); b != "" {
+			s.billable = b == "true" || b == "yes"
+		}
+		rows = append(rows, exportRow{
+			Task:     filepath.ToSlash(rel),
+			Start:    l.start(),
+			End:      l.end(),
+			Seconds:  int64(l.duration() / time.Second),
+			Author:   l.author(),
+			Note:     note,
+			Filename: filepath.Base(l.path()),
+			Meta:     fm.flatten(),
+			settings: s,
+		})
+	}
+	return rows, nil
+}
+
+var exportColumns = []string{"task", "start", "end", "duration_seconds", "author", "note", "filename", "meta", "group"}
+
+//metaJSON is the front matter as a JSON object, for formats without nested values, or "" if there is none
+func (r exportRow) metaJSON() string {
+	if len(r.Meta) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(r.Meta)
+	return string(b)
+}
+
+func (r exportRow) values() []string {
+	return []string{r.Task, r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), strconv.FormatInt(r.Seconds, 10), r.Author, r.Note, r.Filename, r.metaJSON(), r.Group}
+}
+
+func exportCSV(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	for _, r := range rows {
+		cw.Write(r.values())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//exportJSON writes one JSON object per line, which DuckDB reads with read_json_auto
+func exportJSON(w io.Writer, rows []exportRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+//exportSQL writes statements creating and filling a logs table, which sqlite3 and duckdb can both run
+func exportSQL(w io.Writer, rows []exportRow) error {
+	_, err := fmt.Fprint(w, "CREATE TABLE IF NOT EXISTS logs (\n"+
+		"\ttask TEXT NOT NULL,\n"+
+		"\tstart TIMESTAMP NOT NULL,\n"+
+		"\t\"end\" TIMESTAMP NOT NULL,\n"+
+		"\tduration_seconds INTEGER NOT NULL,\n"+
+		"\tauthor TEXT,\n"+
+		"\tnote TEXT,\n"+
+		"\tfilename TEXT,\n"+
+		"\tmeta TEXT,\n"+
+		"\t\"group\" TEXT\n"+
+		");\n")
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		_, err = fmt.Fprintf(w, "INSERT INTO logs VALUES (%s, %s, %s, %d, %s, %s, %s, %s, %s);\n",
+			sqlString(r.Task), sqlString(r.Start.UTC().Format("2006-01-02 15:04:05")), sqlString(r.End.UTC().Format("2006-01-02 15:04:05")),
+			r.Seconds, sqlString(r.Author), sqlString(r.Note), sqlString(r.Filename), sqlString(r.metaJSON()), sqlString(r.Group))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	format := opts.get("format", "csv")
+	export, ok := exporters[format]
+	if !ok {
+		var formats []string
+		for name := range exporters {
+			formats = append(formats, name)
+		}
+		sort.Strings(formats)
+		panic(errors.New("Unknown export format: " + format + " (available: " + strings.Join(formats, ", ") + ")"))
+	}
+	from, to, err := parsePeriod(opts.get("period", ""))
+	if err != nil {
+		panic(err)
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	rows, err := exportRows(t, between(from, to))
+	if err != nil {
+		panic(err)
+	}
+	if opts.has("group-by") {
+		rows = groupRows(t, rows, opts.get("group-by", ""))
+	}
+	if opts.has("anonymize") {
+		rows = anonymizer(opts.get("salt", "")).anonymize(rows)
+	}
+
+	if !opts.has("out") {
+		err = export(os.Stdout, rows)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+	var b bytes.Buffer
+	err = export(&b, rows)
+	if err == nil {
+		err = writeFileAtomic(opts.get("out", ""), b.Bytes(), 0644)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
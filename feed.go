@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//feedEntries is the most entries a feed will contain
+const feedEntries = 50
+
+//feedExcerpt is how much of a note is included in a feed entry
+const feedExcerpt = 280
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Summary string      `xml:"summary"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+func excerpt(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) <= length {
+		return text
+	}
+	return string([]rune(text)[:length]) + "…"
+}
+
+//feed is an Atom feed of the most recent of the given logs in the task
+func (t task) feed(ls logs) ([]byte, error) {
+	sort.Sort(sort.Reverse(logsByEnd(ls)))
+	if len(ls) > feedEntries {
+		ls = ls[:feedEntries]
+	}
+
+	abs, err := filepath.Abs(t.path())
+	if err != nil {
+		return nil, err
+	}
+	feed := atomFeed{
+		Title:   "horolog: " + filepath.Base(abs),
+		ID:      "file://" + (&url.URL{Path: abs}).EscapedPath(),
+		Updated: time.Now().Format(time.RFC3339),
+		Author:  atomPerson{Name: t.user()},
+	}
+	if feed.Author.Name == "" {
+		feed.Author.Name = "horolog"
+	}
+	if len(ls) > 0 {
+		feed.Updated = ls[0].end().Format(time.RFC3339)
+	}
+	for _, l := range ls {
+		rel, err := filepath.Rel(t.path(), l.dir())
+		if err != nil {
+			return nil, err
+		}
+		entry := atomEntry{
+			Title:   rel + " (" + l.duration().String() + ")",
+			ID:      feed.ID + "/" + (&url.URL{Path: filepath.ToSlash(filepath.Join(rel, l.name()))}).EscapedPath(),
+			Updated: l.end().Format(time.RFC3339),
+			Summary: l.start().Format("Mon 2006-01-02 15:04"),
+		}
+		if l.author() != "" {
+			entry.Author = &atomPerson{Name: l.author()}
+		}
+		if note := excerpt(l.text(), feedExcerpt); note != "" {
+			entry.Summary += " – " + note
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	b, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+func feedCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "30d"))
+	if err != nil {
+		panic(err)
+	}
+	b, err := t.feed(t.recursiveLogsMatching(between(from, to)))
+	if err != nil {
+		panic(err)
+	}
+	if opts.has("out") {
+		err = writeFileAtomic(opts.get("out", ""), b, 0644)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+	fmt.Print(string(b))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//lockTree takes an advisory lock on dir's tree (.horolog/lock) so that processes changing
+//it, e.g. an amend from cron during an interactive session, do not race each other.
+//It waits for up to the lock_timeout setting for another process to finish, and
+//returns a function which releases the lock. Trees in another format version than
+//this horolog's are refused.
+func lockTree(dir string) func() {
+	release := takeTreeLock(dir)
+	defer func() {
+		if r := recover(); r != nil {
+			release()
+			panic(r)
+		}
+	}()
+	checkVersion(dir)
+	return release
+}
+
+//takeTreeLock is lockTree whatever the tree's version, for migrate
+func takeTreeLock(dir string) func() {
+	timeout, err := parseDuration(task(dir).setting("lock_timeout", "10s"))
+	if err != nil {
+		panic(errors.New("Invalid lock_timeout: " + err.Error()))
+	}
+	path := filepath.Join(stateDir(dir), "lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		panic(err)
+	}
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err = lockFile(f)
+		if err == nil {
+			break
+		}
+		if !lockBusy(err) || time.Now().After(deadline) {
+			f.Close()
+			if lockBusy(err) {
+				holder, _ := ioutil.ReadFile(path)
+				err = errors.New("Tree Locked: " + path + " is held by pid " + strings.TrimSpace(string(holder)))
+			}
+			panic(err)
+		}
+		if !waiting {
+			holder, _ := ioutil.ReadFile(path)
+			debug("waiting for", path, "held by pid", strings.TrimSpace(string(holder)))
+			waiting = true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return func() {
+		f.Truncate(0)
+		unlockFile(f)
+		f.Close()
+	}
+}
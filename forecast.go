@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+//forecastCommand projects the time logged in a task by the end of a period from
+//the rate so far, and exits 1 if that would exceed the task's budget
+func forecastCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "this-month"))
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	if from == never || to == never || !now.After(from) {
+		panic(errors.New("Cannot forecast a period which is open ended or has not started"))
+	}
+
+	//the budget is not inherited, as it would not make sense for each subtask to share it
+	budget := opts.duration("budget")
+	if !opts.has("budget") {
+		if b := t.config().get("budget", ""); b != "" {
+			budget, err = parseDuration(b)
+			if err != nil {
+				panic(errors.New("Invalid budget in " + t.path() + ": " + err.Error()))
+			}
+		}
+	}
+
+	total := t.durationMatching(between(from, to))
+	elapsed := now.Sub(from)
+	if now.After(to) {
+		elapsed = to.Sub(from)
+	}
+	perDay := time.Duration(float64(total) * float64(24*time.Hour) / float64(elapsed))
+	projected := time.Duration(float64(total) * float64(to.Sub(from)) / float64(elapsed))
+	fmt.Println("Logged:", total.Round(time.Minute).String())
+	fmt.Println("Rate:", perDay.Round(time.Minute).String(), "per day")
+	fmt.Println("Projected:", projected.Round(time.Minute).String())
+	if budget > 0 {
+		fmt.Println("Budget:", budget.String(), "("+percent(total, budget)+" used)")
+		if projected > budget {
+			fmt.Println("On track to exceed the budget by", (projected - budget).Round(time.Minute).String())
+			os.Exit(1)
+		}
+	}
+}
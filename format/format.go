@@ -0,0 +1,69 @@
+//Package format encodes and decodes the names of horolog's logs, which are the only place
+//their times are kept, so that other tools reading or writing a tree can rely on them as
+//much as horolog does. FORMAT.md specifies them, along with the rest of a tree.
+package format
+
+import (
+	"strings"
+	"time"
+)
+
+//Version is the version of the specification in FORMAT.md. It is raised only when a tree
+//written by this version could be misread by an earlier one.
+const Version = 1
+
+const TimeLayout = "2006-01-02 15:04:05-07:00"
+const TimeDelimiter = "=>"
+const AuthorDelimiter = "@"
+
+//portable names avoid the characters which Android shared storage, FAT and Windows reject
+const PortableTimeLayout = "2006-01-02 15.04.05-0700"
+const PortableTimeDelimiter = " to "
+
+//EncodeName is the name of a log, without its extension. Characters in the author which
+//would make the name ambiguous are replaced with _.
+func EncodeName(start, end time.Time, author string, portable bool) string {
+	name := start.Format(TimeLayout) + TimeDelimiter + end.Format(TimeLayout)
+	if portable {
+		name = start.Format(PortableTimeLayout) + PortableTimeDelimiter + end.Format(PortableTimeLayout)
+	}
+	if author != "" {
+		name += AuthorDelimiter + SanitizeAuthor(author)
+	}
+	return name
+}
+
+//SanitizeAuthor is the author as EncodeName writes it
+func SanitizeAuthor(author string) string {
+	return strings.NewReplacer("/", "_", AuthorDelimiter, "_").Replace(author)
+}
+
+//DecodeName parses a log name without its extension, in either form. The author is
+//returned even if the times are invalid, in which case ok is false and any which are invalid are zero.
+func DecodeName(name string) (start, end time.Time, author string, ok bool) {
+	nameSplit := strings.SplitN(name, AuthorDelimiter, 2)
+	if len(nameSplit) == 2 {
+		author = nameSplit[1]
+	}
+	times := strings.SplitN(nameSplit[0], TimeDelimiter, 2)
+	if len(times) < 2 {
+		times = strings.SplitN(nameSplit[0], PortableTimeDelimiter, 2)
+	}
+	if len(times) < 2 {
+		return time.Time{}, time.Time{}, author, false
+	}
+	start, okStart := parseTime(times[0])
+	end, okEnd := parseTime(times[1])
+	return start, end, author, okStart && okEnd
+}
+
+//parseTime parses a time in either layout
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{TimeLayout, PortableTimeLayout} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,139 @@
+package format
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testZones = []*time.Location{
+	time.UTC,
+	time.FixedZone("", 60*60),
+	time.FixedZone("", -8*60*60),
+	time.FixedZone("", 5*60*60+30*60),
+	time.FixedZone("", -(3*60*60 + 30*60)),
+	time.FixedZone("", 14*60*60),
+}
+
+//sameInstantAndOffset reports whether two times are the same instant written the same way
+func sameInstantAndOffset(a, b time.Time) bool {
+	_, offsetA := a.Zone()
+	_, offsetB := b.Zone()
+	return a.Equal(b) && offsetA == offsetB
+}
+
+func TestNameRoundTrip(t *testing.T) {
+	authors := map[string]string{
+		"":          "",
+		"alice":     "alice",
+		"Bob Smith": "Bob Smith",
+		"a/b@c":     "a_b_c",
+		"josé":      "josé",
+	}
+	for _, zone := range testZones {
+		start := time.Date(2017, 1, 10, 17, 31, 4, 0, zone)
+		//across midnight, and across a change of offset
+		for _, end := range []time.Time{start.Add(4 * time.Second), start.Add(8 * time.Hour), start.Add(time.Hour).In(time.FixedZone("", 2*60*60))} {
+			for author, want := range authors {
+				for _, portable := range []bool{false, true} {
+					name := EncodeName(start, end, author, portable)
+					gotStart, gotEnd, gotAuthor, ok := DecodeName(name)
+					if !ok || !sameInstantAndOffset(gotStart, start) || !sameInstantAndOffset(gotEnd, end) || gotAuthor != want {
+						t.Errorf("DecodeName(%q) = %v, %v, %q, %v; want %v, %v, %q", name, gotStart, gotEnd, gotAuthor, ok, start, end, want)
+					}
+					if portable && strings.ContainsAny(name, `:>/\`) {
+						t.Errorf("portable name %q has a character Android, FAT or Windows reject", name)
+					}
+				}
+			}
+		}
+	}
+}
+
+//TestFormatExamples decodes the examples in FORMAT.md
+func TestFormatExamples(t *testing.T) {
+	zone := time.FixedZone("", 60*60)
+	start := time.Date(2017, 1, 10, 17, 31, 4, 0, zone)
+	end := time.Date(2017, 1, 10, 17, 31, 8, 0, zone)
+	for _, name := range []string{
+		"2017-01-10 17:31:04+01:00=>2017-01-10 17:31:08+01:00@alice",
+		"2017-01-10 17.31.04+0100 to 2017-01-10 17.31.08+0100@alice",
+		//either layout on each side of either delimiter
+		"2017-01-10 17:31:04+01:00 to 2017-01-10 17.31.08+0100@alice",
+		"2017-01-10 17.31.04+0100=>2017-01-10 17:31:08+01:00@alice",
+	} {
+		gotStart, gotEnd, author, ok := DecodeName(name)
+		if !ok || !sameInstantAndOffset(gotStart, start) || !sameInstantAndOffset(gotEnd, end) || author != "alice" {
+			t.Errorf("DecodeName(%q) = %v, %v, %q, %v", name, gotStart, gotEnd, author, ok)
+		}
+	}
+}
+
+func TestDecodeInvalidNames(t *testing.T) {
+	for _, name := range []string{
+		"",
+		"README",
+		"2017-01-10 17:31:04+01:00",
+		"2017-01-10 17:31:04+01:00=>",
+		"2017-01-10 17:31:04+01:00=>tomorrow",
+		"2017-01-10 17:31:04=>2017-01-10 17:31:08",
+		"2017-01-10 17:31:04+01:00 - 2017-01-10 17:31:08+01:00",
+		"@2017-01-10 17:31:04+01:00=>2017-01-10 17:31:08+01:00",
+	} {
+		if _, _, _, ok := DecodeName(name); ok {
+			t.Errorf("DecodeName(%q) is ok", name)
+		}
+	}
+	if _, _, author, _ := DecodeName("notes@alice"); author != "alice" {
+		t.Errorf("the author of an invalid name is %q rather than alice", author)
+	}
+}
+
+//FuzzNameRoundTrip checks that decoding an encoded name gives back its times and author,
+//which only loses the characters of the author which writers replace
+func FuzzNameRoundTrip(f *testing.F) {
+	f.Add(int64(1484065864), int64(4), 60, "alice", false)
+	f.Add(int64(1484065864), int64(86400), -480, "a/b@c", true)
+	f.Add(int64(0), int64(0), 0, "", false)
+	f.Add(int64(253402041599), int64(0), 840, "=> to @", true)
+	f.Fuzz(func(t *testing.T, startUnix, seconds int64, offsetMinutes int, author string, portable bool) {
+		//names hold times to the second in four digit years, with offsets of whole minutes
+		const first, last = -62135596800, 253402300799 //0001-01-01 to 9999-12-31
+		if startUnix < first+2*86400 || startUnix > last-2*86400 || seconds < 0 || seconds > 86400 || offsetMinutes <= -24*60 || offsetMinutes >= 24*60 {
+			t.Skip()
+		}
+		zone := time.FixedZone("", offsetMinutes*60)
+		start := time.Unix(startUnix, 0).In(zone)
+		end := start.Add(time.Duration(seconds) * time.Second)
+		name := EncodeName(start, end, author, portable)
+		gotStart, gotEnd, gotAuthor, ok := DecodeName(name)
+		want := SanitizeAuthor(author)
+		if !ok || !sameInstantAndOffset(gotStart, start) || !sameInstantAndOffset(gotEnd, end) || gotAuthor != want {
+			t.Errorf("DecodeName(%q) = %v, %v, %q, %v; want %v, %v, %q", name, gotStart, gotEnd, gotAuthor, ok, start, end, want)
+		}
+		if EncodeName(gotStart, gotEnd, gotAuthor, portable) != name {
+			t.Errorf("%q is not encoded the same way again", name)
+		}
+	})
+}
+
+//FuzzDecodeName checks that any name which decodes is encoded again with the same times,
+//to the second, in the same form
+func FuzzDecodeName(f *testing.F) {
+	f.Add("2017-01-10 17:31:04+01:00=>2017-01-10 17:31:08+01:00@alice")
+	f.Add("2017-01-10 17.31.04+0100 to 2017-01-10 17.31.08+0100")
+	f.Add("2017-01-10 17:31:04+01:00 to 2017-01-10 17.31.08+0100@a@b/c")
+	f.Add("README")
+	f.Fuzz(func(t *testing.T, name string) {
+		start, end, author, ok := DecodeName(name)
+		if !ok {
+			return
+		}
+		portable := !strings.Contains(strings.SplitN(name, AuthorDelimiter, 2)[0], TimeDelimiter)
+		again := EncodeName(start, end, author, portable)
+		gotStart, gotEnd, gotAuthor, ok := DecodeName(again)
+		if !ok || !gotStart.Equal(start.Truncate(time.Second)) || !gotEnd.Equal(end.Truncate(time.Second)) || gotAuthor != SanitizeAuthor(author) {
+			t.Errorf("%q is encoded again as %q, which decodes to %v, %v, %q, %v", name, again, gotStart, gotEnd, gotAuthor, ok)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+)
+
+//a note may begin with a block of YAML front matter, which gives a log structure
+//without changing its name, e.g.
+//	---
+//	tags: [review, urgent]
+//	issues:
+//	  - ACME-12
+//	billable: false
+//	location: office
+//	---
+//	Reviewed the release
+//only the common subset of YAML is understood: keys with a value, a [list], or a list of
+//- items on the following lines. Keys are case insensitive.
+type frontMatter map[string][]string
+
+//parseFrontMatter splits a note into its front matter, if any, and the rest of the note
+func parseFrontMatter(note string) (frontMatter, string) {
+	fm := frontMatter{}
+	normalized := strings.Replace(note, "\r\n", "\n", -1)
+	if !strings.HasPrefix(normalized, "---\n") {
+		return fm, note
+	}
+	lines := strings.Split(normalized, "\n")
+	key := ""
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case line == "---" || line == "...":
+			return fm, strings.Join(lines[i+1:], "\n")
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "- ") && key != "":
+			fm[key] = append(fm[key], yamlScalar(trimmed[2:]))
+		default:
+			keyValue := strings.SplitN(trimmed, ":", 2)
+			if len(keyValue) != 2 {
+				//not front matter after all, just a note which starts with a rule
+				return frontMatter{}, note
+			}
+			key = strings.ToLower(strings.TrimSpace(keyValue[0]))
+			value := strings.TrimSpace(keyValue[1])
+			fm[key] = nil
+			if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+				for _, item := range strings.Split(value[1:len(value)-1], ",") {
+					if item = yamlScalar(item); item != "" {
+						fm[key] = append(fm[key], item)
+					}
+				}
+			} else if value != "" {
+				fm[key] = []string{yamlScalar(value)}
+			}
+		}
+	}
+	//an unterminated block is part of the note
+	return frontMatter{}, note
+}
+
+//yamlScalar removes the quotes and trailing comment from a value
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+//get returns the values of a key, separated by commas
+func (fm frontMatter) get(key string) string {
+	return strings.Join(fm[strings.ToLower(key)], ", ")
+}
+
+//flatten returns each key with its values separated by commas, or nil if there are none
+func (fm frontMatter) flatten() map[string]string {
+	if len(fm) == 0 {
+		return nil
+	}
+	answer := map[string]string{}
+	for key := range fm {
+		answer[key] = fm.get(key)
+	}
+	return answer
+}
+
+//frontMatter returns the front matter of the log's note
+func (l log) frontMatter() frontMatter {
+	fm, _ := parseFrontMatter(l.text())
+	return fm
+}
+
+//note returns the log's note without its front matter
+func (l log) note() string {
+	_, note := parseFrontMatter(l.text())
+	return note
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//exportGrid writes a timesheet grid like those of Tempo or Workday: a row for each task
+//(or group, with --group-by) and a column for each day of the weeks the logs fall in,
+//holding decimal hours, with totals. Logs which run past midnight count in both days.
+func exportGrid(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	if len(rows) == 0 {
+		cw.Flush()
+		return cw.Error()
+	}
+	first, last := rows[0].Start, rows[0].End
+	for _, r := range rows {
+		if r.Start.Before(first) {
+			first = r.Start
+		}
+		if r.End.After(last) {
+			last = r.End
+		}
+	}
+	var days []time.Time
+	for day := weekStart(first); day.Before(last) || len(days)%7 != 0; day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	//with --group-by a log is repeated for each of its groups, but only counted once in the totals
+	cells := map[string][]time.Duration{}
+	totals := make([]time.Duration, len(days))
+	counted := map[string]bool{}
+	var names []string
+	for _, r := range rows {
+		name := r.Task
+		if r.Group != "" {
+			name = r.Group
+		}
+		if cells[name] == nil {
+			cells[name] = make([]time.Duration, len(days))
+			names = append(names, name)
+		}
+		key := r.Task + "/" + r.Filename
+		for i, day := range days {
+			from, to := day, day.AddDate(0, 0, 1)
+			if r.Start.After(from) {
+				from = r.Start
+			}
+			if r.End.Before(to) {
+				to = r.End
+			}
+			if to.After(from) {
+				cells[name][i] += to.Sub(from)
+				if !counted[key] {
+					totals[i] += to.Sub(from)
+				}
+			}
+		}
+		counted[key] = true
+	}
+	sort.Strings(names)
+
+	hours := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+	}
+	header := []string{"Task"}
+	for _, day := range days {
+		header = append(header, day.Format("Mon 2006-01-02"))
+	}
+	cw.Write(append(header, "Total"))
+	for _, name := range names {
+		line := []string{name}
+		var sum time.Duration
+		for _, d := range cells[name] {
+			line = append(line, hours(d))
+			sum += d
+		}
+		cw.Write(append(line, hours(sum)))
+	}
+	line := []string{"Total"}
+	var total time.Duration
+	for _, d := range totals {
+		line = append(line, hours(d))
+		total += d
+	}
+	cw.Write(append(line, hours(total)))
+	cw.Flush()
+	return cw.Error()
+}
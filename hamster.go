@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//hamsterQuery lists the facts in a Hamster (GNOME Time Tracker) database, with their
+//category and activity, which become the task, and their tags
+const hamsterQuery = `SELECT f.start_time, f.end_time, COALESCE(c.name, ''), a.name, COALESCE(f.description, ''),
+	COALESCE((SELECT GROUP_CONCAT(t.name, ' ') FROM fact_tags ft JOIN tags t ON t.id = ft.tag_id WHERE ft.fact_id = f.id), '')
+FROM facts f JOIN activities a ON a.id = f.activity_id LEFT JOIN categories c ON c.id = a.category_id
+WHERE f.end_time IS NOT NULL ORDER BY f.start_time`
+
+//importHamster reads a Hamster database (usually ~/.local/share/hamster-applet/hamster.db)
+//using the sqlite3 command. Facts are filed under category/activity, with their description
+//as the note followed by their tags.
+func importHamster(path string, opts options) ([]importedLog, error) {
+	if path == "-" {
+		return nil, errors.New("hamster databases cannot be read from stdin")
+	}
+	out, err := exec.Command("sqlite3", "-readonly", "-csv", expandHome(path), hamsterQuery).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, errors.New("sqlite3 could not read " + path + ": " + strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return nil, errors.New("importing from hamster requires sqlite3: " + err.Error())
+	}
+	rows, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var answer []importedLog
+	for _, row := range rows {
+		if len(row) != 6 {
+			return nil, errors.New("Invalid Hamster Fact: " + strings.Join(row, ","))
+		}
+		l := importedLog{task: row[2] + "/" + row[3], note: row[4]}
+		if l.start, err = time.ParseInLocation("2006-01-02 15:04:05", row[0], time.Local); err != nil {
+			return nil, err
+		}
+		if l.end, err = time.ParseInLocation("2006-01-02 15:04:05", row[1], time.Local); err != nil {
+			return nil, err
+		}
+		if tags := strings.Fields(row[5]); len(tags) > 0 {
+			if l.note != "" {
+				l.note += "\n"
+			}
+			l.note += "#" + strings.Join(tags, " #")
+		}
+		answer = append(answer, l)
+	}
+	return answer, nil
+}
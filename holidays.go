@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//loadICS adds the all-day events in an iCalendar file, such as a region's public
+//holidays, to daysOff. Events with a time of day are ignored.
+func loadICS(path string, daysOff map[string]string) error {
+	path = expandHome(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	//long lines are folded by starting the following lines with a space or tab
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	var summary string
+	for _, line := range lines {
+		nameValue := strings.SplitN(line, ":", 2)
+		if len(nameValue) != 2 {
+			continue
+		}
+		name := strings.ToUpper(strings.SplitN(nameValue[0], ";", 2)[0])
+		value := nameValue[1]
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			start, end, summary = never, never, ""
+		case name == "DTSTART":
+			start, _ = time.ParseInLocation("20060102", value, time.Local)
+		case name == "DTEND":
+			end, _ = time.ParseInLocation("20060102", value, time.Local)
+		case name == "SUMMARY":
+			summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+		case name == "END" && value == "VEVENT":
+			if start == never {
+				continue
+			}
+			//DTEND is exclusive, and a missing one means a single day
+			if end == never || !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+				daysOff[day.Format("2006-01-02")] = summary
+			}
+		}
+	}
+	return nil
+}
+
+//holidaysCommand lists the days off in a period which overtime takes into account
+func holidaysCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	from, to, err := parsePeriod(opts.get("period", "this-year"))
+	if err != nil {
+		panic(err)
+	}
+	if from == never || to == never {
+		panic(errors.New("Holidays can only be listed for a period with a start and an end"))
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	s, err := t.schedule()
+	if err != nil {
+		panic(err)
+	}
+	var days []string
+	for day := range s.daysOff {
+		d, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+		if !d.Before(from) && d.Before(to) {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		fmt.Println(day, s.daysOff[day])
+	}
+}
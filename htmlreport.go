@@ -0,0 +1,75 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"time"
+)
+
+//an htmlReport is a report along with its charts, drawn as SVG so that the page can
+//be shared as a single file
+type htmlReport struct {
+	reportData
+	Days    htmltemplate.HTML
+	Heatmap htmltemplate.HTML
+	Pie     htmltemplate.HTML
+}
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Report for {{.Root}} ({{.Period}})</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #3c3c3c; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+td.time { text-align: right; font-variant-numeric: tabular-nums; }
+figure { display: inline-block; margin: 1em 1em 1em 0; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Report for {{.Root}} ({{.Period}})</h1>
+<p>Total: {{clock .Total}}</p>
+<figure>{{.Days}}<figcaption>Time per day</figcaption></figure>
+<figure>{{.Pie}}<figcaption>Time per task</figcaption></figure>
+<figure>{{.Heatmap}}<figcaption>Time by weekday and hour</figcaption></figure>
+<h2>Tasks</h2>
+<table>
+<tr><th>Task</th><th>Time</th></tr>
+{{range .Tasks}}<tr><td style="padding-left: {{.Depth}}em">{{.Name}}</td><td class="time">{{clock .Total}}</td></tr>
+{{end}}</table>
+<h2>Logs</h2>
+<table>
+<tr><th>Start</th><th>Time</th><th>Task</th><th>Note</th></tr>
+{{range .Logs}}<tr><td>{{.Start.Format "Mon 02 Jan 15:04"}}</td><td class="time">{{clock .Duration}}</td><td>{{.Task}}</td><td>{{if .Markdown}}{{markdown .Note}}{{else}}{{firstLine .Note}}{{end}}</td></tr>
+{{end}}</table>
+{{if .Milestones}}<h2>Milestones</h2>
+<table>
+<tr><th>Reached</th><th>Milestone</th><th>Task</th><th>Time since the last</th></tr>
+{{range .Milestones}}<tr><td>{{.At.Format "Mon 02 Jan 15:04"}}</td><td>{{.Name}}</td><td>{{.Task}}</td><td class="time">{{clock .Time}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`
+
+//writeHTMLReport writes a report as a page with charts of the time per day, the share of
+//each task and the weekdays and hours it was logged
+func writeHTMLReport(w io.Writer, t task, period string, from, to time.Time) error {
+	tmpl, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(reportFuncs)).Funcs(htmltemplate.FuncMap{"markdown": renderMarkdown}).Parse(htmlReportTemplate)
+	if err != nil {
+		return err
+	}
+	report := htmlReport{reportData: newReportData(t, period, from, to)}
+	if ls := t.recursiveLogsMatching(between(from, to)); len(ls) > 0 {
+		//the charts are generated here, so their markup is trusted
+		ms, err := loadMilestones(t)
+		if err != nil {
+			return err
+		}
+		report.Days = htmltemplate.HTML(svgString(func(w io.Writer) error { return svgBars(w, withMilestones(chartBars(ls, "day", from, to), ms, "day")) }))
+		report.Heatmap = htmltemplate.HTML(svgString(func(w io.Writer) error { return svgHeatmap(w, ls) }))
+		report.Pie = htmltemplate.HTML(svgString(func(w io.Writer) error { return svgPie(w, taskSlices(t, between(from, to))) }))
+	}
+	return tmpl.Execute(w, report)
+}
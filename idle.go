@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//an idleDetector tells watch when the user goes idle or locks the screen, and when they
+//return. New desktops are supported by adding one to idleDetectors.
+type idleDetector interface {
+	//available reports whether the detector works in this session
+	available() bool
+	//watch calls changed with true and when the user went away, and false when they
+	//return, until ctx is done
+	watch(ctx context.Context, opts idleOptions, changed func(idle bool, since time.Time)) error
+}
+
+//idleOptions are the settings detectors may use
+type idleOptions struct {
+	//every is how often polling detectors check
+	every time.Duration
+	//timeout is how long without input counts as idle, for detectors which notice that
+	timeout time.Duration
+}
+
+//idleDetectors are tried in order of preference when no detector is chosen
+var idleDetectors = map[string]struct {
+	order    int
+	detector idleDetector
+}{
+	"wayland": {0, waylandDetector{}},
+	"gnome":   {1, pollingDetector{gnomeScreenLocked, gnomeAvailable}},
+	"macos":   {2, pollingDetector{macScreenLocked, func() bool { return runtime.GOOS == "darwin" }}},
+	"windows": {3, wtsDetector{}},
+}
+
+func idleDetectorNames() []string {
+	var names []string
+	for name := range idleDetectors {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return idleDetectors[names[i]].order < idleDetectors[names[j]].order })
+	return names
+}
+
+//chooseIdleDetector returns the named detector, or with auto the first available one
+func chooseIdleDetector(name string) (string, idleDetector, error) {
+	if name != "auto" {
+		d, ok := idleDetectors[name]
+		if !ok {
+			return "", nil, errors.New("Invalid --detector: " + name + " (use auto, " + strings.Join(idleDetectorNames(), ", ") + ")")
+		}
+		if !d.detector.available() {
+			return "", nil, errors.New("Detector Unavailable: " + name)
+		}
+		return name, d.detector, nil
+	}
+	for _, name := range idleDetectorNames() {
+		if d := idleDetectors[name].detector; d.available() {
+			return name, d, nil
+		}
+	}
+	return "", nil, errors.New("No way to tell when the screen is locked here (use --detector=" + strings.Join(idleDetectorNames(), ", ") + ")")
+}
+
+//a pollingDetector asks whether the screen is locked every so often
+type pollingDetector struct {
+	locked      func() (bool, error)
+	isAvailable func() bool
+}
+
+func (d pollingDetector) available() bool {
+	return d.isAvailable()
+}
+
+func (d pollingDetector) watch(ctx context.Context, opts idleOptions, changed func(bool, time.Time)) error {
+	if _, err := d.locked(); err != nil {
+		return errors.New("Cannot tell whether the screen is locked: " + err.Error())
+	}
+	ticker := time.NewTicker(opts.every)
+	defer ticker.Stop()
+	was := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+		locked, err := d.locked()
+		if err != nil {
+			debug("cannot tell whether the screen is locked:", err)
+			continue
+		}
+		if locked != was {
+			changed(locked, time.Now())
+			was = locked
+		}
+	}
+}
+
+func gnomeAvailable() bool {
+	_, err := exec.LookPath("qdbus")
+	return err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+//gnomeScreenLocked asks the GNOME screensaver whether the screen is locked
+func gnomeScreenLocked() (bool, error) {
+	cmd := exec.Command("qdbus", "org.gnome.ScreenSaver", "/org/gnome/ScreenSaver", "org.gnome.ScreenSaver.GetActive")
+	var outb bytes.Buffer
+	cmd.Stdout = &outb
+	if err := cmd.Run(); err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(outb.String()) == "true", nil
+}
+
+//lockedPath records when the user went away (e.g. locked the screen) while watch is
+//running, so that sessions which end before they return leave the time out
+func lockedPath() string {
+	return filepath.Join(sessionDir(), "locked")
+}
+
+//lockedSince returns when the user went away, if watch has seen them go
+func lockedSince() (time.Time, bool) {
+	b, err := ioutil.ReadFile(lockedPath())
+	if err != nil {
+		return time.Time{}, false
+	}
+	since, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(b)))
+	return since, err == nil
+}
+
+//addPause records that the session was paused from start to end
+func (s session) addPause(start, end time.Time) error {
+	f, err := os.OpenFile(s.pausesPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(start.Format(time.RFC3339Nano) + " " + end.Format(time.RFC3339Nano) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+//pauseSessions pauses the running sessions for the time the user was away
+func pauseSessions(since, until time.Time) {
+	for _, s := range loadSessions() {
+		start := since
+		if s.start.After(start) {
+			start = s.start
+		}
+		if !until.After(start) {
+			continue
+		}
+		if err := s.addPause(start, until); err != nil {
+			panic(err)
+		}
+		debug("paused", s.task, "for", until.Sub(start).Round(time.Second))
+	}
+}
+
+//watchCommand is the idle watcher, which pauses running sessions while the user is away
+//(the screen is locked, or on Wayland, there has been no input for a while), so that the
+//time is left out of their logs, and tracks tasks for the autostart rules
+func watchCommand(args []string) {
+	opts, _ := parseOptions(args)
+	var settings idleOptions
+	var err error
+	every := opts.get("every", task(".").setting("watch_interval", "10s"))
+	if settings.every, err = parseDuration(every); err != nil || settings.every <= 0 {
+		panic(errors.New("Invalid --every: " + every))
+	}
+	timeout := opts.get("idle", task(".").setting("idle_timeout", "5m"))
+	if settings.timeout, err = parseDuration(timeout); err != nil || settings.timeout <= 0 {
+		panic(errors.New("Invalid --idle: " + timeout))
+	}
+	name, detector, err := chooseIdleDetector(opts.get("detector", task(".").setting("idle_detector", "auto")))
+	if err != nil {
+		panic(err)
+	}
+	if err := os.MkdirAll(sessionDir(), 0700); err != nil {
+		panic(err)
+	}
+	//a lock left by a watcher which was stopped while the user was away is still paused
+	resume := func() {
+		if since, ok := lockedSince(); ok {
+			debug("back after", time.Since(since).Round(time.Second))
+			pauseSessions(since, time.Now())
+			os.Remove(lockedPath())
+		}
+	}
+	resume()
+	recoverPowerLosses()
+	if opts.has("power") || task(".").setting("power_events", "false") == "true" {
+		critical := strings.TrimSuffix(task(".").setting("battery_critical", "5%"), "%")
+		percent, err := strconv.Atoi(critical)
+		if err != nil || percent < 0 || percent > 100 {
+			panic(errors.New("Invalid battery_critical: " + critical))
+		}
+		go watchPower(settings.every, percent)
+	}
+	autostartDone := make(chan struct{})
+	go watchAutostart(settings.every, autostartDone)
+	sdNotify("READY=1")
+	inform("Watching for the user to be away with", name)
+
+	err = detector.watch(ctx, settings, func(idle bool, since time.Time) {
+		if !idle {
+			resume()
+			return
+		}
+		debug("away since", since.Format("15:04:05"))
+		if err := ioutil.WriteFile(lockedPath(), []byte(since.Format(time.RFC3339Nano)+"\n"), 0600); err != nil {
+			panic(err)
+		}
+	})
+	sdNotify("STOPPING=1")
+	resume()
+	if err != nil {
+		panic(err)
+	}
+	<-autostartDone
+}
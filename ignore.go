@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//ignoreFileName is a file in a task's directory listing, in the style of .gitignore, files
+//and directories in it and beneath it which are not logs or tasks, such as node_modules
+//or editor backups, so that they are never read
+const ignoreFileName = ".horologignore"
+
+//an ignoreRule is a line of an ignore file: a pattern matched against names (or, if it
+//contains a /, paths relative to the file), which ignores them or, after !, unignores them.
+//A trailing / only matches directories.
+type ignoreRule struct {
+	base    string
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+func (r ignoreRule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.Contains(r.pattern, "/") {
+		ok, _ := filepath.Match(r.pattern, filepath.Base(rel))
+		return ok
+	}
+	return matchGlob(strings.TrimPrefix(r.pattern, "/"), rel)
+}
+
+//loadIgnoreFile returns the rules in dir's ignore file, if it has one
+func loadIgnoreFile(dir string) []ignoreRule {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	debug("reading ignore file", filepath.Join(dir, ignoreFileName))
+	var answer []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		r.pattern = line
+		answer = append(answer, r)
+	}
+	return answer
+}
+
+//taskIgnores are the absolute path of each task read so far and the rules which apply
+//to its entries: those of its own ignore file, after those of the directories above it
+type taskIgnores struct {
+	abs   string
+	rules []ignoreRule
+}
+
+var (
+	ignoresMu sync.Mutex
+	ignores   = map[string]taskIgnores{}
+)
+
+func (t task) ignoreRules() taskIgnores {
+	ignoresMu.Lock()
+	cached, ok := ignores[t.path()]
+	ignoresMu.Unlock()
+	if ok {
+		return cached
+	}
+	abs, err := filepath.Abs(t.path())
+	if err != nil {
+		abs = t.path()
+	}
+	var rules []ignoreRule
+	if parent := filepath.Dir(abs); parent != abs {
+		rules = append(rules, task(parent).ignoreRules().rules...)
+	}
+	cached = taskIgnores{abs: abs, rules: append(rules, loadIgnoreFile(abs)...)}
+	ignoresMu.Lock()
+	ignores[t.path()] = cached
+	ignoresMu.Unlock()
+	return cached
+}
+
+//ignores reports whether an entry of the task's directory is ignored by the ignore files
+//in it and above it, in which the last rule to match wins
+func (t task) ignores(name string, isDir bool) bool {
+	ti := t.ignoreRules()
+	path := filepath.Join(ti.abs, name)
+	answer := false
+	for _, r := range ti.rules {
+		if r.matches(path, isDir) {
+			answer = !r.negate
+		}
+	}
+	return answer
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//an importedLog is an entry read from another tool, before it is written into the tree
+type importedLog struct {
+	task   string
+	start  time.Time
+	end    time.Time
+	author string
+	note   string
+}
+
+//an importer reads entries from a file (or - for stdin, where that makes sense) in another tool's format
+type importer func(path string, opts options) ([]importedLog, error)
+
+var importers = map[string]importer{
+	"csv":               importCSV,
+	"hamster":           importHamster,
+	"atimetracker":      importATimeTracker,
+	"simpletimetracker": importSimpleTimeTracker,
+}
+
+//registerImporter adds an import format, from a file of its own like registerExporter
+func registerImporter(name string, imp importer) {
+	if _, ok := importers[name]; ok {
+		panic(errors.New("Import format registered twice: " + name))
+	}
+	importers[name] = imp
+}
+
+//openImport opens a file to import, or stdin for -
+func openImport(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+//importTime parses a timestamp with the --time-format option (a Go layout such as
+//02/01/2006 15:04), or else as RFC 3339 or any date parseDate understands
+func importTime(s string, opts options) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if opts.has("time-format") {
+		return time.ParseInLocation(opts.get("time-format", ""), s, time.Local)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return parseDate(s)
+}
+
+//importCSV reads one entry per row, with the columns given by --map as numbers
+//(from 1) or, with --header, names, e.g. --map="start=1,end=2,task=3,note=4".
+//Either end or duration is required, and author is optional.
+func importCSV(path string, opts options) ([]importedLog, error) {
+	r, err := openImport(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if sep := opts.get("separator", ","); sep != "" {
+		reader.Comma = []rune(sep)[0]
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if !opts.has("map") {
+		return nil, errors.New("No columns specified, use e.g. --map=\"start=1,end=2,task=3,note=4\"")
+	}
+	var header []string
+	if opts.has("header") && len(rows) > 0 {
+		header, rows = rows[0], rows[1:]
+	}
+	columns := map[string]int{}
+	for _, pair := range strings.Split(opts.get("map", ""), ",") {
+		fieldColumn := strings.SplitN(pair, "=", 2)
+		if len(fieldColumn) != 2 {
+			return nil, errors.New("Invalid --map: " + pair)
+		}
+		field, column := strings.TrimSpace(fieldColumn[0]), strings.TrimSpace(fieldColumn[1])
+		n, err := strconv.Atoi(column)
+		if err != nil {
+			n = 0
+			for i, name := range header {
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					n = i + 1
+				}
+			}
+		}
+		if n < 1 {
+			return nil, errors.New("Invalid --map column for " + field + ": " + column)
+		}
+		columns[field] = n - 1
+	}
+	if _, ok := columns["start"]; !ok {
+		return nil, errors.New("No start column in --map")
+	}
+	_, hasEnd := columns["end"]
+	_, hasDuration := columns["duration"]
+	if !hasEnd && !hasDuration {
+		return nil, errors.New("No end or duration column in --map")
+	}
+
+	var answer []importedLog
+	for i, row := range rows {
+		get := func(field string) string {
+			if c, ok := columns[field]; ok && c < len(row) {
+				return row[c]
+			}
+			return ""
+		}
+		invalid := func(field string) error {
+			return errors.New("Invalid " + field + " on row " + strconv.Itoa(i+1) + ": " + get(field))
+		}
+		l := importedLog{task: get("task"), author: get("author"), note: get("note")}
+		if l.start, err = importTime(get("start"), opts); err != nil {
+			return nil, invalid("start")
+		}
+		if hasEnd && get("end") != "" {
+			if l.end, err = importTime(get("end"), opts); err != nil {
+				return nil, invalid("end")
+			}
+		} else {
+			dur, err := parseDuration(get("duration"))
+			if err != nil {
+				//spreadsheets often record durations as decimal hours
+				hours, herr := strconv.ParseFloat(strings.TrimSpace(get("duration")), 64)
+				if herr != nil {
+					return nil, invalid("duration")
+				}
+				dur = time.Duration(hours * float64(time.Hour))
+			}
+			l.end = l.start.Add(dur)
+		}
+		answer = append(answer, l)
+	}
+	return answer, nil
+}
+
+//importTaskPath turns a task name from another tool into a path beneath root, with levels
+//separated by slashes, leaving out anything which could escape the tree
+func importTaskPath(root, name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		parts = append(parts, strings.TrimLeft(part, "."))
+	}
+	if len(parts) == 0 {
+		return root
+	}
+	return root + "/" + strings.Join(parts, "/")
+}
+
+func importCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if len(positional) < 2 {
+		panic(errors.New("Usage: horolog import <format> <file or -> [task]"))
+	}
+	imp, ok := importers[positional[0]]
+	if !ok {
+		var formats []string
+		for name := range importers {
+			formats = append(formats, name)
+		}
+		sort.Strings(formats)
+		panic(errors.New("Invalid Format: " + positional[0] + " (use " + strings.Join(formats, ", ") + ")"))
+	}
+	root := "."
+	if len(positional) > 2 {
+		root = positional[2]
+	}
+
+	entries, err := imp(positional[1], opts)
+	if err != nil {
+		panic(err)
+	}
+	if !opts.has("dry-run") {
+		createTask(root)
+		defer lockTree(root)()
+	}
+
+	imported, existing := 0, 0
+	for _, e := range entries {
+		if !e.end.After(e.start) {
+			panic(errors.New("Invalid Entry: " + e.task + " ends before it starts at " + e.start.String()))
+		}
+		path := importTaskPath(root, e.task)
+		if opts.has("dry-run") {
+			inform(e.start, e.end.Sub(e.start), "\t\t", path+"/")
+			continue
+		}
+		t, err := loadOrCreateTask(path)
+		if err != nil {
+			panic(err)
+		}
+		author := e.author
+		if author == "" {
+			author = t.user()
+		}
+		lpath := t.authoredLogPath(e.start, e.end, author)
+		if _, err := os.Stat(lpath); err == nil {
+			existing++
+			continue
+		}
+		note := e.note
+		if note != "" && !strings.HasSuffix(note, "\n") {
+			note += "\n"
+		}
+		forced, err := writeNewLog(lpath, []byte(note), opts.has("force"))
+		if err != nil {
+			panic(err)
+		}
+		action := "import"
+		if forced {
+			action += " --force"
+		}
+		audit(root, action, treePath(lpath))
+		imported++
+	}
+	if !opts.has("dry-run") {
+		inform("Imported", imported, "logs, skipping", existing, "already in", filepath.Clean(root))
+	}
+}
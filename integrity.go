@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//integrityPath is the manifest of finalized logs, in sha256sum format with paths
+//relative to the root of the tree, which verify-integrity checks them against
+func integrityPath(dir string) string {
+	return filepath.Join(treeRoot(dir), stateDirName, "integrity.sha256")
+}
+
+func loadIntegrity(dir string) (map[string]string, error) {
+	hashes := map[string]string{}
+	f, err := os.Open(integrityPath(dir))
+	if os.IsNotExist(err) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		hashPath := strings.SplitN(line, "  ", 2)
+		if len(hashPath) != 2 {
+			return nil, errors.New("Invalid Manifest: " + integrityPath(dir))
+		}
+		hashes[hashPath[1]] = hashPath[0]
+	}
+	return hashes, scanner.Err()
+}
+
+func saveIntegrity(dir string, hashes map[string]string) error {
+	var paths []string
+	for path := range hashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	manifest := "# logs finalized by horolog verify-integrity\n"
+	for _, path := range paths {
+		manifest += hashes[path] + "  " + path + "\n"
+	}
+	stateDir(dir)
+	return writeFileAtomic(integrityPath(dir), []byte(manifest), 0600)
+}
+
+//verifyIntegrityCommand finalizes logs which ended more than --settle ago by recording
+//their hashes, and reports finalized logs which have since been modified or removed,
+//e.g. by bit rot or a sync tool, exiting with status 1 if there are any. --update
+//accepts the changes instead, for edits which were intended.
+func verifyIntegrityCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	settle := 24 * time.Hour
+	if opts.has("settle") {
+		settle = opts.duration("settle")
+	}
+	defer lockTree(dir)()
+	hashes, err := loadIntegrity(dir)
+	if err != nil {
+		panic(err)
+	}
+	root := treeRoot(dir)
+	prefix := treePath(t.path())
+
+	problems, finalized := 0, 0
+	report := func(problem, path string) {
+		if opts.has("update") {
+			inform("accepted", problem+":", path)
+		} else {
+			fmt.Println(problem+":", path)
+			problems++
+		}
+	}
+	var paths []string
+	for path := range hashes {
+		if prefix == "." || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		checkCanceled()
+		hash, err := hashFile(filepath.Join(root, path))
+		switch {
+		case os.IsNotExist(err):
+			report("removed", path)
+			if opts.has("update") {
+				delete(hashes, path)
+			}
+		case err != nil:
+			report("unreadable", path)
+		case hash != hashes[path]:
+			report("modified", path)
+			if opts.has("update") {
+				hashes[path] = hash
+			}
+		}
+	}
+
+	settled := time.Now().Add(-settle)
+	for _, l := range t.recursiveLogsMatching(func(l log) bool { return l.end().Before(settled) }) {
+		path := treePath(l.path())
+		if _, ok := hashes[path]; ok {
+			continue
+		}
+		hash, err := hashFile(l.path())
+		if err != nil {
+			panic(err)
+		}
+		hashes[path] = hash
+		finalized++
+	}
+	if finalized > 0 || opts.has("update") {
+		if err := saveIntegrity(dir, hashes); err != nil {
+			panic(err)
+		}
+	}
+
+	if problems > 0 {
+		os.Exit(1)
+	}
+	inform("Verified", len(paths), "logs, finalizing", finalized, "more")
+}
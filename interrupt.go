@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//an interruption is work which pauses the current session, recorded by interrupt in the
+//session directory until back logs it in the interruptions task and resumes the session
+type interruption struct {
+	task   string
+	start  time.Time
+	reason string
+	//paused is the pid of the session which was paused, or 0 if there was none
+	paused int
+}
+
+func interruptionPath() string {
+	return filepath.Join(sessionDir(), "interruption")
+}
+
+func loadInterruption() (interruption, bool) {
+	c := loadConfig(interruptionPath())
+	start, err := time.Parse(time.RFC3339Nano, c.get("start", ""))
+	if err != nil || c.get("task", "") == "" {
+		return interruption{}, false
+	}
+	paused, _ := strconv.Atoi(c.get("paused", "0"))
+	return interruption{task: c.get("task", ""), start: start, reason: c.get("reason", ""), paused: paused}, true
+}
+
+//pausesPath is where the times a session was paused for interruptions (or while the
+//screen was locked) are kept, one interval per line
+func (s session) pausesPath() string {
+	return s.path() + ".pauses"
+}
+
+//pauses returns the times the session was paused, including an interruption or a locked
+//screen which has not ended by the end of the session, in order
+func (s session) pauses(end time.Time) []interval {
+	var answer []interval
+	if f, err := os.Open(s.pausesPath()); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 2 {
+				continue
+			}
+			from, err1 := time.Parse(time.RFC3339Nano, fields[0])
+			to, err2 := time.Parse(time.RFC3339Nano, fields[1])
+			if err1 == nil && err2 == nil {
+				answer = append(answer, interval{from, to})
+			}
+		}
+		f.Close()
+	}
+	if i, ok := loadInterruption(); ok && i.paused == s.pid {
+		answer = append(answer, interval{i.start, end})
+	}
+	//as is the screen being locked, if watch is running
+	if since, ok := lockedSince(); ok && since.Before(end) {
+		answer = append(answer, interval{since, end})
+	}
+	sort.Slice(answer, func(i, j int) bool { return answer[i].start.Before(answer[j].start) })
+	return answer
+}
+
+//interruptTask is where interruptions are logged: --task, or else the interrupt_task
+//setting (by default interruptions), relative to the root of the tree of the session
+//which was interrupted
+func interruptTask(opts options, paused *session) (task, error) {
+	base := "."
+	if paused != nil {
+		base = paused.task
+	}
+	path := opts.get("task", task(base).setting("interrupt_task", "interruptions"))
+	if !filepath.IsAbs(path) && !opts.has("task") {
+		path = filepath.Join(treeRoot(base), path)
+	}
+	return loadOrCreateTask(path)
+}
+
+func interruptCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if _, ok := loadInterruption(); ok {
+		panic(errors.New("Interruption Already Running: use horolog back to end it"))
+	}
+	var paused *session
+	sessions := loadSessions()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].start.After(sessions[j].start) })
+	for _, s := range sessions {
+		if s.interactive {
+			paused = &s
+			break
+		}
+	}
+	t, err := interruptTask(opts, paused)
+	if err != nil {
+		panic(err)
+	}
+	abs, err := filepath.Abs(t.path())
+	if err != nil {
+		panic(err)
+	}
+	i := interruption{task: abs, start: time.Now(), reason: strings.Join(strings.Fields(strings.Join(positional, " ")), " ")}
+	text := "task = \"" + i.task + "\"\nstart = " + i.start.Format(time.RFC3339Nano) + "\nreason = \"" + i.reason + "\"\n"
+	if paused != nil {
+		i.paused = paused.pid
+		text += "paused = " + strconv.Itoa(paused.pid) + "\n"
+	}
+	if err := os.MkdirAll(sessionDir(), 0700); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(interruptionPath(), []byte(text), 0600); err != nil {
+		panic(err)
+	}
+	if paused != nil {
+		inform("Paused " + paused.task + ", logging in " + t.path() + " until horolog back")
+	} else {
+		inform("Logging in " + t.path() + " until horolog back")
+	}
+}
+
+func backCommand(args []string) {
+	i, ok := loadInterruption()
+	if !ok {
+		panic(errors.New("No Interruption Running: start one with horolog interrupt"))
+	}
+	end := time.Now()
+	t := task(i.task)
+	defer lockTree(t.path())()
+	path := t.logPath(i.start, end)
+	note := "[interrupt]"
+	if i.reason != "" {
+		note += " " + i.reason
+	}
+	if _, err := writeNewLog(path, []byte(note+"\n"), false); err != nil {
+		panic(err)
+	}
+	audit(t.path(), "interrupt", treePath(path))
+
+	if i.paused != 0 && processAlive(i.paused) {
+		if err := (session{pid: i.paused}).addPause(i.start, end); err != nil {
+			panic(err)
+		}
+	}
+	os.Remove(interruptionPath())
+	inform("Logged", formatHoursMinutes(end.Sub(i.start)), "in", t.path())
+}
+
+//interruptionsCommand counts the logs marked [interrupt] on each day of a period, with
+//the time they took and the cost of switching back to what was interrupted
+func interruptionsCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "this-week"))
+	if err != nil {
+		panic(err)
+	}
+	cost, err := parseDuration(opts.get("switch-cost", t.setting("switch_cost", "15m")))
+	if err != nil {
+		panic(errors.New("Invalid --switch-cost: " + err.Error()))
+	}
+
+	counts := map[time.Time]int{}
+	spent := map[time.Time]time.Duration{}
+	var days []time.Time
+	for _, l := range t.recursiveLogsMatching(between(from, to)) {
+		if l.entryType() != "interrupt" {
+			continue
+		}
+		day := startOfDay(l.start())
+		if counts[day] == 0 {
+			days = append(days, day)
+		}
+		counts[day]++
+		spent[day] += l.duration()
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	total, totalSpent := 0, time.Duration(0)
+	for _, day := range days {
+		fmt.Printf("%s  %3d  %6s  + %s switching\n", day.Format("2006-01-02 Mon"), counts[day], formatHoursMinutes(spent[day]), formatHoursMinutes(time.Duration(counts[day])*cost))
+		total += counts[day]
+		totalSpent += spent[day]
+	}
+	fmt.Printf("Total: %d interruptions, %s interrupted, %s switching (at %s each)\n", total, formatHoursMinutes(totalSpent), formatHoursMinutes(time.Duration(total)*cost), cost)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//clients are tasks whose own .horolog.conf names them, e.g. client = "Acme GmbH". Time is
+//charged at the rate setting (per hour) in the currency setting, both inherited, so that
+//a subtask may be charged differently.
+func (t task) client() string {
+	return t.config().get("client", "")
+}
+
+//a currencyFormat is how amounts in a currency are written
+type currencyFormat struct {
+	symbol   string
+	suffix   bool
+	decimals int
+}
+
+var currencyFormats = map[string]currencyFormat{
+	"EUR": {"€", false, 2},
+	"USD": {"$", false, 2},
+	"GBP": {"£", false, 2},
+	"JPY": {"¥", false, 0},
+	"INR": {"₹", false, 2},
+	"CHF": {"CHF ", false, 2},
+	"SEK": {" kr", true, 2},
+	"NOK": {" kr", true, 2},
+	"DKK": {" kr", true, 2},
+	"PLN": {" zł", true, 2},
+}
+
+//formatMoney writes an amount with the currency's symbol, its usual number of decimal
+//places and thousands separators, or the currency's code for others
+func formatMoney(amount float64, currency string) string {
+	f, ok := currencyFormats[strings.ToUpper(currency)]
+	if !ok {
+		f = currencyFormat{" " + currency, true, 2}
+	}
+	s := strconv.FormatFloat(math.Abs(amount), 'f', f.decimals, 64)
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	s = whole + fraction
+	if f.suffix {
+		s += f.symbol
+	} else {
+		s = f.symbol + s
+	}
+	if amount < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+//exchangeRates are read from the fx_rates setting (by default ~/.config/horolog/rates),
+//with one rate per line, e.g. EUR USD 1.0868 for 1 EUR = 1.0868 USD
+type exchangeRates map[[2]string]float64
+
+func loadExchangeRates(path string) (exchangeRates, error) {
+	rates := exchangeRates{}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, errors.New("Invalid Exchange Rate: " + scanner.Text())
+		}
+		rate, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || rate <= 0 {
+			return nil, errors.New("Invalid Exchange Rate: " + scanner.Text())
+		}
+		rates[[2]string{strings.ToUpper(fields[0]), strings.ToUpper(fields[1])}] = rate
+	}
+	return rates, scanner.Err()
+}
+
+//convert converts an amount between currencies, using the inverse rate if necessary
+func (rates exchangeRates) convert(amount float64, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+	if rate, ok := rates[[2]string{from, to}]; ok {
+		return amount * rate, nil
+	}
+	if rate, ok := rates[[2]string{to, from}]; ok {
+		return amount / rate, nil
+	}
+	return 0, errors.New("No exchange rate from " + from + " to " + to)
+}
+
+//an invoiceLine is the time charged for one task at one rate
+type invoiceLine struct {
+	task     string
+	duration time.Duration
+	rate     float64
+	currency string
+}
+
+//amount is the charge for the line, rounded to the currency's smallest unit
+func (il invoiceLine) amount() float64 {
+	f, ok := currencyFormats[strings.ToUpper(il.currency)]
+	if !ok {
+		f.decimals = 2
+	}
+	unit := math.Pow(10, float64(f.decimals))
+	return math.Round(il.duration.Hours()*il.rate*unit) / unit
+}
+
+//invoiceLines totals the time in each task beneath the client, charged at each task's rate
+func invoiceLines(client task, f filter) []invoiceLine {
+	var answer []invoiceLine
+	for _, line := range client.summaryLines(f) {
+		t := line.task
+		rate, err := strconv.ParseFloat(t.setting("rate", "0"), 64)
+		if err != nil {
+			panic(errors.New("Invalid rate for " + t.path() + ": " + t.setting("rate", "")))
+		}
+		rel, err := filepath.Rel(client.path(), t.path())
+		if err != nil {
+			rel = t.path()
+		}
+		answer = append(answer, invoiceLine{
+			task:     filepath.ToSlash(rel),
+			duration: line.logs.duration(),
+			rate:     rate,
+			currency: strings.ToUpper(t.setting("currency", "EUR")),
+		})
+	}
+	return answer
+}
+
+//findClients returns the task and its subtasks which are clients, outermost first
+func findClients(t task) []task {
+	var answer []task
+	for _, t2 := range append([]task{t}, t.recursiveSubtasks()...) {
+		if t2.client() != "" {
+			answer = append(answer, t2)
+		}
+	}
+	return answer
+}
+
+func invoiceCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "last-month"))
+	if err != nil {
+		panic(err)
+	}
+	clients := []task{t}
+	if opts.has("all-clients") {
+		if clients = findClients(t); len(clients) == 0 {
+			panic(errors.New("No clients beneath " + t.path() + " (set client = \"name\" in a task's " + taskConfigName + ")"))
+		}
+	}
+	target := strings.ToUpper(opts.get("convert", ""))
+	var rates exchangeRates
+	if target != "" {
+		path := expandHome(t.setting("fx_rates", filepath.Join(configDir(), "rates")))
+		if rates, err = loadExchangeRates(path); err != nil {
+			panic(err)
+		}
+	}
+
+	//a client's time is only charged in its own section, not in an enclosing client's
+	nested := map[string]bool{}
+	for _, c := range clients {
+		nested[c.path()] = true
+	}
+	totals := map[string]float64{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, c := range clients {
+		client := c
+		f := func(l log) bool {
+			for d := strings.TrimSuffix(l.dir(), "/"); d != client.path() && d != "" && d != "."; d = filepath.Dir(d) {
+				if nested[d] {
+					return false
+				}
+			}
+			return between(from, to)(l)
+		}
+		name := c.client()
+		if name == "" {
+			name = c.path()
+		}
+		fmt.Fprintln(w, name+" ("+c.path()+")")
+		fmt.Fprintln(w, "Task\tHours\tRate\tAmount")
+		sums := map[string]float64{}
+		var hours time.Duration
+		for _, il := range invoiceLines(c, f) {
+			fmt.Fprintf(w, "%s\t%.2f\t%s\t%s\n", il.task, il.duration.Hours(), formatMoney(il.rate, il.currency), formatMoney(il.amount(), il.currency))
+			sums[il.currency] += il.amount()
+			hours += il.duration
+		}
+		var currencies []string
+		for currency := range sums {
+			currencies = append(currencies, currency)
+		}
+		sort.Strings(currencies)
+		for i, currency := range currencies {
+			label, h := "Total", fmt.Sprintf("%.2f", hours.Hours())
+			if i > 0 {
+				label, h = "", ""
+			}
+			fmt.Fprintf(w, "%s\t%s\t\t%s\n", label, h, formatMoney(sums[currency], currency))
+			if target == "" {
+				totals[currency] += sums[currency]
+				continue
+			}
+			converted, err := rates.convert(sums[currency], currency, target)
+			if err != nil {
+				panic(err)
+			}
+			if currency != target {
+				fmt.Fprintf(w, "\t\t\t(%s)\n", formatMoney(converted, target))
+			}
+			totals[target] += converted
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	if len(clients) > 1 {
+		var currencies []string
+		for currency := range totals {
+			currencies = append(currencies, currency)
+		}
+		sort.Strings(currencies)
+		var amounts []string
+		for _, currency := range currencies {
+			amounts = append(amounts, formatMoney(totals[currency], currency))
+		}
+		fmt.Println("All clients:", strings.Join(amounts, ", "))
+	}
+}
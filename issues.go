@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//issuePattern matches references to tickets in notes: JIRA style keys (ACME-123),
+//GitHub repositories' issues (GH-owner/repo#12) and bare issue numbers (#4567)
+var issuePattern = regexp.MustCompile(`\b(GH-[\w.-]+/[\w.-]+#[0-9]+)|\b([A-Z][A-Z0-9]+-[0-9]+)\b|(?:^|[^\w/&#])(#[0-9]+)\b`)
+
+//issuePatterns caches the issue_pattern setting of each directory
+var issuePatterns = map[string]*regexp.Regexp{}
+
+//taskIssuePattern is the issue_pattern setting of a task, a regular expression
+//whose first group (or else the whole match) is an issue, or the default
+func taskIssuePattern(dir string) *regexp.Regexp {
+	if re, ok := issuePatterns[dir]; ok {
+		return re
+	}
+	re := issuePattern
+	if pattern := task(dir).setting("issue_pattern", ""); pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			panic(errors.New("Invalid issue_pattern: " + err.Error()))
+		}
+	}
+	issuePatterns[dir] = re
+	return re
+}
+
+//issues returns the tickets the log refers to, from the issues in its front matter
+//and the references in its note, in the order they appear
+func (l log) issues() []string {
+	fm, note := parseFrontMatter(l.text())
+	answer := append(fm["issue"], fm["issues"]...)
+	for _, m := range taskIssuePattern(l.dir()).FindAllStringSubmatch(note, -1) {
+		issue := m[0]
+		for _, group := range m[1:] {
+			if group != "" {
+				issue = group
+				break
+			}
+		}
+		answer = append(answer, strings.TrimSpace(issue))
+	}
+	seen := map[string]bool{}
+	unique := answer[:0]
+	for _, issue := range answer {
+		if !seen[issue] {
+			seen[issue] = true
+			unique = append(unique, issue)
+		}
+	}
+	return unique
+}
+
+//groupings are the ways summaries and exports can be broken down other than by task
+//(--group-by=), each returning the groups a log belongs to. A log may belong to several
+//groups, counting in full towards each, or to none.
+var groupings = map[string]func(l log) []string{
+	"issue": func(l log) []string { return l.issues() },
+	"context": func(l log) []string {
+		return l.frontMatter()["context"]
+	},
+	"type": func(l log) []string {
+		if t := l.entryType(); t != "" {
+			return []string{t}
+		}
+		return nil
+	},
+	"group": func(l log) []string { return taskGroups(l.dir()) },
+}
+
+//taskGroupsCache caches the groups of each directory
+var taskGroupsCache = map[string][]string{}
+
+//taskGroups returns the reporting groups a task rolls up into, from its group setting
+//(e.g. group = "billable/acme", or several separated by commas), along with the groups
+//above them, so that billable/acme also counts towards billable
+func taskGroups(dir string) []string {
+	if groups, ok := taskGroupsCache[dir]; ok {
+		return groups
+	}
+	var groups []string
+	seen := map[string]bool{}
+	for _, g := range strings.Split(task(dir).setting("group", ""), ",") {
+		g = strings.Trim(strings.TrimSpace(g), "/")
+		for g != "" && g != "." {
+			if !seen[g] {
+				seen[g] = true
+				groups = append(groups, g)
+			}
+			g = path.Dir(g)
+		}
+	}
+	sort.Strings(groups)
+	taskGroupsCache[dir] = groups
+	return groups
+}
+
+//grouping returns the named grouping, panicking if there is no such grouping
+func grouping(name string) func(l log) []string {
+	g, ok := groupings[name]
+	if !ok {
+		var names []string
+		for n := range groupings {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		panic(errors.New("Invalid --group-by: " + name + " (use " + strings.Join(names, ", ") + ")"))
+	}
+	return g
+}
+
+//groupedSummary lists the time in each group, longest first, along with the tasks (relative
+//to root) whose logs are in it. Logs in no group are counted as (none).
+func groupedSummary(ls logs, root task, name string) string {
+	g := grouping(name)
+	groups := map[string]logs{}
+	for _, l := range ls {
+		keys := g(l)
+		if len(keys) == 0 {
+			keys = []string{"(none)"}
+		}
+		for _, key := range keys {
+			groups[key] = append(groups[key], l)
+		}
+	}
+	var keys []string
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		di, dj := groups[keys[i]].duration(), groups[keys[j]].duration()
+		if di != dj {
+			return di > dj
+		}
+		return keys[i] < keys[j]
+	})
+
+	answer := ""
+	for _, key := range keys {
+		var tasks []string
+		seen := map[string]bool{}
+		for _, l := range groups[key] {
+			rel, err := filepath.Rel(root.path(), l.dir())
+			if err != nil {
+				rel = l.dir()
+			}
+			if rel = filepath.ToSlash(rel); !seen[rel] {
+				seen[rel] = true
+				tasks = append(tasks, rel)
+			}
+		}
+		sort.Strings(tasks)
+		answer += key + " (" + groups[key].duration().String() + ") " + strings.Join(tasks, ", ") + "\n"
+	}
+	return answer
+}
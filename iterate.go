@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//errStop can be returned by a forEachLog callback to stop early, and is not returned by forEachLog
+var errStop = errors.New("stop")
+
+//forEachLog calls fn with each log in the task and its subtasks which matches f, reading
+//directories a batch of names at a time rather than building a list of every log, so
+//that trees of any size can be processed in constant memory. Logs within a directory
+//are visited in no particular order, before its subtasks, which are visited in order.
+func (t task) forEachLog(f filter, fn func(l log) error) error {
+	err := t.walkLogs(f, fn)
+	if err == errStop {
+		return nil
+	}
+	return err
+}
+
+func (t task) walkLogs(f filter, fn func(l log) error) error {
+	checkCanceled()
+	debug("reading", t.path())
+	subtasks, hasLogsDir, err := t.walkDir(t.path(), f, fn)
+	if err == nil && hasLogsDir {
+		_, _, err = t.walkDir(t.path()+"/"+logsDirName, f, fn)
+	}
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(subtasks)
+	for _, name := range subtasks {
+		if err := task(t.path()+"/"+name).walkLogs(f, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//walkDir calls fn for each log in one of the task's directories which matches f, returning
+//the names of its subtasks and whether it has a .timelogs directory
+func (t task) walkDir(dirPath string, f filter, fn func(l log) error) (subtasks []string, hasLogsDir bool, err error) {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		skip(dirPath, err)
+		return nil, false, nil
+	}
+	defer dir.Close()
+	for {
+		names, err := dir.Readdirnames(256)
+		for _, name := range names {
+			if name == logsDirName {
+				hasLogsDir = true
+			}
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			path := dirPath + "/" + name
+			fi, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			if fi = t.followLink(path, fi); fi == nil || t.ignores(name, fi.IsDir()) {
+				continue
+			}
+			if fi.IsDir() {
+				if dirPath == t.path() && !task(path).excluded() {
+					subtasks = append(subtasks, name)
+				}
+				continue
+			}
+			l, err := loadLog(path)
+			if err != nil {
+				if looksLikeLog(name) {
+					skip(path, err)
+				}
+				continue
+			}
+			if f(l) {
+				if err := fn(l); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			skip(dirPath, err)
+			break
+		}
+	}
+	return subtasks, hasLogsDir, nil
+}
+
+//durationMatching is the time in the logs in the task and its subtasks which match f
+func (t task) durationMatching(f filter) time.Duration {
+	var total time.Duration
+	t.forEachLog(f, func(l log) error {
+		total += l.duration()
+		return nil
+	})
+	return total
+}
+
+//hasLogsMatching reports whether any log in the task or its subtasks matches f
+func (t task) hasLogsMatching(f filter) bool {
+	found := false
+	t.forEachLog(f, func(l log) error {
+		found = true
+		return errStop
+	})
+	return found
+}
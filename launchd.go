@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//macScreenLocked asks IOKit (through ioreg) whether the console session's screen is
+//locked, which CGSession reports as CGSSessionScreenIsLocked
+func macScreenLocked() (bool, error) {
+	cmd := exec.Command("ioreg", "-n", "Root", "-d1")
+	var outb bytes.Buffer
+	cmd.Stdout = &outb
+	if err := cmd.Run(); err != nil {
+		return false, err
+	}
+	return strings.Contains(outb.String(), `"CGSSessionScreenIsLocked"=Yes`), nil
+}
+
+//plistString escapes s for a property list
+func plistString(s string) string {
+	return "<string>" + html.EscapeString(s) + "</string>"
+}
+
+//launchdAgent returns a launchd property list running args, with more keys added
+func launchdAgent(label string, args []string, more string) string {
+	home, _ := os.UserHomeDir()
+	var program []string
+	for _, arg := range args {
+		program = append(program, "\t\t"+plistString(arg))
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	` + plistString(label) + `
+	<key>ProgramArguments</key>
+	<array>
+` + strings.Join(program, "\n") + `
+	</array>
+	<key>StandardErrorPath</key>
+	` + plistString(filepath.Join(home, "Library", "Logs", label+".log")) + `
+` + more + `</dict>
+</plist>
+`
+}
+
+var calendarWeekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+//calendarEvent matches the systemd calendar events launchd can also express: optional
+//weekdays, a date whose year and month are * and whose day is * or a number, and a time
+var calendarEvent = regexp.MustCompile(`^(?:([A-Za-z,]+) )?(?:\*-\*-(\*|[0-9]{1,2}) )?([0-9]{1,2}):([0-9]{2})(?::00)?$`)
+
+//launchdCalendar converts a schedule (a systemd calendar event, such as daily, weekly,
+//Mon *-*-* 07:00 or *-*-01 09:00) to launchd's StartCalendarInterval
+func launchdCalendar(schedule string) (string, error) {
+	switch strings.ToLower(schedule) {
+	case "hourly":
+		return launchdInterval(map[string]int{"Minute": 0}), nil
+	case "daily":
+		schedule = "*-*-* 00:00"
+	case "weekly":
+		schedule = "Mon *-*-* 00:00"
+	case "monthly":
+		schedule = "*-*-01 00:00"
+	}
+	m := calendarEvent.FindStringSubmatch(schedule)
+	if m == nil {
+		return "", errors.New("Invalid schedule for launchd: " + schedule + " (use e.g. Mon *-*-* 07:00 or *-*-01 09:00)")
+	}
+	interval := map[string]int{}
+	interval["Hour"], _ = strconv.Atoi(m[3])
+	interval["Minute"], _ = strconv.Atoi(m[4])
+	if m[2] != "" && m[2] != "*" {
+		interval["Day"], _ = strconv.Atoi(m[2])
+	}
+	if m[1] == "" {
+		return launchdInterval(interval), nil
+	}
+	//launchd takes a list of intervals for several weekdays
+	var intervals []string
+	for _, day := range strings.Split(m[1], ",") {
+		if len(day) < 3 {
+			return "", errors.New("Invalid weekday in schedule: " + day)
+		}
+		wd, ok := calendarWeekdays[strings.ToLower(day)[:3]]
+		if !ok {
+			return "", errors.New("Invalid weekday in schedule: " + day)
+		}
+		interval["Weekday"] = wd
+		intervals = append(intervals, launchdInterval(interval))
+	}
+	return "<array>\n\t\t" + strings.Join(intervals, "\n\t\t") + "\n\t</array>", nil
+}
+
+func launchdInterval(interval map[string]int) string {
+	answer := "<dict>"
+	for _, key := range []string{"Day", "Weekday", "Hour", "Minute"} {
+		if v, ok := interval[key]; ok {
+			answer += "<key>" + key + "</key><integer>" + strconv.Itoa(v) + "</integer>"
+		}
+	}
+	return answer + "</dict>"
+}
+
+//launchdAgents returns the launchd agents to run horolog's services for the tree at root,
+//the macOS equivalent of systemdUnits
+func launchdAgents(exe, root string, opts options) map[string]string {
+	keepAlive := "\t<key>RunAtLoad</key>\n\t<true/>\n\t<key>KeepAlive</key>\n\t<true/>\n"
+	working := "\t<key>WorkingDirectory</key>\n\t" + plistString(root) + "\n"
+	agents := map[string]string{
+		"com.horolog.serve.plist": launchdAgent("com.horolog.serve", []string{exe, "serve", root, "--addr=" + opts.get("addr", "localhost:8080")}, working+keepAlive),
+		"com.horolog.watch.plist": launchdAgent("com.horolog.watch", []string{exe, "watch"}, keepAlive),
+	}
+	for _, v := range scheduledViews() {
+		calendar, err := launchdCalendar(v.schedule)
+		if err != nil {
+			panic(err)
+		}
+		label := "com.horolog.snapshot." + v.name
+		agents[label+".plist"] = launchdAgent(label, []string{exe, "snapshot", "--view=" + v.name, "--out=" + v.out}, working+"\t<key>StartCalendarInterval</key>\n\t"+calendar+"\n")
+	}
+	return agents
+}
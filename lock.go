@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//locked periods, such as months which have been invoiced, are listed in .horolog/locks
+//with the period as it was given and its start and end, e.g.
+//	2024-03 2024-03-01T00:00:00+01:00 2024-04-01T00:00:00+02:00
+type lockedPeriod struct {
+	name string
+	from time.Time
+	to   time.Time
+}
+
+func locksPath(dir string) string {
+	return filepath.Join(treeRoot(dir), stateDirName, "locks")
+}
+
+func loadLocks(dir string) ([]lockedPeriod, error) {
+	var answer []lockedPeriod
+	f, err := os.Open(locksPath(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, errors.New("Invalid Lock: " + scanner.Text())
+		}
+		p := lockedPeriod{name: fields[0]}
+		if p.from, err = parsePeriodTime(fields[1]); err != nil {
+			return nil, err
+		}
+		if p.to, err = parsePeriodTime(fields[2]); err != nil {
+			return nil, err
+		}
+		answer = append(answer, p)
+	}
+	return answer, scanner.Err()
+}
+
+//errLocked is the error for changes to logs in locked periods
+var errLocked = errors.New("Period Locked")
+
+//unlocked returns an error if the time between start and end overlaps a locked period,
+//unless force is given, and whether it was forced so that the change can be audited as such
+func (t task) unlocked(start, end time.Time, force bool) (bool, error) {
+	locks, err := loadLocks(t.path())
+	if err != nil {
+		return false, err
+	}
+	for _, p := range locks {
+		if (p.to == never || start.Before(p.to)) && (p.from == never || end.After(p.from)) {
+			if !force {
+				return false, fmt.Errorf("%w: %s (use --force to change it anyway)", errLocked, p.name)
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//checkUnlocked is unlocked, panicking rather than returning the error
+func (t task) checkUnlocked(start, end time.Time, force bool) bool {
+	forced, err := t.unlocked(start, end, force)
+	if err != nil {
+		panic(err)
+	}
+	return forced
+}
+
+//logs are only ever written, renamed or removed with the functions below, which refuse to
+//change a log in a locked period unless force is given, so that no command can leave the
+//lock out. Each returns whether a lock was forced, so that the change can be audited as such.
+
+//logUnlocked checks the times in the name of the log at path, if it has valid ones
+func logUnlocked(path string, force bool) (bool, error) {
+	l := log(path)
+	if l.start() == never || l.end() == never {
+		return false, nil
+	}
+	return task(filepath.Dir(path)).unlocked(l.start(), l.end(), force)
+}
+
+//writeNewLog writes a log which must not exist yet, such as one of the same times from a
+//parallel session, removing it again if it cannot be written completely
+func writeNewLog(path string, note []byte, force bool) (bool, error) {
+	forced, err := logUnlocked(path, force)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return false, err
+	}
+	_, err = f.Write(note)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return forced, err
+}
+
+//rewriteLog replaces the note of an existing log
+func rewriteLog(path string, note []byte, force bool) (bool, error) {
+	forced, err := logUnlocked(path, force)
+	if err != nil {
+		return false, err
+	}
+	return forced, writeFileAtomic(path, note, 0644)
+}
+
+//renameLog moves a log to another task or times, which must not be taken already
+func renameLog(from, to string, force bool) (bool, error) {
+	forcedFrom, err := logUnlocked(from, force)
+	if err != nil {
+		return false, err
+	}
+	forcedTo, err := logUnlocked(to, force)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(to); err == nil {
+		return false, errors.New("Log Already Exists: " + to)
+	}
+	return forcedFrom || forcedTo, os.Rename(from, to)
+}
+
+//removeLog deletes a log
+func removeLog(path string, force bool) (bool, error) {
+	forced, err := logUnlocked(path, force)
+	if err != nil {
+		return false, err
+	}
+	return forced, os.Remove(path)
+}
+
+func lockCommand(args []string) {
+	_, positional := parseOptions(args)
+	if len(positional) == 0 {
+		locks, err := loadLocks(".")
+		if err != nil {
+			panic(err)
+		}
+		for _, p := range locks {
+			fmt.Println(p.name, formatPeriodTime(p.from), formatPeriodTime(p.to))
+		}
+		return
+	}
+	dir := "."
+	if len(positional) > 1 {
+		dir = positional[1]
+	}
+	if strings.ContainsAny(positional[0], " \t\n") {
+		panic(errors.New("Invalid Period: " + positional[0]))
+	}
+	from, to, err := parsePeriod(positional[0])
+	if err != nil {
+		panic(err)
+	}
+	defer lockTree(dir)()
+	f, err := os.OpenFile(locksPath(dir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(positional[0] + " " + formatPeriodTime(from) + " " + formatPeriodTime(to) + "\n")
+	if err != nil {
+		panic(err)
+	}
+	audit(dir, "lock", positional[0])
+	inform("Locked", positional[0])
+}
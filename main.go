@@ -1,29 +1,38 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
-)
 
-const timeLayout = "2006-01-02 15:04:05-07:00"
-const timeDelimiter = "=>"
+	"./format"
+)
 
 var never = time.Time{}
 
 //e,g, 2017-01-10 17:31:04+01:00 - 2017-01-10 17:31:08+01:00.txt
+//optionally followed by the author, e.g. ...17:31:08+01:00@alice.txt
+//or with portable names, 2017-01-10 17.31.04+0100 to 2017-01-10 17.31.08+0100.txt
+//and ending in .md rather than .txt for notes in Markdown (see FORMAT.md and format)
 type log string
 
 func loadLog(path string) (log, error) {
 	src, err := os.Stat(path)
 	l := log(path)
+	if _, conflict := syncConflictOriginal(filepath.Base(path)); conflict && err == nil {
+		return log(""), errors.New("Sync Conflict Copy: " + path + " (use horolog dedupe)")
+	}
 	if err != nil || src.IsDir() || l.start() == never || l.end() == never {
 		return log(""), errors.New("Invalid Log File: " + path)
 	}
@@ -36,6 +45,10 @@ func (l log) path() string {
 
 func (l log) dir() string {
 	dir, _ := filepath.Split(l.path())
+	//logs kept in .timelogs belong to the task it is in
+	if dir == logsDirName+"/" || strings.HasSuffix(dir, "/"+logsDirName+"/") {
+		dir = strings.TrimSuffix(dir, logsDirName+"/")
+	}
 	if dir == "" {
 		return "./"
 	}
@@ -44,7 +57,12 @@ func (l log) dir() string {
 
 func (l log) name() string {
 	_, name := filepath.Split(l.path())
-	return strings.TrimSuffix(name, ".txt")
+	for _, ext := range logExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
 }
 
 func (l log) text() string {
@@ -56,25 +74,25 @@ func (l log) text() string {
 }
 
 func (l log) start() time.Time {
-	nameSplit := strings.SplitN(l.name(), timeDelimiter, 2)
-
-	start, err := time.Parse(timeLayout, nameSplit[0])
-	if err != nil {
-		return never
-	}
-
+	start, _ := l.times()
 	return start
 }
 
 func (l log) end() time.Time {
-	nameSplit := strings.SplitN(l.name(), timeDelimiter, 2)
+	_, end := l.times()
+	return end
+}
 
-	end, err := time.Parse(timeLayout, nameSplit[1])
-	if err != nil {
-		return never
-	}
+//times parses the start and end of the log from its name, returning never for either if they are invalid
+func (l log) times() (time.Time, time.Time) {
+	start, end, _, _ := format.DecodeName(l.name())
+	return start, end
+}
 
-	return end
+//author returns the user who recorded the log, or "" if it is not attributed
+func (l log) author() string {
+	_, _, author, _ := format.DecodeName(l.name())
+	return author
 }
 
 func (l log) duration() time.Duration {
@@ -96,59 +114,57 @@ func loadTask(path string) (task, error) {
 	return task(path), nil
 }
 
+func loadOrCreateTask(path string) (task, error) {
+	t, err := loadTask(path)
+	if err != nil {
+		err = createTask(path)
+		if err != nil {
+			return task(""), err
+		}
+		t, err = loadTask(path)
+	}
+	return t, err
+}
+
 func (t task) path() string {
 	return string(t)
 }
 
-func (t task) recursiveDurationWithin(dur time.Duration) time.Duration {
-	var total time.Duration
-	for _, l := range t.logsWithin(dur) {
-		total += l.duration()
-	}
-	for _, t := range t.subtasks() {
-		total += t.recursiveDurationWithin(dur)
-	}
-	return total
+//logPath is the path of a new log in the task, attributed to the current user
+func (t task) logPath(start, end time.Time) string {
+	return t.authoredLogPath(start, end, t.user())
 }
 
-func (t task) durationWithin(dur time.Duration) time.Duration {
-	var total time.Duration
-	for _, l := range t.logsWithin(dur) {
-		total += l.duration()
-	}
-	return total
+//authoredLogPath is the path of a log in the task recorded by the given user
+func (t task) authoredLogPath(start, end time.Time, user string) string {
+	portable := t.setting("portable_names", strconv.FormatBool(minimalMode())) == "true"
+	return t.logsDir() + "/" + format.EncodeName(start, end, user, portable) + t.logExtension()
 }
 
-func (t task) summaryWithin(dur time.Duration) string {
-	var answer string
-	ls := t.logsWithin(dur)
-	if len(ls) > 0 {
-		answer += t.path() + " (" + t.durationWithin(dur).String() + ")\n"
+//textMatching is the notes of the matching logs in the task and its subtasks, under the
+//name and total of each task, reading up to workers notes at once. With titles, each
+//log is shown as a line with its title rather than its whole note.
+func (t task) textMatching(f filter, workers int, titles bool) string {
+	lines := t.summaryLines(f)
+	var ls logs
+	for _, line := range lines {
+		ls = append(ls, line.logs...)
 	}
+	notes := readNotes(ls, workers)
 
-	ts := t.subtasks()
-	for _, t2 := range ts {
-		answer += t2.summaryWithin(dur)
-	}
-	return answer
-}
-
-func (t task) textWithin(dur time.Duration) string {
 	var answer string
-	ls := t.logsWithin(dur)
-	if len(ls) > 0 {
-		title := t.path() + " (" + t.durationWithin(dur).String() + ")\n"
-		answer += title
-		for _, l := range ls {
-			answer += l.text()
+	for _, line := range lines {
+		answer += line.task.path() + " (" + line.logs.duration().String() + ")\n"
+		for _, l := range line.logs {
+			if titles {
+				answer += "\t" + titleLine(l, notes[0]) + "\n"
+			} else {
+				answer += notes[0]
+			}
+			notes = notes[1:]
 		}
 		answer += "\n"
 	}
-
-	ts := t.subtasks()
-	for _, t2 := range ts {
-		answer += t2.textWithin(dur)
-	}
 	return answer
 }
 
@@ -187,126 +203,819 @@ func (lbe logsByEnd) Swap(i, j int) {
 	lbe[j] = temp
 }
 
+//a filter reports whether a log should be included
+type filter func(l log) bool
+
+func within(dur time.Duration) filter {
+	return func(l log) bool {
+		return dur == 0 || l.end().After(time.Now().Add(-dur))
+	}
+}
+
+//between includes logs which overlap the period from-to, either of which may be never
+func between(from, to time.Time) filter {
+	return func(l log) bool {
+		return (from == never || l.end().After(from)) && (to == never || l.start().Before(to))
+	}
+}
+
+//defaultWindows applies the default_window setting of each log's task, so that tasks
+//which only matter for a short while are left out of show and summary once they are stale
+func defaultWindows() filter {
+	windows := map[string]time.Duration{}
+	return func(l log) bool {
+		window, ok := windows[l.dir()]
+		if !ok {
+			setting := task(l.dir()).setting("default_window", "")
+			if setting != "" {
+				var err error
+				window, err = parseDuration(setting)
+				if err != nil {
+					panic(errors.New("Invalid default_window for " + l.dir() + ": " + err.Error()))
+				}
+			}
+			windows[l.dir()] = window
+		}
+		return within(window)(l)
+	}
+}
+
 func (t task) logsWithin(dur time.Duration) logs {
+	return t.logsMatching(within(dur))
+}
+
+func (t task) logsMatching(f filter) logs {
+	debug("reading", t.path())
 	var answer logs
-	files, _ := ioutil.ReadDir(t.path())
-	for _, f := range files {
-		l, err := loadLog(t.path() + "/" + f.Name())
+	var read func(dir string)
+	read = func(dir string) {
+		files, err := ioutil.ReadDir(dir)
 		if err != nil {
-			continue
+			skip(dir, err)
 		}
-		if dur == 0 || l.end().After(time.Now().Add(-dur)) {
-			answer = append(answer, l)
+		for _, fi := range files {
+			if fi.Name() == logsDirName && dir == t.path() {
+				read(dir + "/" + logsDirName)
+				continue
+			}
+			if strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			path := dir + "/" + fi.Name()
+			if fi = t.followLink(path, fi); fi == nil || fi.IsDir() || t.ignores(fi.Name(), false) {
+				continue
+			}
+			l, err := loadLog(path)
+			if err != nil {
+				if looksLikeLog(fi.Name()) {
+					skip(path, err)
+				}
+				continue
+			}
+			if f(l) {
+				answer = append(answer, l)
+			}
 		}
 	}
+	read(t.path())
 	return answer
 }
 
 func (t task) recursiveLogsWithin(dur time.Duration) logs {
+	return t.recursiveLogsMatching(within(dur))
+}
+
+//recursiveLogsMatching lists the logs in the task and its subtasks which match f, as
+//forEachLog visits them, but with each task's logs sorted by path
+func (t task) recursiveLogsMatching(f filter) logs {
 	var answer logs
-	answer = append(answer, t.logsWithin(dur)...)
-	for _, t2 := range t.subtasks() {
-		answer = append(answer, t2.recursiveLogsWithin(dur)...)
+	first := 0
+	byName := func() {
+		ls := answer[first:]
+		sort.Slice(ls, func(i, j int) bool { return ls[i].path() < ls[j].path() })
+		first = len(answer)
+	}
+	t.forEachLog(f, func(l log) error {
+		if first < len(answer) && answer[first].dir() != l.dir() {
+			byName()
+		}
+		answer = append(answer, l)
+		return nil
+	})
+	byName()
+	return answer
+}
+
+//lastEnd is when the latest of the logs ended
+func (ls logs) lastEnd() time.Time {
+	var last time.Time
+	for _, l := range ls {
+		if l.end().After(last) {
+			last = l.end()
+		}
+	}
+	return last
+}
+
+func (ls logs) duration() time.Duration {
+	var total time.Duration
+	for _, l := range ls {
+		total += l.duration()
+	}
+	return total
+}
+
+//summaryByUser lists the time logged by each author, with unattributed logs listed as (unknown)
+func (ls logs) summaryByUser(indent string) string {
+	totals := map[string]time.Duration{}
+	var users []string
+	for _, l := range ls {
+		user := l.author()
+		if user == "" {
+			user = "(unknown)"
+		}
+		if _, ok := totals[user]; !ok {
+			users = append(users, user)
+		}
+		totals[user] += l.duration()
+	}
+	sort.Strings(users)
+	var answer string
+	for _, user := range users {
+		answer += indent + user + " (" + totals[user].String() + ")\n"
 	}
 	return answer
 }
 
 func (t task) subtasks() []task {
 	var answer []task
-	files, _ := ioutil.ReadDir(t.path())
+	files, err := ioutil.ReadDir(t.path())
+	if err != nil {
+		skip(t.path(), err)
+	}
 	for _, f := range files {
+		//hidden directories such as .horolog and .git are never tasks
+		if strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		if f = t.followLink(t.path()+"/"+f.Name(), f); f == nil || t.ignores(f.Name(), f.IsDir()) {
+			continue
+		}
 		t2, err := loadTask(t.path() + "/" + f.Name())
-		if err != nil {
+		if err != nil || t2.excluded() {
+			continue
+		}
+		answer = append(answer, t2)
+	}
+	return answer
+}
+
+//excludes are glob patterns for tasks to leave out, from the exclude setting and --exclude
+var excludes []string
+
+//takeExcludes removes any --exclude= arguments, adding them to excludes along with the exclude setting
+func takeExcludes(args []string) []string {
+	for _, pattern := range strings.Split(loadGlobalConfig().get("exclude", ""), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			excludes = append(excludes, pattern)
+		}
+	}
+	return takeExcludeArgs(args)
+}
+
+//takeExcludeArgs removes any --exclude= arguments, adding them to excludes
+func takeExcludeArgs(args []string) []string {
+	var answer []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--exclude=") {
+			excludes = append(excludes, strings.TrimPrefix(arg, "--exclude="))
+			continue
+		}
+		answer = append(answer, arg)
+	}
+	return answer
+}
+
+//excluded reports whether the task's path matches an exclude pattern. Patterns without a /
+//match a task of that name anywhere, and patterns ending in /** also match the task itself.
+func (t task) excluded() bool {
+	path := filepath.ToSlash(filepath.Clean(t.path()))
+	for _, pattern := range excludes {
+		pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+				return true
+			}
 			continue
 		}
+		if matchGlob(strings.TrimSuffix(pattern, "/**"), path) || matchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+//matchGlob matches a slash separated path against a pattern in which ** matches any number of directories
+func matchGlob(pattern, path string) bool {
+	patterns := strings.Split(pattern, "/")
+	paths := strings.Split(path, "/")
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		for ; i < len(patterns); i++ {
+			if patterns[i] == "**" {
+				for k := j; k <= len(paths); k++ {
+					if match(i+1, k) {
+						return true
+					}
+				}
+				return false
+			}
+			if j >= len(paths) {
+				return false
+			}
+			if ok, _ := filepath.Match(patterns[i], paths[j]); !ok {
+				return false
+			}
+			j++
+		}
+		return j == len(paths)
+	}
+	return match(0, 0)
+}
+
+func (t task) recursiveSubtasks() []task {
+	checkCanceled()
+	var answer []task
+	for _, t2 := range t.subtasks() {
 		answer = append(answer, t2)
+		answer = append(answer, t2.recursiveSubtasks()...)
 	}
 	return answer
 }
 
-//
-// func getScreenLockState() bool {
-// 	cmd := exec.Command("qdbus", "org.gnome.ScreenSaver", "/org/gnome/ScreenSaver", "org.gnome.ScreenSaver.GetActive")
-// 	var outb bytes.Buffer
-// 	cmd.Stdout = &outb
-// 	err := cmd.Run()
-// 	if err != nil {
-// 		panic(err)
-// 	}
-// 	if outb.String() == "true\n" {
-// 		return true
-// 	}
-// 	return false
-// }
+//stateDirName is the directory at the root of a tree in which horolog keeps its own records
+const stateDirName = ".horolog"
+
+//treeRoot returns the nearest directory at or above dir which contains a .horolog directory.
+//If there is none, it is the current directory if dir is beneath it (so that the state of
+//a new tree is not kept in whichever subtask was first changed), or else dir itself.
+func treeRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if src, err := os.Stat(filepath.Join(d, stateDirName)); err == nil && src.IsDir() {
+			if d == abs {
+				return dir
+			}
+			return d
+		}
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+	if wd, err := os.Getwd(); err == nil && strings.HasPrefix(abs, wd+string(filepath.Separator)) {
+		return "."
+	}
+	return dir
+}
+
+//stateDir returns the path of a directory within the .horolog directory of dir's tree, creating it if necessary
+func stateDir(dir string, elem ...string) string {
+	path := filepath.Join(append([]string{treeRoot(dir), stateDirName}, elem...)...)
+	err := os.MkdirAll(path, 0700)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
 
 func parseDurationArgument(arg string) time.Duration {
-	var dur time.Duration
 	args := strings.SplitN(arg, "=", 2)
 	if len(args) == 1 {
 		return 0
 	}
-	arg = args[1]
-	if len(arg) == 0 {
-		panic(errors.New("No duration specified"))
+	dur, err := parseDuration(args[1])
+	if err != nil {
+		panic(err)
+	}
+	return dur
+}
+
+//durationUnits are the units accepted by parseDuration. Months are 30 days and years 365 days.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+var durationComponent = regexp.MustCompile(`^([0-9]*\.?[0-9]+)(ns|us|µs|ms|mo|s|m|h|d|w|y)`)
+
+//parseDuration accepts compound durations with an optional sign (1d4h, -1w, 1.5h) as well as
+//natural durations (1 hour 30 min), using the units s, m, h, d, w, mo and y
+func parseDuration(arg string) (time.Duration, error) {
+	s := normalizeDuration(arg)
+	if len(s) == 0 {
+		return 0, errors.New("No duration specified")
 	}
-	if arg[len(arg)-1:len(arg)] == "d" {
-		days, err := strconv.Atoi(arg[:len(arg)-1])
-		if err != nil {
-			panic(err)
+	sign := time.Duration(1)
+	if s[0] == '-' || s[0] == '+' {
+		if s[0] == '-' {
+			sign = -1
 		}
-		dur = time.Hour * 24 * time.Duration(days)
-	} else {
-		var err error
-		dur, err = time.ParseDuration(arg)
+		s = s[1:]
+	}
+	var total time.Duration
+	for first := true; first || s != ""; first = false {
+		m := durationComponent.FindStringSubmatch(s)
+		if m == nil {
+			return 0, errors.New("Invalid Duration: " + arg + " (expected e.g. 1h30m, 2d, -1w, 3mo or \"45 min\")")
+		}
+		n, err := strconv.ParseFloat(m[1], 64)
 		if err != nil {
-			panic(err)
+			return 0, err
 		}
+		total += time.Duration(n * float64(durationUnits[m[2]]))
+		s = s[len(m[0]):]
+	}
+	return sign * total, nil
+}
+
+//options holds --name=value and --name arguments, which may appear anywhere among positional arguments
+type options map[string][]string
+
+func parseOptions(args []string) (options, []string) {
+	opts := options{}
+	var positional []string
+	for i, arg := range args {
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		nameValue := strings.SplitN(arg[2:], "=", 2)
+		if len(nameValue) == 1 {
+			nameValue = append(nameValue, "")
+		}
+		opts[nameValue[0]] = append(opts[nameValue[0]], nameValue[1])
+	}
+	return opts, positional
+}
+
+func (o options) has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
+
+//get returns the last value given for an option, or def if it was not given
+func (o options) get(name, def string) string {
+	if vs := o[name]; len(vs) > 0 {
+		return vs[len(vs)-1]
+	}
+	return def
+}
+
+func (o options) duration(name string) time.Duration {
+	if !o.has(name) {
+		return 0
+	}
+	dur, err := parseDuration(o.get(name, ""))
+	if err != nil {
+		panic(errors.New("Invalid --" + name + ": " + err.Error()))
 	}
 	return dur
 }
 
-func (t task) createLog() error {
-	fpath := os.TempDir() + "/" + strings.Replace(t.path(), "/", "⧸", -1) + ".log"
-	f, err := os.Create(fpath)
+//parsePeriod accepts today, yesterday, this-week, last-week, this-month (or month),
+//last-month, this-year (or year), last-year, a date, a month (2006-01), a year,
+//a range (from..to) or a duration before now. to is exclusive and may be never.
+func parsePeriod(arg string) (from, to time.Time, err error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	year := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	switch strings.ToLower(arg) {
+	case "", "all":
+		return never, never, nil
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case "week", "this-week":
+		return monday, monday.AddDate(0, 0, 7), nil
+	case "last-week":
+		return monday.AddDate(0, 0, -7), monday, nil
+	case "month", "this-month":
+		return month, month.AddDate(0, 1, 0), nil
+	case "last-month":
+		return month.AddDate(0, -1, 0), month, nil
+	case "year", "this-year":
+		return year, year.AddDate(1, 0, 0), nil
+	case "last-year":
+		return year.AddDate(-1, 0, 0), year, nil
+	}
+	if fromTo := strings.SplitN(arg, "..", 2); len(fromTo) == 2 {
+		if fromTo[0] != "" {
+			if from, _, err = parsePeriod(fromTo[0]); err != nil {
+				return never, never, err
+			}
+		}
+		if fromTo[1] != "" {
+			if _, to, err = parsePeriod(fromTo[1]); err != nil {
+				return never, never, err
+			}
+		}
+		return from, to, nil
+	}
+	if t, err := time.ParseInLocation("2006-01", arg, time.Local); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	if t, err := time.ParseInLocation("2006", arg, time.Local); err == nil {
+		return t, t.AddDate(1, 0, 0), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", arg, time.Local); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if dur, err := parseDuration(arg); err == nil {
+		return now.Add(-dur), never, nil
+	}
+	if t, err := parseDate(arg); err == nil {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		return day, day.AddDate(0, 0, 1), nil
+	}
+	return never, never, errors.New("Invalid Period: " + arg)
+}
+
+//sessionOptions control how createLog records a session
+type sessionOptions struct {
+	stopwatch bool
+	//tmuxRename names the tmux window after the task while the session runs
+	tmuxRename bool
+	stdin      bool
+	popup      bool
+	prompt     bool
+	//allowParallel starts an interactive session even if another is already running
+	allowParallel bool
+	//asBreak records the session as a break rather than as work
+	asBreak bool
+	//context is recorded in the note's front matter, if set
+	context string
+	//until, if set, stands in for the editor: the note is written to the session's note
+	//file by someone else (see rpc), and the session ends when it receives keep, discard or break
+	until <-chan string
+	//started, if set, is called once the session has started
+	started func(session)
+}
+
+func (t task) createLog(so sessionOptions) error {
+	//without an editor to pass Ctrl-C to, whoever ends the session also handles interrupts
+	if so.until == nil {
+		stopInterrupts()
+	}
+	recoverPowerLosses()
+	//sessions reading stdin are usually timing a command, and may run alongside anything
+	interactive := !so.stdin
+	unlock := lockTree(t.path())
+	if interactive && !so.allowParallel {
+		for _, s := range loadSessions() {
+			if s.interactive {
+				unlock()
+				return errors.New("Session Already Running: " + s.task + " (pid " + strconv.Itoa(s.pid) + "), use --allow-parallel to start another")
+			}
+		}
+	}
+	//the note is named after the task for the editor's sake, but is unique to the session,
+	//as there may be several sessions in the same task
+	f, err := ioutil.TempFile("", strings.Replace(t.path(), "/", "⧸", -1)+".*.log")
 	if err != nil {
+		unlock()
 		return err
 	}
 	f.Close()
+	fpath := f.Name()
+
+	startT := time.Now()
+	s, err := startSession(t, startT, interactive, fpath)
+	unlock()
+	if err != nil {
+		os.Remove(fpath)
+		return err
+	}
+	defer s.end()
+	if so.started != nil {
+		so.started(s)
+	}
+	stopStopwatch := func() {}
+	if so.stopwatch {
+		stopStopwatch = showStopwatch(t, startT)
+	}
+	restoreWindow := func() {}
+	if so.tmuxRename {
+		restoreWindow = renameTmuxWindow(t)
+	}
+	stopWatchingSleep := watchSleep()
 
-	editor := os.Getenv("EDITOR")
+	var noteErr error
+	chosen := ""
+	switch {
+	case so.until != nil:
+		chosen = <-so.until
+	case so.prompt:
+		noteErr = promptNote(t, fpath)
+	case so.stdin:
+		noteErr = captureNote(t, fpath)
+	case so.popup:
+		noteErr = popupNote(t, fpath)
+	default:
+		noteErr = editNote(t, s)
+	}
+	endT := time.Now()
+	stopStopwatch()
+	restoreWindow()
+	sleeps := stopWatchingSleep()
+	note, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		note = nil
+	}
+
+	//an empty note or a failed editor usually means the session was abandoned, and a
+	//very short session that it was started by accident
+	action := "keep"
+	if so.asBreak {
+		action = "break"
+	}
+	empty := strings.TrimSpace(string(note)) == ""
+	minDuration, err := parseDuration(t.setting("min_duration", "0s"))
+	if err != nil {
+		return errors.New("Invalid min_duration: " + err.Error())
+	}
+	switch {
+	case chosen != "":
+		action = chosen
+	case !interactive:
+	case endT.Sub(startT) < minDuration && empty:
+		action = "discard"
+	case endT.Sub(startT) < minDuration:
+		action = askAboutNote(t, "The session only lasted "+endT.Sub(startT).Round(time.Second).String())
+	case noteErr != nil || empty:
+		action = t.setting("empty_notes", "ask")
+		if action == "ask" {
+			problem := "The note is empty"
+			if noteErr != nil {
+				problem = "The editor failed (" + noteErr.Error() + ")"
+			}
+			action = askAboutNote(t, problem)
+			noteErr = nil
+		}
+	}
+	target := t
+	switch action {
+	case "keep":
+	case "discard":
+		os.Remove(fpath)
+		return noteErr
+	case "break":
+		target, err = loadOrCreateTask(t.breakTask().path())
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("Invalid empty_notes: " + action + " (use ask, keep, discard or break)")
+	}
+
+	//time spent on interruptions is logged in their own task (see interrupt), and time spent
+	//asleep, e.g. with the laptop's lid closed, can be left out, by logging the times either side
+	gaps := s.pauses(endT)
+	if len(sleeps) > 0 && interactive {
+		switch t.sleepAction(sleeps) {
+		case "keep":
+		case "split":
+			gaps = append(gaps, sleeps...)
+			sort.Slice(gaps, func(i, j int) bool { return gaps[i].start.Before(gaps[j].start) })
+		default:
+			return errors.New("Invalid on_sleep: " + t.setting("on_sleep", "") + " (use ask, keep or split)")
+		}
+	}
+	parts := []interval{{startT, endT}}
+	if len(gaps) > 0 {
+		if parts = awake(startT, endT, gaps); len(parts) == 0 {
+			//keep the note even if the whole session was interrupted
+			parts = []interval{{startT, endT}}
+		}
+	}
+	if so.context != "" {
+		note = addFrontMatter(note, "context", so.context)
+	}
+	defer lockTree(t.path())()
+	for _, part := range parts {
+		dpath := target.logPath(part.start, part.end)
+		if _, err := writeNewLog(dpath, note, false); err != nil {
+			return errors.New("Cannot Write Log: " + err.Error() + " (the note is kept in " + fpath + ")")
+		}
+		audit(t.path(), map[string]string{"keep": "log", "break": "break"}[action], treePath(dpath))
+	}
+	os.Remove(fpath)
+	return noteErr
+}
+
+//askAboutNote asks what to do with a session which may have been abandoned, returning
+//keep, discard or break, or keep if there is no terminal to ask on
+func askAboutNote(t task, problem string) string {
+	if src, err := os.Stdin.Stat(); err != nil || src.Mode()&os.ModeCharDevice == 0 {
+		return "keep"
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, problem+". Keep the log in "+t.path()+", discard it, or record it as a break? [k/d/b] ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "keep"
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "", "k", "keep":
+			return "keep"
+		case "d", "discard":
+			return "discard"
+		case "b", "break":
+			return "break"
+		}
+	}
+}
+
+//editor is the command used to edit notes in the task, from its editor setting, $VISUAL or $EDITOR
+func (t task) editor() []string {
+	editor := t.setting("editor", "")
 	if editor == "" {
-		editor = "vim"
+		editor = os.Getenv("VISUAL")
 	}
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := splitCommand(editor)
+	if len(args) == 0 {
+		return []string{"vim"}
+	}
+	return args
+}
 
-	editCmd := exec.Command(editor, fpath)
+//editNote runs the editor on the session's note, telling it about the session in
+//HOROLOG_TASK (relative to the root of the tree), HOROLOG_TASK_DIR, HOROLOG_START and
+//HOROLOG_NOTE_PATH, e.g. so that it can load snippets for the task
+func editNote(t task, s session) error {
+	editor := t.editor()
+	editCmd := exec.Command(editor[0], append(editor[1:], s.note)...)
+	editCmd.Env = s.environ()
 	editCmd.Stdin = os.Stdin
 	editCmd.Stdout = os.Stdout
 	editCmd.Stderr = os.Stderr
-	startT := time.Now()
-	defer func() {
-		endT := time.Now()
-		dpath := t.path() + "/" + startT.Format(timeLayout) + timeDelimiter + endT.Format(timeLayout) + ".txt"
-		cpCmd := exec.Command("cp", fpath, dpath)
-		err = cpCmd.Run()
-		if err != nil {
-			touchCmd := exec.Command("touch", dpath)
-			touchCmd.Run()
-		}
-	}()
-	err = editCmd.Start()
+	err := editCmd.Start()
 	if err != nil {
 		return err
 	}
-	err = editCmd.Wait()
+	return editCmd.Wait()
+}
+
+//captureNote reads the note from stdin until EOF, for when no editor is available
+func captureNote(t task, fpath string) error {
+	if src, err := os.Stdin.Stat(); err == nil && src.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintln(os.Stderr, "Logging "+t.path()+", end the note with Ctrl-D")
+	}
+	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_APPEND, 0600)
 	if err != nil {
 		return err
 	}
-
+	defer f.Close()
+	_, err = io.Copy(f, os.Stdin)
 	return err
 }
 
+func logCommand(args []string) {
+	opts, positional := parseOptions(args)
+	var so sessionOptions
+	dir := "."
+	for _, arg := range positional {
+		if arg == "-" {
+			so.stdin = true
+		} else {
+			dir = arg
+		}
+	}
+	t, err := loadOrCreateTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	so.stopwatch = opts.has("stopwatch") || t.setting("stopwatch", "") == "true"
+	so.tmuxRename = opts.has("tmux-rename") || t.setting("tmux_rename", "") == "true"
+	so.prompt = !so.stdin && (opts.has("prompt") || minimalMode())
+	so.allowParallel = opts.has("allow-parallel")
+	so.asBreak = opts.has("break")
+	so.context = sessionContext(t, opts)
+	err = t.createLog(so)
+	if err != nil {
+		panic(err)
+	}
+}
+
+//commands are invoked as horolog <command> [arguments], anything else is treated as a task
+var commands = map[string]func(args []string){
+	"query":            queryCommand,
+	"check":            checkCommand,
+	"summary":          summaryCommand,
+	"submit":           submitCommand,
+	"verify":           verifyCommand,
+	"verify-integrity": verifyIntegrityCommand,
+	"amend":            amendCommand,
+	"status":           statusCommand,
+	"log":              logCommand,
+	"popup":            popupCommand,
+	"digest":           digestCommand,
+	"feed":             feedCommand,
+	"serve":            serveCommand,
+	"export":           exportCommand,
+	"report":           reportCommand,
+	"compare":          compareCommand,
+	"chart":            chartCommand,
+	"forecast":         forecastCommand,
+	"invoice":          invoiceCommand,
+	"utilization":      utilizationCommand,
+	"overtime":         overtimeCommand,
+	"absence":          absenceCommand,
+	"holidays":         holidaysCommand,
+	"breaks":           breaksCommand,
+	"lock":             lockCommand,
+	"history":          historyCommand,
+	"bench":            benchCommand,
+	"copy":             copyCommand,
+	"plugins":          pluginsCommand,
+	"migrate":          migrateCommand,
+	"bot":              botCommand,
+	"autostart":        autostartCommand,
+	"rpc":              rpcCommand,
+	"prompt":           promptCommand,
+	"tmux-status":      tmuxStatusCommand,
+	"watch":            watchCommand,
+	"install-service":  installServiceCommand,
+	"snapshot":         snapshotCommand,
+	"views":            viewsCommand,
+	"board":            boardCommand,
+	"milestone":        milestoneCommand,
+	"milestones":       milestonesCommand,
+	"estimate-report":  estimateReportCommand,
+	"interrupt":        interruptCommand,
+	"back":             backCommand,
+	"interruptions":    interruptionsCommand,
+	"review":           reviewCommand,
+	"dedupe":           dedupeCommand,
+	"prune-notes":      pruneNotesCommand,
+	"remind":           remindCommand,
+	"import":           importCommand,
+	"bundle":           bundleCommand,
+}
+
 func main() {
-	args := os.Args[1:]
+	//errors are reported by panicking, so print them without a stack trace unless they are bugs
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+			fmt.Fprintln(os.Stderr, "horolog:", r)
+			os.Exit(2)
+		}
+	}()
+
+	catchInterrupts()
+	defer stopInterrupts()
+	defer warnSkipped()
+	args := takeExcludes(takeVerbosity(os.Args[1:]))
+	if len(args) > 0 && args[0] == "view" {
+		args = viewArgs(args[1:])
+	}
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			cmd(args[1:])
+			return
+		}
+	}
+	if len(args) > 0 {
+		if path, ok := findPlugin(args[0]); ok {
+			runPlugin(path, args[1:])
+			return
+		}
+	}
 	if len(args) > 0 && (args[0] == "--help" || args[0] == "-h") {
 		//print help
 		fmt.Println(`horolog v1.4
@@ -314,45 +1023,738 @@ func main() {
 Usage:
 	horolog task123/investigation
  		Starts logging in specified task
+	horolog task123/investigation --stopwatch
+		Also shows the elapsed time in the terminal (or tmux pane)
+		title while logging (or set stopwatch = true in the config)
+	horolog task123/investigation --tmux-rename
+		Names the tmux window after the task while logging, restoring
+		it afterwards (or set tmux_rename = true in the config)
+	horolog task123/investigation
+		Notes are edited with the editor setting (see Configuration),
+		$VISUAL or $EDITOR, which may include arguments, e.g. code --wait.
+		A note may begin with YAML front matter between --- lines, e.g.
+		tags: [review, urgent] or billable: false, which is available
+		to queries (as meta.tags), exports, reports and utilization
+	horolog log <task> -
+		Reads the note from stdin until EOF instead of running $EDITOR,
+		timing the session until then
+	horolog log <task> --allow-parallel
+		Starts logging even though another session (other than one
+		reading stdin) is already running, which is otherwise refused
+	horolog log <task> --break
+		Records the session as a break, e.g. lunch, which appears in
+		timelines and counts towards breaks, but not towards totals
+	horolog log <task> --context=train
+		Records where the session took place, e.g. office, home or
+		train, in the note's front matter (or set context = "home" in
+		the config), for summary --group-by=context
+	horolog log <task> --prompt
+		Asks for the note line by line, ending with an empty line, which
+		is the default in minimal mode (see Configuration)
+	ln -s ../archive/acme-2022 clients/acme
+		Tasks may be symbolic links to directories elsewhere, whose logs
+		then count in each place they appear. Links leading back to a
+		task containing them are left out with a warning
+	task123/.horologignore
+		Lists files and directories in the task and beneath it which
+		are never read, one pattern per line as in .gitignore, e.g.
+		node_modules/, /build or *.swp (with ! to read one after all),
+		so task directories can hold other things without slowing down
+		or confusing horolog
+	horolog <command> [arguments]
+		Runs one of the commands below (use ./name for a task which
+		shares its name with a command)
+	horolog <command> --verbose
+		Also prints what horolog is doing, such as the directories and
+		config files it reads, to stderr
+	horolog <command> --strict
+		Fails on the first file which looks like a log but cannot be
+		read, instead of leaving it out and warning afterwards
+	horolog <command> --quiet
+		Leaves out confirmations and other messages which are not the
+		result of the command, for scripts
+
+Commands:
+	query '<query>' [task]
+		Evaluates a query against all logs in a task, e.g.
+		  sum(duration) where task ~ "acme" and weekday in (sat,sun) since 2024-01-01
+		Aggregates are sum/avg/min/max(duration) and count(*). Fields
+		are task, text, user, type, weekday, hour, date, duration and meta.key
+		for front matter, compared with = != < <= > >= ~ !~ or in (...),
+		and combined with and/or/not. Also accepts since/until <date or
+		duration> and group by task/user/type/day/weekday/week/month/hour or
+		meta.key. With --roots (see summary below), tasks are prefixed by
+		the name of their tree
+	summary [task] --period=this-week --by-user
+		The same as --summary, but for a period (see check below)
+	summary [task] --group-by=issue --period=this-month
+		Shows the time spent on each issue referred to in notes or
+		front matter (e.g. ACME-123, #4567 or GH-owner/repo#12), and the
+		tasks it was spent in. Logs mentioning several issues count
+		towards each
+	summary [task] --group-by=context --period=last-month
+		Shows the time spent in each context (see log --context), such
+		as office and home
+	summary [task] --group-by=group --period=last-month
+		Shows the time in each reporting group, which tasks join by
+		setting group = "billable/acme" in their .horolog.conf, so that
+		reports can be arranged differently from the directories. Time
+		in billable/acme also counts towards billable
+	summary [task] --group-by=type --period=this-week
+		Shows the time spent on each type of work, given by a marker
+		at the start of the note such as [meeting], [focus] or
+		[interrupt] (or type: in front matter, or a symbol set with
+		type_markers), e.g. to see how much of a week was meetings
+	summary [task] --large --period=today
+		Shows the total in large digits, followed by a compact list of
+		tasks, for small screens
+	summary --roots=alice:/data/alice,bob:/data/bob
+		Merges several task trees into one summary, with each task
+		prefixed by the name given to its tree. The trees are read in
+		parallel. --roots alone uses the roots setting
+	digest [task] --period=last-week --mailto=me@example.com
+		Emails the summary of a period along with the notes of its
+		longest logs (or prints it without --mailto), e.g. from cron.
+		Uses the [smtp] section of the config (see Configuration)
+	import csv <file or -> [task] --map="start=1,end=2,task=3,note=4"
+		Adds a log for each row of a spreadsheet or another tool's
+		export, in a task (beneath the given one) named by the task
+		column. Columns are numbers, or names with --header, and may be
+		start, end or duration (e.g. 1h30m or 1.5), task, note and
+		author. Times are parsed with --time-format (a Go layout such as
+		"02/01/2006 15:04") or else as dates. --separator=";" changes
+		the separator, and --dry-run only lists what would be added
+	import hamster <hamster.db> [task]
+		Adds the activities recorded in Hamster (GNOME Time Tracker),
+		usually in ~/.local/share/hamster-applet/hamster.db, in tasks
+		named category/activity, with their descriptions and tags as
+		notes. Requires the sqlite3 command
+	import atimetracker <timetracker.db> [task]
+	import simpletimetracker <export.csv> [task]
+		Adds the time recorded on an Android phone with ATimeTracker
+		(from its database backup, using sqlite3) or Simple Time
+		Tracker (from its CSV export). Entries already imported are
+		skipped, so the same export can be imported again later
+	bundle create <file or -> [task]
+		Packs the task's logs, configs and (for the root of a tree) its
+		.horolog records into a gzipped tarball, with a manifest of
+		checksums, as a backup or to move them to another machine
+	bundle restore <file or -> [task] [--dry-run] [--force]
+		Unpacks a bundle into the task after checking its checksums.
+		Identical files are left alone, .horolog records are merged, and
+		nothing is restored if a log differs or is in a locked period
+		unless --force is given. The bundle must be in the tree's format
+		version
+	export [task] --format=csv --period=this-month --out=logs.csv
+		Writes one row per log (task, start, end, duration_seconds,
+		author, note, filename, and meta for the front matter) as csv,
+		json (one object per line) or sql (statements for sqlite3 or
+		duckdb). DuckDB can convert these to Parquet, e.g.
+		COPY 'logs.csv' TO 'logs.parquet'
+	export [task] --format=iif --period=last-month
+		Writes time for accounting software: iif (QuickBooks Desktop),
+		quickbooks-csv (QuickBooks Online) or lexoffice. Each task is
+		billed as its service_item, to the customer named by client,
+		at its rate (see Configuration)
+	export [task] --format=timeclock > time.timeclock
+		Writes i and o (clock in and out) lines for hledger or
+		ledger-cli, e.g. hledger -f time.timeclock balance, with each
+		task as an account (clients:acme) and the first line of the
+		note as the description
+	export [task] --format=grid --period=last-week
+		Writes a timesheet grid, as in Tempo or Workday, with a row for
+		each task (or group, with --group-by) and a column for each day
+		of the weeks, holding decimal hours, with totals, so that it can
+		be copied cell by cell or uploaded
+	export [task] --anonymize --salt=secret
+		Hashes task names (each level consistently, keeping the shape
+		of the tree), authors and groups, and leaves out notes, e.g. to
+		share a realistic dataset. A salt stops names being guessed
+	export [task] --group-by=issue
+		Repeats each log for each group it is in (see summary), with
+		the group in the group column, ordered by group
+	report [task] --template=weekly --period=this-week
+		Fills in ~/.config/horolog/templates/weekly.tmpl (or the given
+		file), a Go text/template, with .Root, .Period, .From, .To,
+		.Total, .Tasks (each with .Name, .Depth, .Total and .Logs),
+		.Logs (each with .Task, .Start, .End, .Duration, .Author, .Note
+		and .Meta), .Users (user to total) and .Milestones (each with
+		.Name, .Task, .At and .Time since the last). Functions hours, clock,
+		firstLine, title, indent, join and trim are available. Without
+		--template a plain report is shown
+	report [task] --format=html --period=last-month > report.html
+		Writes the report as a web page, with charts of the time per
+		day, the share of each subtask, and a heatmap of the weekdays
+		and hours it was logged. Notes of .md logs are shown as Markdown
+	feed [task] --period=30d --out=feed.xml
+		Writes an Atom feed of the most recent logs, with their
+		durations and the start of their notes
+	serve [task] --addr=localhost:8080 --roots=
+		Serves the feed at /feed.atom (optionally ?period=7d)
+		and the summary at /summary (optionally ?period=this-week),
+		which is kept up to date as logs change rather than recounted,
+		for live dashboards of large trees. With --roots, both span
+		every tree, each of which is loaded and watched separately.
+		It also takes activity events, e.g. from a browser extension
+		reporting the active tab, as a POST to /activity with
+		Content-Type: application/json and {"domain", "url", "title",
+		"at"} (only domain or url is needed), refusing any sent from
+		web pages. The [activity.name] rules (see Configuration) map
+		the domain to a task beneath the served one, in which events
+		less than activity_gap (default 5m) apart make one provisional
+		log, marked provisional: activity, with the titles as its note
+		With quick_add_token set, it also takes logs ending now from a
+		phone (e.g. an iOS Shortcut or Tasker, given --addr=:8080), as
+		POST /quick-add?task=clients/acme&minutes=30&note=... with the
+		header Authorization: Bearer <token>. duration=half an hour
+		may be given instead of minutes, and context=phone adds it to
+		the note's front matter. The reply is a sentence to show or say
+	submit [task] --period=last-week
+		Records a manifest of the contents of each log in the period
+		(in .horolog/submissions, in sha256sum format), so that later
+		changes can be detected
+	verify [task]
+		Reports submitted logs which have since been modified or
+		removed, and logs added to submitted periods, exiting with
+		status 1 if there are any
+	verify-integrity [task] --settle=24h
+		Records the checksum of each log once it ended more than
+		--settle ago (in .horolog/integrity.sha256), and reports logs
+		which have since been modified or removed, e.g. by bit rot or a
+		sync tool, exiting with status 1 if there are any. --update
+		accepts the changes instead, for intended edits
+	amend <task> <duration> [--from=<time>] [--end=<time>]
+		Retroactively adds time to a task, ending now or at --end, or
+		from --from until --end. Durations and times may be written
+		naturally, e.g. "45 min", --from="last monday 9am",
+		--end="yesterday 17:30" or --from="2 hours ago". A negative
+		duration, e.g. -30m, takes time off the task's latest logs
+		ending before now or --end, shortening, splitting or removing
+		them
+	amend <task> [day] <HH:MM-HH:MM>
+		Retroactively adds an exact interval to a task, today or on the
+		given day, e.g. 09:00-11:30 or 2024-01-05 9am-1:30pm
+	amend ... --break
+		Records the time as a break rather than as work (see log)
+	amend ... --context=office
+		Records where the time was spent (see log)
+	amend ... --force
+		Amends a locked period anyway, recording it in the audit log
+	review [period] [task] --provisional
+		Walks through the logs of the day (or period) in order, asking
+		whether to keep each, retag it (move it to another task),
+		adjust its times, or merge it into the log before. With
+		--provisional, only logs marked provisional (such as those made
+		from activity, see serve) are shown, and keeping one (or
+		changing it) confirms it
+	copy <log> <task> [--split=50%]
+		Duplicates a log into another task, e.g. for pair work, or with
+		--split moves that share of its time (from the end) into a
+		copy there instead. The link is kept in .horolog/copies, and
+		summaries can leave duplicates out with --no-copies
+	plugins
+		Lists the plugins on the PATH: programs named horolog-<name>,
+		which horolog <name> runs with the same arguments (unless there
+		is a command or task of that name). They are told the root of
+		the tree, the task, the --period and where to find this horolog
+		in the environment, and in more detail (including the logs in
+		the --period, as from export --format=json) in the JSON file
+		named by HOROLOG_REQUEST
+	migrate [task] --dry-run
+		Brings a tree written by an older horolog up to the format
+		version this one uses (see FORMAT.md), which it refuses to
+		change until then. The version is kept in .horolog/version,
+		and --dry-run lists the steps which would be taken
+	dedupe [task] --dry-run
+		Resolves the conflict copies made by sync tools such as
+		Syncthing and Dropbox, removing those identical to their
+		original and reporting the rest (which are left out until
+		merged by hand, exiting with status 1), and removes logs which
+		duplicate another log of the same times byte for byte. In
+		locked periods, duplicates are only removed with --force
+	prune-notes [task] --older-than=3y --keep-durations
+		Removes the text of notes of logs which ended before the
+		retention period (or the note_retention setting), keeping the
+		logs, and so their times and durations, as well as front
+		matter (unless --all-text). --truncate=80 keeps the start of
+		each note, --delete removes the logs instead, and --dry-run
+		only lists them. Logs in locked periods are only changed with
+		--force
+	lock <period> [task]
+		Closes a period (e.g. an invoiced month, lock 2024-03) so that
+		it can no longer be amended. Without arguments, lists locks
+	history [task] --period=this-month
+		Shows the changes made to the task and its subtasks (new and
+		amended logs, and for the whole tree, locks and absences), as
+		recorded in .horolog/audit.log
+	popup [task]
+		Shows a dialog (using zenity) to pick a task beneath the given
+		one, and then logs it until the note dialog is closed. Suitable
+		for binding to a global hotkey
+	status [--all]
+		Shows the task most recently started and its elapsed time, with
+		the number of other sessions running in parallel, e.g. for a
+		tmux status line: #(horolog status). --all lists every session
+	tmux-status --width=20
+		Prints a short line for tmux's status-right, e.g. set -g
+		status-right '#(horolog tmux-status)', with the task being
+		logged and its elapsed time, any interruption, and whether the
+		session is paused, or nothing when no session is running
+	rpc
+		For editor plugins: reads JSON-RPC 2.0 requests, one per line,
+		on stdin and replies on stdout, so that a plugin can run it
+		once as a job rather than running horolog for each action.
+		Methods: start {task, context, allow_parallel, break} starts
+		a session in the task (a directory); note {text, append}
+		replaces (or appends to) its note; stop {action} logs it, or
+		with action discard or break, discards it or logs it as a
+		break; status {} describes it, or else the newest session
+		running elsewhere, as {running, own, task, dir, start,
+		elapsed_seconds, note, paused}. The session is logged when
+		stdin is closed, e.g. when the editor exits
+	bot [task] --via=telegram
+		Answers messages on Telegram or Matrix (see [bot] in
+		Configuration) about the tree: "start acme fixing login" starts
+		logging the task (beneath the given one) with that note,
+		"note ..." adds to it, "stop" logs it ("stop discard" throws it
+		away), "status" says what is being logged, and "today?" (or
+		any period, e.g. "this-week?") lists the time logged in each
+		top level task. Sessions it starts are like any other, and are
+		logged when it stops
+	prompt --format="{task} {elapsed}"
+		Prints the newest session for a shell prompt, e.g.
+		PS1='$(horolog prompt) \$ ', or nothing when none is running.
+		It only reads the session files, never the tree, so it is fast
+		enough to run every time the prompt is drawn. The format may
+		use {task}, {path} (its directory), {elapsed}, {duration}
+		(e.g. 1h5m) and {minutes}. --width truncates {task} with …
+	prompt --format=starship
+		Ready-made output for prompt frameworks, with an icon and the
+		task truncated to --width (default 20): starship (⏱ emoji) or
+		powerline (a Nerd Font clock, for powerline-go, oh-my-posh or
+		tide). For starship, add to starship.toml:
+			[custom.horolog]
+			command = "horolog prompt --format=starship"
+			when = true
+			style = "bold yellow"
+	interrupt "prod incident" --task=interruptions
+		Pauses the running session and starts timing an interruption,
+		in the interrupt_task (see Configuration) unless --task is given
+	back
+		Ends the interruption, logging it with an [interrupt] marker,
+		and resumes the paused session, whose log leaves out the time
+		it was paused
+	interruptions [task] --period=this-week --switch-cost=15m
+		Counts the interruptions on each day, with the time they took
+		and the cost of switching back afterwards (or set switch_cost)
+	board [task] --columns=todo,doing,done
+		Shows the tasks beneath the task which have a status (set
+		status = "doing" in a task's .horolog.conf) as cards in a column
+		for each status, with the time logged in each card and column
+	board <task> --set=doing
+		Moves the task to another column, setting its status
+	view <name> [task] [options]
+		Runs a saved view (see [view.name] in Configuration), with any
+		options given overriding the view's, and a task given replacing
+		the view's task
+	views
+		Lists the saved views and what they run
+	watch --detector=auto --idle=5m --every=10s
+		The idle watcher: pauses running sessions while the user is
+		away, so that the time is left out of their logs. On Wayland
+		compositors with ext-idle-notify (e.g. sway) that is after the
+		idle time without input, and otherwise while the screen is
+		locked, checked every so often with GNOME's screensaver or on
+		macOS, IOKit, or on Windows, as the session notifies it.
+		--detector=wayland, gnome, macos or windows chooses one
+	watch --power
+		Also records how far each running session got whenever the
+		battery is critical (or set power_events = true), so that if the
+		computer dies or is hibernated the session is still logged up to
+		then, with ended: power in its front matter, the next time watch
+		or a session starts
+	autostart --when-path=~/src/acme --task=clients/acme --after=15m
+		Has watch track the task whenever files change in the directory
+		(e.g. a VS Code workspace), leaving out hidden directories and
+		node_modules, unless the task is already being logged. The
+		session stops, and is logged up to the last change, once
+		nothing has changed for --after. The note says where the files
+		changed. autostart lists the rules, and --remove=<path>
+		removes one
+	install-service --user [task] --addr=localhost:8080 --dry-run
+		Writes systemd user units for serve (on the task's tree), watch,
+		and a timer taking a snapshot of each view with a schedule (see
+		[view.name] in Configuration), which report readiness to systemd.
+		On macOS (or with --init=launchd) it writes launchd agents to
+		~/Library/LaunchAgents instead
+	snapshot --view=weekly --out=/srv/reports/{{date}}.html [task]
+		Runs a view unattended, e.g. from cron or a systemd timer, and
+		writes its output to the file, filling in {{date}}, {{time}},
+		{{week}}, {{month}} and {{view}}. It never prompts, records each
+		run in the file given by --log (or snapshot_log), and exits
+		with 0 if the file was written, 1 if it was written but the view
+		reported a problem, 2 if the snapshot could not be taken and 3
+		if the view failed (nothing is written)
+	milestone <task> "beta shipped" --at=
+		Records that the task reached a milestone, now or at the given
+		time. Milestones are shown in timelines, charts and reports
+	milestones [task]
+		Lists the milestones of the task and its subtasks, with the time
+		logged in them since the milestone before
+	estimate-report [task] --period=all
+		Compares the time logged in each task with an estimate (set
+		estimate = "12h" in its .horolog.conf) to the estimate, with
+		the difference as a percentage, and how many times their
+		estimates the tasks took overall. Estimates of subtasks are
+		taken to be part of their parent's
+	compare [task] --a=last-week --b=this-week --depth=1
+		Shows the time in each task during two periods, the change
+		between them, and which tasks are new or have been dropped
+	chart [task] --by=week --period=2024-01..2024-06
+		Plots the time logged in the task each day, week or month, as
+		bars in the terminal, or with --format=png --out=chart.png (or
+		--format=svg) as an image, e.g. to show a client how effort
+		ramped up
+	chart [task] --type=heatmap --format=svg --out=heatmap.svg
+		Draws the time logged in each hour of each weekday, or with
+		--type=pie each subtask's share of the time
+	forecast [task] --period=this-month --budget=20h
+		Projects the time logged in the task by the end of the period
+		from the rate so far, exiting with status 1 if it is on track
+		to exceed the budget (or the budget setting, see Configuration)
+	invoice [task] --period=last-month
+		Shows the time in each task and what it comes to at its rate,
+		in its currency (see Configuration)
+	invoice [task] --all-clients --convert=EUR
+		Shows a section for each client beneath the task (tasks whose
+		.horolog.conf sets client), with a total across clients.
+		--convert adds the totals in another currency, using the
+		exchange rates in fx_rates
+	utilization [task] --period=this-month
+		Shows billable time, total time and their ratio for each week,
+		where billable tasks are those with the billable setting (or
+		logs with billable: true in their front matter)
+	overtime [task] --period=this-month
+		Shows the time expected each week (from daily_hours or
+		weekly_hours, workdays and the calendar, see Configuration),
+		the time worked, and the surplus or deficit, up to today
+	absence <kind> <period> [task]
+		Records a full-day absence such as vacation or sick leave in
+		.horolog/absences, e.g. absence vacation 2024-07-01..2024-07-12.
+		Absences are left out of summaries and no work is expected on
+		them by overtime. Without arguments, lists recorded absences
+	holidays [task] --period=this-year
+		Lists the days off in the period from the calendar, holidays
+		and absences, on which overtime expects no work
+	breaks [task] --period=this-month --max-work=6h --min-break=30m
+		Lists days on which more than --max-work was logged with less
+		than --min-break of gaps between logs (counting gaps of at least
+		--min-gap=15m) and recorded breaks, exiting with status 1 if
+		there are any. --all lists every day
+	remind [task] --period=today --notify
+		Lists the recurring slots (see [remind.name] in Configuration)
+		which have ended without a log in their task that day, as
+		desktop notifications with --notify, exiting with status 1 if
+		there are any. Suitable for running every few minutes from cron
+	bench [task] --runs=3 --cpuprofile=cpu.out --memprofile=mem.out
+		Times scanning the tree, reading notes, loading and refreshing
+		the tree kept by serve, and generating a summary and report,
+		showing the first (cold cache), best and mean of the runs, to
+		measure performance on a machine or filesystem. Profiles can be
+		read with go tool pprof
+	check [task] --min=2h --max=8h --period=today --quiet
+		Exits with status 0 if the time logged in the task (or --task=)
+		during the period is within the thresholds, 1 if not, and 2 on
+		error. Periods are today, yesterday, this-week, last-week,
+		this-month, last-month, this-year, last-year, a date, a month
+		(2024-03), a year, a range (2024-01-01..2024-03) or a duration
 
 Options:
 	-s/--show
 		Displays total time, time of each subtask, and all logged text
 	-s=/--show=
 		The same as --show, but also filters out activity older than the
-		specified length of time (units are d/h/m/s)
+		specified length of time (units are y/mo/w/d/h/m/s)
+	-s --workers=8
+		Reads this many notes at once (or set read_workers), which is
+		much faster on network filesystems such as NFS or SSHFS
+	-s/-u --all
+		Includes logs older than the default_window of their task (see
+		Configuration), which are otherwise left out
 	-u/--summary
 		Only show total time and time of each subtask
 	-u=/--summary=
 		The same as --summary, but also filters out activity older than
-		the specified length of time (units are d/h/m/s)
+		the specified length of time (units are y/mo/w/d/h/m/s)
+	-u --by-user
+		Also breaks down the summary by the user who recorded each log
+		(set with user = name in ~/.config/horolog/config, or $USER)
+	-u --depth=2
+		Rolls up tasks more than this many levels below the task into
+		their ancestor at that level
+	-u --sort=duration
+		Orders tasks by their time (longest first), name, or recent
+		(most recently active first), rather than directory order
+	-u --percent
+		Shows each task's share of the total, and of its parent task
+	-u --overlap
+		Also shows how much of each user's time was logged in parallel
+		sessions. Parallel logs count in full towards each of their
+		tasks, so the total may be more than the time which passed
+	-u --no-copies
+		Leaves out logs duplicated into another task with copy
+	-u --roots=
+		Summarizes several trees at once (see summary below)
+	-u/query --cache=1m
+		Reuses the output of an identical command for this long (or set
+		cache_ttl), unless a task, log, config or record has changed
+		since, so that dashboards refreshing every few seconds are cheap.
+		Notes edited in place are only noticed once it expires
 	-t/--timeline
 		Displays time spent on tasks, in order
 	-t=/--timeline=
 		The same as --timeline, but also filters out activity older than
-		the specified lenght of time (units are d/h/m/s)
+		the specified length of time (units are y/mo/w/d/h/m/s)
+	-t --sort=start
+		Orders the timeline by when logs started, rather than ended
+	-s/-u/-t --titles
+		Shows each log on one line with its start, time and title (the
+		first line of its note) instead of its whole note
 	-a=/--ammend=
-		Retroactively adds the specified time to a task (can be negative)
+		Retroactively adds the specified time to a task (can be negative,
+		to take time off its latest logs, as with amend)
+	--exclude=archive/**
+		Leaves out tasks matching the pattern, which may be repeated and
+		used with any command. Patterns without a / match tasks of
+		that name anywhere, and ** matches any number of directories
 	-h/--help
-		Displays this text`)
+		Displays this text
+
+Configuration:
+	Settings are read from ~/.config/horolog/config, as lines of
+	key = "value", and may be overridden for a task and its subtasks
+	by a .horolog.conf file in the task's directory
+	editor = "code --wait"
+		The editor used for notes, which like any setting may be set for
+		a task and its subtasks in the task's .horolog.conf. It is run
+		with HOROLOG_TASK, HOROLOG_TASK_DIR, HOROLOG_START and
+		HOROLOG_NOTE_PATH set, e.g. for autocommands loading snippets
+	user = "alice"
+		The user logs are attributed to (defaults to $USER)
+	stopwatch = true
+		Always show the elapsed time in the terminal title
+	tmux_rename = true
+		Always name the tmux window after the task while logging
+	minimal = true
+		Asks for notes at a prompt instead of running an editor and
+		uses portable names, for Termux and other minimal terminals
+		(the default when $TERMUX_VERSION is set)
+	portable_names = true
+		Names new logs like 2017-01-10 17.31.04+0100 to ...txt,
+		avoiding characters which Android shared storage, FAT and
+		Windows reject (both kinds of name are always read)
+	default_window = "48h"
+		Only includes logs from this recent period in show and summary,
+		unless a period or --all is given. Usually set for a single
+		task, e.g. an inbox, in its .horolog.conf
+	roots = "work:~/work,personal:~/personal"
+		The trees used by --roots without a value
+	log_extension = "md"
+		Names new logs ...md rather than ...txt, for notes written in
+		Markdown. Logs with either extension are always read
+	timelogs = true
+		Writes new logs into a .timelogs directory within their task,
+		so that it can double as a project directory. Logs are read
+		from both, and files which do not look like logs are ignored
+	exclude = "archive/**, personal/**"
+		Tasks which are always left out, as with --exclude
+	estimate = "12h"
+		The time a task is expected to take, set in its .horolog.conf,
+		for estimate-report
+	status = "doing"
+		The column of board the task is in, set for a single task in
+		its .horolog.conf (it is not inherited by subtasks)
+	board_columns = "todo,doing,done"
+		The columns board shows first, in order
+	budget = "20h"
+		The monthly retainer used by forecast, set for a single task in
+		its .horolog.conf (it is not inherited by subtasks)
+	billable = true
+		Counts the task and its subtasks as billable in utilization
+	client = "Acme GmbH"
+		Makes the task a client for invoice --all-clients, set in its
+		own .horolog.conf (it is not inherited by subtasks)
+	rate = 95
+	currency = "EUR"
+		The hourly rate charged by invoice for the task and its
+		subtasks, which may set a different rate (e.g. for travel)
+	service_item = "Consulting"
+		The product or service the task's time is billed as in the
+		accounting exports (defaults to the task's path)
+	note_retention = "3y"
+		How long notes are kept before prune-notes removes their text
+	fx_rates = "~/.config/horolog/rates"
+		Exchange rates for invoice --convert, one per line, e.g.
+		EUR USD 1.0868 for 1 EUR = 1.0868 USD
+	daily_hours = "8h"
+	weekly_hours = "40h"
+	workdays = "mon,tue,wed,thu,fri"
+		The time expected to be worked on each workday, used by
+		overtime. weekly_hours is spread evenly over the workdays
+	calendar = "~/.config/horolog/calendar"
+		A file of days off, one per line, as a date or range and a
+		description, e.g. 2024-08-01..2024-08-14 Vacation
+	holidays = "~/.config/horolog/holidays-de-by.ics"
+		iCalendar files (separated by commas) whose all-day events are
+		days off, such as a region's public holiday calendar
+	read_workers = 8
+		How many notes --show reads at once
+	cache_ttl = "1m"
+		How long summaries and queries are cached (see --cache)
+	lock_timeout = "10s"
+		How long to wait for another horolog process which is changing
+		the tree (see .horolog/lock) before giving up
+	on_sleep = "ask"
+		What to do when the computer sleeps during a session: ask,
+		keep the time asleep in the log, or split the log into the
+		times before and after
+	min_duration = "60s"
+		Discards sessions shorter than this whose note is empty, and
+		asks whether to keep shorter sessions with a note
+	empty_notes = "ask"
+		What to do when a note is left empty or the editor fails: ask,
+		keep the log, discard it, or record it as a break (see
+		log --break)
+	context = "home"
+		Where sessions take place unless given with --context, usually
+		set in the config of each machine (or changed by a script)
+	group = "billable/acme, internal"
+		The reporting groups the task and its subtasks roll up into,
+		for --group-by=group
+	watch_interval = "10s"
+		How often watch checks whether the screen is locked
+	power_events = true
+	battery_critical = "5%"
+		Whether watch records sessions while the battery is critical,
+		and how little charge left counts as critical
+	idle_detector = "auto"
+	idle_timeout = "5m"
+		How watch tells that the user is away, and on Wayland, how long
+		without input counts as away
+	snapshot_log = "~/.local/state/horolog/snapshots.log"
+		Where snapshot records each run
+	interrupt_task = "interruptions"
+		The task interruptions are logged in, relative to the root of
+		the tree of the session they interrupt
+	switch_cost = "15m"
+		The time lost getting back into work after each interruption
+	type_markers = "📅=meeting, 🎯=focus"
+		Symbols at the start of notes which give their type, as well
+		as markers such as [meeting] (see summary --group-by=type)
+	issue_pattern = "\b(ACME-[0-9]+)\b"
+		A regular expression for the issues referred to in notes, used
+		by --group-by=issue, whose first group (or else the whole
+		match) is the issue, instead of the usual ticket references
+	quick_add_token = "a long random string"
+		The token phones must send to serve's /quick-add, best kept in
+		the global config rather than the tree
+	activity_gap = "5m"
+		How far apart activity events (see serve) can be and still be
+		part of the same provisional log
+	[smtp]
+	host = "smtp.example.com"
+	port = 587
+	username = "me@example.com"
+	password = "secret"
+	from = "me@example.com"
+		The mail server used by digest
+	[remind.standup]
+	task = "meetings/standup"
+	days = "mon,tue,wed,thu,fri"
+	at = "09:30"
+	duration = "15m"
+	every = "1w"
+	from = "2024-01-01"
+		A recurring slot checked by remind. every may be a number of
+		weeks, e.g. 2w for a biweekly retro, counted from the week of from
+	[bot]
+	telegram_token = "123456:ABC..."
+	telegram_chats = "987654321"
+		The Telegram bot (from @BotFather) and the chats it answers,
+		which it tells the id of when messaged from any other
+	matrix_homeserver = "https://matrix.example.org"
+	matrix_token = "syt_..."
+	matrix_room = "!abc:example.org"
+	matrix_user = "@me:example.org"
+		Or the Matrix account the bot uses, the room it answers in, and
+		the only user it answers
+	[view.weekly-client-report]
+	command = "summary clients --period=last-week --group-by=group"
+	description = "Time per client group last week"
+		A saved invocation run by view, e.g. a report with its filters,
+		grouping and format, so that it need not be typed again
+	schedule = "Mon *-*-* 07:00"
+	out = "~/reports/{{date}}.html"
+		When install-service's timer takes a snapshot of the view, as a
+		systemd calendar event, and where it is written
+	[activity.acme]
+	domains = "acme.com, *.acme.atlassian.net"
+	task = "clients/acme"
+		Activity in these domains (or beneath them) sent to serve is
+		recorded in the task, relative to the served one`)
 	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--timeline") || strings.HasPrefix(args[0], "-t")) {
 		dur := parseDurationArgument(args[0])
+		opts, positional := parseOptions(args[1:])
 		var dir string
-		if len(args) == 1 {
+		if len(positional) == 0 {
 			dir = "."
 		} else {
-			dir = args[1]
+			dir = positional[0]
 		}
 		t, err := loadTask(dir)
 		if err != nil {
 			panic(err)
 		}
-		ls := t.recursiveLogsWithin(dur)
-		sort.Sort(logsByEnd(ls))
+		ls := append(t.recursiveLogsWithin(dur), t.recordedBreaks(within(dur))...)
+		switch opts.get("sort", "end") {
+		case "start":
+			sort.Sort(logsByStart(ls))
+		case "end":
+			sort.Sort(logsByEnd(ls))
+		default:
+			panic(errors.New("Invalid --sort: " + opts.get("sort", "") + " (use start or end)"))
+		}
+		ms, err := loadMilestones(t)
+		if err != nil {
+			panic(err)
+		}
+		//milestones are shown among the logs, before any which start (or end) after them
+		showMilestones := func(before time.Time) {
+			for len(ms) > 0 && ms[0].at.Before(before) {
+				if dur == 0 || ms[0].at.After(time.Now().Add(-dur)) {
+					fmt.Println(ms[0].at, "◆ "+ms[0].name, "\t\t", ms[0].task)
+				}
+				ms = ms[1:]
+			}
+		}
 		for _, l := range ls {
-			fmt.Println(l.start(), l.duration(), "\t\t", l.dir())
+			if opts.get("sort", "end") == "start" {
+				showMilestones(l.start())
+			} else {
+				showMilestones(l.end())
+			}
+			label := l.dir()
+			if isBreak(l) {
+				label = breakLabel(l)
+			}
+			if opts.has("titles") {
+				fmt.Println(titleLine(l, l.text(), label))
+				continue
+			}
+			fmt.Println(l.start(), l.duration(), "\t\t", label)
 			fmt.Println(l.text())
 		}
+		showMilestones(time.Now())
 	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--ammend") || strings.HasPrefix(args[0], "-a")) {
 		dur := parseDurationArgument(args[0])
 		var dir string
@@ -361,46 +1763,28 @@ Options:
 		} else {
 			dir = args[1]
 		}
-		t, err := loadTask(dir)
+		t, err := loadOrCreateTask(dir)
 		if err != nil {
-			err = createTask(dir)
-			if err != nil {
-				panic(err)
-			}
-			t, err = loadTask(dir)
-			if err != nil {
-				panic(err)
-			}
+			panic(err)
 		}
-		startT := time.Now().Add(-dur)
 		endT := time.Now()
-		p := t.path() + "/" + startT.Format(timeLayout) + timeDelimiter + endT.Format(timeLayout) + ".txt"
-		touchCmd := exec.Command("touch", p)
-		touchCmd.Run()
-	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--show") || strings.HasPrefix(args[0], "-s")) {
-		var dir string
-		if len(args) == 1 {
-			dir = "."
-		} else {
-			dir = args[1]
+		defer lockTree(t.path())()
+		if dur < 0 {
+			t.takeOff(-dur, endT, false)
+			return
 		}
-		dur := parseDurationArgument(args[0])
-
-		//handle --show=dur
-		t, err := loadTask(dir)
+		l, _, err := t.amend(endT.Add(-dur), endT, nil, false)
 		if err != nil {
 			panic(err)
 		}
-
-		fmt.Println("Total: " + t.recursiveDurationWithin(dur).String() + "\n")
-		fmt.Println(t.textWithin(dur))
-
-	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--summary") || strings.HasPrefix(args[0], "-u")) {
+		audit(t.path(), "amend", treePath(l.path()))
+	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--show") || strings.HasPrefix(args[0], "-s")) {
+		opts, positional := parseOptions(args[1:])
 		var dir string
-		if len(args) == 1 {
+		if len(positional) == 0 {
 			dir = "."
 		} else {
-			dir = args[1]
+			dir = positional[0]
 		}
 		dur := parseDurationArgument(args[0])
 
@@ -409,31 +1793,25 @@ Options:
 		if err != nil {
 			panic(err)
 		}
+		f := within(dur)
+		if dur == 0 && !opts.has("all") {
+			f = defaultWindows()
+		}
 
-		fmt.Println("Total: " + t.recursiveDurationWithin(dur).String() + "\n")
-		fmt.Println(t.summaryWithin(dur))
-
-	} else {
-		var dir string
+		fmt.Println("Total: " + t.recursiveLogsMatching(f).duration().String() + "\n")
+		fmt.Println(t.textMatching(f, noteWorkers(t, opts), opts.has("titles")))
 
-		if len(args) == 0 {
-			dir = "."
-		} else {
-			dir = args[0]
+	} else if len(args) > 0 && (strings.HasPrefix(args[0], "--summary") || strings.HasPrefix(args[0], "-u")) {
+		opts, positional := parseOptions(args[1:])
+		dur := parseDurationArgument(args[0])
+		f := within(dur)
+		if dur == 0 && !opts.has("all") {
+			f = defaultWindows()
 		}
+		printSummary(f, opts, positional)
 
+	} else {
 		//handle creation
-		t, err := loadTask(dir)
-		if err != nil {
-			err = createTask(dir)
-			if err != nil {
-				panic(err)
-			}
-			t, err = loadTask(dir)
-			if err != nil {
-				panic(err)
-			}
-		}
-		t.createLog()
+		logCommand(args)
 	}
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"./format"
+)
+
+func TestLogNameExtensions(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("", 60*60))
+	end := start.Add(90 * time.Minute)
+	for _, ext := range logExtensions {
+		for _, portable := range []bool{false, true} {
+			for _, dir := range []string{"clients/acme", filepath.Join("clients/acme", logsDirName)} {
+				l := log(filepath.Join(dir, format.EncodeName(start, end, "alice", portable)+ext))
+				if !l.start().Equal(start) || !l.end().Equal(end) || l.author() != "alice" || l.duration() != 90*time.Minute {
+					t.Errorf("%s: got %v, %v, %q", l.path(), l.start(), l.end(), l.author())
+				}
+				if l.dir() != "clients/acme/" {
+					t.Errorf("%s: dir is %q rather than the task", l.path(), l.dir())
+				}
+			}
+		}
+	}
+}
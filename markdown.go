@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"html"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+)
+
+//logExtensions are the extensions logs may have, the first being the default. Logs
+//ending in .md are notes in Markdown, which the HTML report renders.
+var logExtensions = []string{".txt", ".md"}
+
+//logExtension is the extension of new logs in the task, from the log_extension setting
+func (t task) logExtension() string {
+	ext := t.setting("log_extension", logExtensions[0])
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for _, e := range logExtensions {
+		if ext == e {
+			return ext
+		}
+	}
+	panic(errors.New("Invalid log_extension: " + ext + " (use " + strings.Join(logExtensions, " or ") + ")"))
+}
+
+//markdown reports whether the log's note is written in Markdown
+func (l log) markdown() bool {
+	return strings.HasSuffix(l.path(), ".md")
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownBullet  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	markdownNumber  = regexp.MustCompile(`^\s*[0-9]+[.)]\s+(.*)$`)
+	markdownCode    = regexp.MustCompile("`([^`]+)`")
+	markdownStrong  = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	markdownEm      = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+//markdownInline renders the emphasis, code and links within a line, after escaping it
+func markdownInline(s string) string {
+	var code []string
+	//code spans are set aside so that nothing inside them is rendered
+	s = markdownCode.ReplaceAllStringFunc(s, func(m string) string {
+		code = append(code, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return "\x00"
+	})
+	s = html.EscapeString(s)
+	s = markdownLink.ReplaceAllStringFunc(s, func(m string) string {
+		parts := markdownLink.FindStringSubmatch(m)
+		href := html.UnescapeString(parts[2])
+		if i := strings.Index(href, ":"); i >= 0 && !strings.HasPrefix(href, "http:") && !strings.HasPrefix(href, "https:") && !strings.HasPrefix(href, "mailto:") && !strings.ContainsAny(href[:i], "/?#") {
+			//leave out links which would run script, such as javascript:
+			return parts[1]
+		}
+		return `<a href="` + html.EscapeString(href) + `">` + parts[1] + `</a>`
+	})
+	s = markdownStrong.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = markdownEm.ReplaceAllString(s, "<em>$1$2</em>")
+	for _, c := range code {
+		s = strings.Replace(s, "\x00", c, 1)
+	}
+	return s
+}
+
+//renderMarkdown renders the common parts of Markdown (headings, paragraphs, lists, code
+//blocks, emphasis, code and links) as HTML, escaping everything else
+func renderMarkdown(note string) htmltemplate.HTML {
+	var out, paragraph strings.Builder
+	list := ""
+	closeBlocks := func() {
+		if paragraph.Len() > 0 {
+			out.WriteString("<p>" + markdownInline(strings.TrimSpace(paragraph.String())) + "</p>\n")
+			paragraph.Reset()
+		}
+		if list != "" {
+			out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	startList := func(kind string) {
+		if list != kind {
+			closeBlocks()
+			out.WriteString("<" + kind + ">\n")
+			list = kind
+		}
+	}
+
+	lines := strings.Split(strings.TrimRight(note, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t\r")
+		switch {
+		case strings.HasPrefix(line, "```"):
+			closeBlocks()
+			out.WriteString("<pre><code>")
+			for i++; i < len(lines) && !strings.HasPrefix(lines[i], "```"); i++ {
+				out.WriteString(html.EscapeString(lines[i]) + "\n")
+			}
+			out.WriteString("</code></pre>\n")
+		case line == "":
+			closeBlocks()
+		case markdownHeading.MatchString(line):
+			closeBlocks()
+			m := markdownHeading.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			out.WriteString("<h" + level + ">" + markdownInline(m[2]) + "</h" + level + ">\n")
+		case markdownBullet.MatchString(line):
+			startList("ul")
+			out.WriteString("<li>" + markdownInline(markdownBullet.FindStringSubmatch(line)[1]) + "</li>\n")
+		case markdownNumber.MatchString(line):
+			startList("ol")
+			out.WriteString("<li>" + markdownInline(markdownNumber.FindStringSubmatch(line)[1]) + "</li>\n")
+		default:
+			if list != "" {
+				closeBlocks()
+			}
+			paragraph.WriteString(line + "\n")
+		}
+	}
+	closeBlocks()
+	//the note has been escaped, apart from the markup added here
+	return htmltemplate.HTML(out.String())
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"./format"
+)
+
+//the format version of a tree (see FORMAT.md) is kept in .horolog/version, and trees from
+//before it was recorded are version 1. horolog only changes trees in its own version: an
+//older tree must be brought up to date with migrate, and a newer one needs a newer horolog.
+
+//a migration brings a tree from the version before to its version
+type migration struct {
+	version     int
+	description string
+	apply       func(root string) error
+}
+
+//migrations are the steps up to format.Version, oldest first, e.g. renaming logs when the
+//name format changes, or adding front matter which a new version relies on. None are
+//needed yet, as the format is still the first.
+var migrations []migration
+
+func versionPath(dir string) string {
+	return filepath.Join(treeRoot(dir), stateDirName, "version")
+}
+
+//treeVersion is the format version of dir's tree
+func treeVersion(dir string) (int, error) {
+	b, err := ioutil.ReadFile(versionPath(dir))
+	if os.IsNotExist(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || v < 1 {
+		return 0, errors.New("Invalid Format Version: " + versionPath(dir))
+	}
+	return v, nil
+}
+
+func recordVersion(dir string, v int) error {
+	return ioutil.WriteFile(filepath.Join(stateDir(dir), "version"), []byte(strconv.Itoa(v)+"\n"), 0600)
+}
+
+func tooNew(dir string, v int) error {
+	return fmt.Errorf("Tree Too New: %s is in format version %d, but this horolog only knows up to %d (upgrade horolog)", treeRoot(dir), v, format.Version)
+}
+
+//checkVersion panics unless dir's tree is in this horolog's format version, recording
+//the version if it has not been yet
+func checkVersion(dir string) {
+	v, err := treeVersion(dir)
+	if err != nil {
+		panic(err)
+	}
+	if v > format.Version {
+		panic(tooNew(dir, v))
+	}
+	if v < format.Version {
+		panic(fmt.Errorf("Tree Needs Migrating: %s is in format version %d rather than %d (run horolog migrate)", treeRoot(dir), v, format.Version))
+	}
+	if _, err := os.Stat(versionPath(dir)); os.IsNotExist(err) {
+		if err := recordVersion(dir, v); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//migrateCommand applies the migrations the tree has not had yet, recording the version
+//after each so that an interrupted migration carries on where it stopped
+func migrateCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	if _, err := loadTask(dir); err != nil {
+		panic(err)
+	}
+	defer takeTreeLock(dir)()
+	v, err := treeVersion(dir)
+	if err != nil {
+		panic(err)
+	}
+	if v > format.Version {
+		panic(tooNew(dir, v))
+	}
+	var pending []migration
+	for _, m := range migrations {
+		if m.version > v {
+			pending = append(pending, m)
+		}
+	}
+	if opts.has("dry-run") {
+		for _, m := range pending {
+			fmt.Println(m.version, m.description)
+		}
+		return
+	}
+	for _, m := range pending {
+		inform("Migrating", treeRoot(dir), "to format version", strconv.Itoa(m.version)+":", m.description)
+		if err := m.apply(treeRoot(dir)); err != nil {
+			panic(errors.New("Migration to format version " + strconv.Itoa(m.version) + " failed: " + err.Error()))
+		}
+		if err := recordVersion(dir, m.version); err != nil {
+			panic(err)
+		}
+		audit(dir, "migrate", strconv.Itoa(m.version))
+	}
+	if len(pending) > 0 {
+		//cached output was worked out from the tree in its old format
+		if err := os.RemoveAll(filepath.Join(treeRoot(dir), stateDirName, "cache")); err != nil {
+			panic(err)
+		}
+	} else {
+		if err := recordVersion(dir, v); err != nil {
+			panic(err)
+		}
+		inform(treeRoot(dir), "is already in format version", v)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//a milestone marks a point in a task's history, such as a release, so that the time
+//leading up to it can be totalled
+type milestone struct {
+	at   time.Time
+	task string
+	name string
+}
+
+//milestones are kept in .horolog/milestones, with one tab separated line per milestone:
+//	time task name
+//where the time is RFC 3339 and the task is relative to the root of the tree
+func milestonesPath(dir string) string {
+	return filepath.Join(treeRoot(dir), stateDirName, "milestones")
+}
+
+//loadMilestones returns the milestones of t and its subtasks, oldest first
+func loadMilestones(t task) ([]milestone, error) {
+	var answer []milestone
+	f, err := os.Open(milestonesPath(t.path()))
+	if os.IsNotExist(err) {
+		return answer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefix := treePath(t.path())
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		if prefix != "." && fields[1] != prefix && !strings.HasPrefix(fields[1], prefix+"/") {
+			continue
+		}
+		answer = append(answer, milestone{at.Local(), fields[1], fields[2]})
+	}
+	sort.SliceStable(answer, func(i, j int) bool { return answer[i].at.Before(answer[j].at) })
+	return answer, scanner.Err()
+}
+
+//sinceMilestones is the time logged in t and its subtasks in logs which started after
+//the previous milestone (or at any time, for the first) and before each one
+func sinceMilestones(t task, ms []milestone) []time.Duration {
+	answer := make([]time.Duration, len(ms))
+	if len(ms) == 0 {
+		return answer
+	}
+	for _, l := range t.recursiveLogsMatching(between(never, ms[len(ms)-1].at)) {
+		i := sort.Search(len(ms), func(i int) bool { return ms[i].at.After(l.start()) })
+		if i < len(ms) {
+			answer[i] += l.duration()
+		}
+	}
+	return answer
+}
+
+//withMilestones labels each bar with the milestones reached during it
+func withMilestones(bars []chartBar, ms []milestone, by string) []chartBar {
+	for _, m := range ms {
+		b := bucketStart(m.at, by)
+		for i := range bars {
+			if bars[i].start.Equal(b) {
+				bars[i].milestones = append(bars[i].milestones, m.name)
+			}
+		}
+	}
+	return bars
+}
+
+func milestoneCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if len(positional) != 2 || strings.TrimSpace(positional[1]) == "" {
+		panic(errors.New("Usage: horolog milestone <task> <name> [--at=]"))
+	}
+	t, err := loadTask(positional[0])
+	if err != nil {
+		panic(err)
+	}
+	at := time.Now()
+	if opts.has("at") {
+		if at, err = parseDate(opts.get("at", "")); err != nil {
+			panic(errors.New("Invalid --at: " + opts.get("at", "")))
+		}
+	}
+	name := strings.Join(strings.Fields(positional[1]), " ")
+	defer lockTree(t.path())()
+
+	f, err := os.OpenFile(milestonesPath(t.path()), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(at.Format(time.RFC3339) + "\t" + treePath(t.path()) + "\t" + name + "\n"); err != nil {
+		panic(err)
+	}
+	audit(t.path(), "milestone", treePath(t.path()), name)
+	inform(at.Format("2006-01-02 15:04"), "\t\t", name)
+}
+
+//milestonesCommand lists the milestones of a task and its subtasks, with the time logged
+//since the one before, and the time logged since the last
+func milestonesCommand(args []string) {
+	_, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	ms, err := loadMilestones(t)
+	if err != nil {
+		panic(err)
+	}
+	if len(ms) == 0 {
+		inform("No milestones in", t.path())
+		return
+	}
+	for i, d := range sinceMilestones(t, ms) {
+		fmt.Println(ms[i].at.Format("2006-01-02 15:04"), formatHoursMinutes(d), "\t"+ms[i].name, "\t"+ms[i].task)
+	}
+	var since time.Duration
+	for _, l := range t.recursiveLogsMatching(func(l log) bool { return !l.start().Before(ms[len(ms)-1].at) }) {
+		since += l.duration()
+	}
+	fmt.Println(formatHoursMinutes(since), "since", ms[len(ms)-1].name)
+}
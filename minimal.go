@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//minimalMode avoids anything which needs a desktop terminal, for Termux and the like
+func minimalMode() bool {
+	return loadGlobalConfig().get("minimal", strconv.FormatBool(os.Getenv("TERMUX_VERSION") != "")) == "true"
+}
+
+//promptNote asks for the note a line at a time, ending at an empty line or EOF,
+//which is easier than an editor or Ctrl-D on a phone keyboard
+func promptNote(t task, fpath string) error {
+	fmt.Println("Logging " + t.path() + " since " + time.Now().Format("15:04"))
+	fmt.Println("Type the note, then an empty line to stop:")
+	var note string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			break
+		}
+		note += scanner.Text() + "\n"
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, []byte(note), 0600)
+}
+
+//formatHoursMinutes formats a duration as h:mm
+func formatHoursMinutes(d time.Duration) string {
+	d = d.Round(time.Minute)
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	return fmt.Sprintf("%s%d:%02d", sign, int(d.Hours()), int(d.Minutes())%60)
+}
+
+var bigGlyphs = map[rune][5]string{
+	'0': {"███", "█ █", "█ █", "█ █", "███"},
+	'1': {" █ ", "██ ", " █ ", " █ ", "███"},
+	'2': {"███", "  █", "███", "█  ", "███"},
+	'3': {"███", "  █", "███", "  █", "███"},
+	'4': {"█ █", "█ █", "███", "  █", "  █"},
+	'5': {"███", "█  ", "███", "  █", "███"},
+	'6': {"███", "█  ", "███", "█ █", "███"},
+	'7': {"███", "  █", "  █", "  █", "  █"},
+	'8': {"███", "█ █", "███", "█ █", "███"},
+	'9': {"███", "█ █", "███", "  █", "███"},
+	':': {" ", "█", " ", "█", " "},
+	'-': {"   ", "   ", "███", "   ", "   "},
+	'h': {"█  ", "█  ", "███", "█ █", "█ █"},
+}
+
+//bigText renders digits in a block font five lines high
+func bigText(s string) string {
+	var lines [5]string
+	for _, r := range s {
+		glyph, ok := bigGlyphs[r]
+		if !ok {
+			continue
+		}
+		for i := range lines {
+			lines[i] += glyph[i] + " "
+		}
+	}
+	return strings.Join(lines[:], "\n") + "\n"
+}
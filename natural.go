@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationWords = map[string]string{
+	"ms": "ms", "millisecond": "ms", "milliseconds": "ms",
+	"s": "s", "sec": "s", "secs": "s", "second": "s", "seconds": "s",
+	"m": "m", "min": "m", "mins": "m", "minute": "m", "minutes": "m",
+	"h": "h", "hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+	"d": "d", "day": "d", "days": "d",
+	"w": "w", "wk": "w", "wks": "w", "week": "w", "weeks": "w",
+	"mo": "mo", "month": "mo", "months": "mo",
+	"y": "y", "yr": "y", "yrs": "y", "year": "y", "years": "y",
+}
+
+var durationPart = regexp.MustCompile(`^(-?[0-9]*\.?[0-9]+)\s*([a-z]+)`)
+
+//normalizeDuration turns natural durations such as "45 min", "1 hour and 30 minutes"
+//or "half an hour" into the form expected by time.ParseDuration, returning anything
+//it does not understand unchanged
+func normalizeDuration(arg string) string {
+	s := strings.ToLower(strings.TrimSpace(arg))
+	s = strings.NewReplacer("half an hour", "30m", "an hour", "1h", "a day", "1d", "a week", "1w").Replace(s)
+	var answer string
+	for s != "" {
+		m := durationPart.FindStringSubmatch(s)
+		if m == nil {
+			return arg
+		}
+		unit, ok := durationWords[m[2]]
+		if !ok {
+			return arg
+		}
+		answer += m[1] + unit
+		s = strings.TrimLeft(s[len(m[0]):], " ,")
+		s = strings.TrimPrefix(s, "and ")
+	}
+	return answer
+}
+
+var clockTime = regexp.MustCompile(`^([0-9]{1,2})(?::([0-9]{2}))?(?::([0-9]{2}))?(am|pm)?$`)
+
+func parseClock(s string) (hour, min, sec int, ok bool) {
+	switch s {
+	case "noon", "midday":
+		return 12, 0, 0, true
+	case "midnight":
+		return 0, 0, 0, true
+	}
+	m := clockTime.FindStringSubmatch(s)
+	if m == nil || (m[2] == "" && m[4] == "") {
+		return 0, 0, 0, false
+	}
+	hour, _ = strconv.Atoi(m[1])
+	min, _ = strconv.Atoi(m[2])
+	sec, _ = strconv.Atoi(m[3])
+	if m[4] != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0, false
+		}
+		hour %= 12
+		if m[4] == "pm" {
+			hour += 12
+		}
+	}
+	return hour, min, sec, hour < 24 && min < 60 && sec < 60
+}
+
+//parseDate accepts a day and/or a time of day in local time, e.g.
+//	2024-01-05, 2024-01-05 09:00, today, yesterday 17:30, last monday 9am,
+//	friday noon, 2 hours ago, in 10 minutes or now
+//a day on its own means midnight, and a time on its own means today
+func parseDate(arg string) (time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		t, err := time.ParseInLocation(layout, arg, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	invalid := errors.New("Invalid Date: " + arg)
+
+	words := strings.Fields(strings.ToLower(arg))
+	if len(words) == 0 {
+		return never, invalid
+	}
+	if words[0] == "now" && len(words) == 1 {
+		return now, nil
+	}
+	if words[len(words)-1] == "ago" {
+		dur, err := parseDuration(strings.Join(words[:len(words)-1], " "))
+		if err != nil {
+			return never, invalid
+		}
+		return now.Add(-dur), nil
+	}
+	if words[0] == "in" {
+		dur, err := parseDuration(strings.Join(words[1:], " "))
+		if err != nil {
+			return never, invalid
+		}
+		return now.Add(dur), nil
+	}
+
+	day := today
+	var hour, min, sec int
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case w == "at" || w == "on":
+		case w == "today":
+		case w == "yesterday":
+			day = today.AddDate(0, 0, -1)
+		case w == "tomorrow":
+			day = today.AddDate(0, 0, 1)
+		case (w == "last" || w == "next" || w == "this") && i+1 < len(words):
+			wd, err := parseWeekday(words[i+1])
+			if err != nil {
+				return never, invalid
+			}
+			day = relativeWeekday(today, wd, w)
+			i++
+		default:
+			if wd, err := parseWeekday(w); err == nil {
+				day = relativeWeekday(today, wd, "")
+				break
+			}
+			if t, err := time.ParseInLocation("2006-01-02", w, time.Local); err == nil {
+				day = t
+				break
+			}
+			if i+1 < len(words) && (words[i+1] == "am" || words[i+1] == "pm") {
+				w += words[i+1]
+				i++
+			}
+			h, m, s, ok := parseClock(w)
+			if !ok {
+				return never, invalid
+			}
+			hour, min, sec = h, m, s
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, 0, time.Local), nil
+}
+
+//relativeWeekday finds the weekday nearest to today in the given direction: "last" is
+//strictly before today, "next" strictly after, "this" within the current week, and ""
+//means the most recent, which may be today
+func relativeWeekday(today time.Time, wd time.Weekday, direction string) time.Time {
+	diff := int(wd) - int(today.Weekday())
+	switch direction {
+	case "last":
+		if diff >= 0 {
+			diff -= 7
+		}
+	case "next":
+		if diff <= 0 {
+			diff += 7
+		}
+	case "this":
+		diff = (int(wd)+6)%7 - (int(today.Weekday())+6)%7
+	default:
+		if diff > 0 {
+			diff -= 7
+		}
+	}
+	return today.AddDate(0, 0, diff)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+//inLocation makes the named zone local for the rest of the test, e.g. to cross a change
+//to or from daylight saving time
+func inLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+	return loc
+}
+
+func TestParseDuration(t *testing.T) {
+	for _, test := range []struct {
+		arg  string
+		want time.Duration
+	}{
+		{"45m", 45 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"1.5h", 90 * time.Minute},
+		{".5h", 30 * time.Minute},
+		{"1d4h", 28 * time.Hour},
+		{"-1w", -7 * 24 * time.Hour},
+		{"+2d", 48 * time.Hour},
+		{"-30m", -30 * time.Minute},
+		{"3mo", 90 * 24 * time.Hour},
+		{"1y", 365 * 24 * time.Hour},
+		{"250ms", 250 * time.Millisecond},
+		{"45 min", 45 * time.Minute},
+		{"45 Minutes", 45 * time.Minute},
+		{"1 hour 30 min", 90 * time.Minute},
+		{"1 hour and 30 minutes", 90 * time.Minute},
+		{"2 hrs, 15 mins", 135 * time.Minute},
+		{"half an hour", 30 * time.Minute},
+		{"an hour", time.Hour},
+		{"a day", 24 * time.Hour},
+		{"a week", 7 * 24 * time.Hour},
+		{"2 weeks", 14 * 24 * time.Hour},
+		{"-2 days", -48 * time.Hour},
+		{"  10s  ", 10 * time.Second},
+		{"0m", 0},
+	} {
+		got, err := parseDuration(test.arg)
+		if err != nil || got != test.want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v", test.arg, got, err, test.want)
+		}
+	}
+	for _, arg := range []string{
+		"",
+		" ",
+		"h",
+		"10",
+		"-",
+		"1x",
+		"1 fortnight",
+		"--1h",
+		"1h-30m",
+		"1h 30",
+		"1 hour and",
+		"an hour and a half",
+		"1..5h",
+		"tomorrow",
+	} {
+		if got, err := parseDuration(arg); err == nil {
+			t.Errorf("parseDuration(%q) = %v, want an error", arg, got)
+		}
+	}
+}
+
+func TestParseDateRelative(t *testing.T) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	for _, test := range []struct {
+		arg  string
+		want time.Time
+	}{
+		{"today", today},
+		{"yesterday", today.AddDate(0, 0, -1)},
+		{"tomorrow", today.AddDate(0, 0, 1)},
+		{"yesterday 17:30", today.AddDate(0, 0, -1).Add(17*time.Hour + 30*time.Minute)},
+		{"Tomorrow at 9am", today.AddDate(0, 0, 1).Add(9 * time.Hour)},
+		{"9am", today.Add(9 * time.Hour)},
+		{"9 pm", today.Add(21 * time.Hour)},
+		{"12am", today},
+		{"12pm", today.Add(12 * time.Hour)},
+		{"noon", today.Add(12 * time.Hour)},
+		{"midnight", today},
+		{"08:15:30", today.Add(8*time.Hour + 15*time.Minute + 30*time.Second)},
+		{"last " + today.Weekday().String(), today.AddDate(0, 0, -7)},
+		{"next " + today.Weekday().String(), today.AddDate(0, 0, 7)},
+		{"this " + today.Weekday().String(), today},
+		{today.Weekday().String(), today},
+		{"on " + today.AddDate(0, 0, -1).Weekday().String() + " noon", today.AddDate(0, 0, -1).Add(12 * time.Hour)},
+	} {
+		got, err := parseDate(test.arg)
+		if err != nil || !got.Equal(test.want) {
+			if time.Now().YearDay() != now.YearDay() {
+				t.Skip("the day changed during the test")
+			}
+			t.Errorf("parseDate(%q) = %v, %v; want %v", test.arg, got, err, test.want)
+		}
+	}
+	for _, test := range []struct {
+		arg  string
+		want time.Duration
+	}{
+		{"now", 0},
+		{"2 hours ago", -2 * time.Hour},
+		{"90 min ago", -90 * time.Minute},
+		{"in 10 minutes", 10 * time.Minute},
+		{"in 1d", 24 * time.Hour},
+	} {
+		before := time.Now()
+		got, err := parseDate(test.arg)
+		after := time.Now()
+		if err != nil || got.Before(before.Add(test.want)) || got.After(after.Add(test.want)) {
+			t.Errorf("parseDate(%q) = %v, %v; want %v from now", test.arg, got, err, test.want)
+		}
+	}
+}
+
+func TestParseDateBoundaries(t *testing.T) {
+	inLocation(t, "Europe/London")
+	for _, test := range []struct {
+		arg  string
+		want string
+	}{
+		{"2024-02-29", "2024-02-29 00:00:00 +0000"},
+		{"2024-12-31 23:59:59", "2024-12-31 23:59:59 +0000"},
+		{"2025-01-01T00:00", "2025-01-01 00:00:00 +0000"},
+		{"2024-06-30T12:30:15", "2024-06-30 12:30:15 +0100"},
+		{"2024-03-01 9am", "2024-03-01 09:00:00 +0000"},
+		//the clocks go forward at 01:00 on 2024-03-31 and back at 02:00 on 2024-10-27
+		{"2024-03-31", "2024-03-31 00:00:00 +0000"},
+		{"2024-03-31 12:00", "2024-03-31 12:00:00 +0100"},
+		{"2024-03-30 23:59", "2024-03-30 23:59:00 +0000"},
+		{"2024-10-27", "2024-10-27 00:00:00 +0100"},
+		{"2024-10-27 noon", "2024-10-27 12:00:00 +0000"},
+	} {
+		got, err := parseDate(test.arg)
+		if err != nil || got.Format("2006-01-02 15:04:05 -0700") != test.want {
+			t.Errorf("parseDate(%q) = %v, %v; want %v", test.arg, got, err, test.want)
+		}
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, arg := range []string{
+		"",
+		"someday",
+		"2023-02-29",
+		"2024-02-30",
+		"2024-13-01",
+		"2024-1-5",
+		"24:00",
+		"9:60",
+		"13pm",
+		"0am",
+		"9",
+		"last",
+		"last funday",
+		"next 9am",
+		"yesterday someday",
+		"in",
+		"ago",
+		"in a while",
+		"sometime ago",
+		"now please",
+	} {
+		if got, err := parseDate(arg); err == nil {
+			t.Errorf("parseDate(%q) = %v, want an error", arg, got)
+		}
+	}
+}
+
+func TestRelativeWeekday(t *testing.T) {
+	loc := inLocation(t, "Europe/London")
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+	for _, test := range []struct {
+		today     time.Time
+		weekday   time.Weekday
+		direction string
+		want      time.Time
+	}{
+		//across the end of a leap February
+		{day(2024, 3, 1), time.Thursday, "last", day(2024, 2, 29)},
+		{day(2024, 3, 1), time.Friday, "last", day(2024, 2, 23)},
+		{day(2024, 2, 29), time.Friday, "next", day(2024, 3, 1)},
+		{day(2023, 3, 1), time.Tuesday, "", day(2023, 2, 28)},
+		//across the end of a year
+		{day(2025, 1, 1), time.Monday, "last", day(2024, 12, 30)},
+		{day(2025, 1, 1), time.Friday, "", day(2024, 12, 27)},
+		{day(2025, 1, 1), time.Wednesday, "", day(2025, 1, 1)},
+		{day(2025, 1, 1), time.Wednesday, "next", day(2025, 1, 8)},
+		{day(2025, 1, 1), time.Sunday, "this", day(2025, 1, 5)},
+		{day(2025, 1, 1), time.Monday, "this", day(2024, 12, 30)},
+		{day(2024, 12, 31), time.Monday, "next", day(2025, 1, 6)},
+		//across changes to and from daylight saving time, still at midnight
+		{day(2024, 4, 1), time.Sunday, "last", day(2024, 3, 31)},
+		{day(2024, 4, 1), time.Saturday, "", day(2024, 3, 30)},
+		{day(2024, 3, 30), time.Monday, "next", day(2024, 4, 1)},
+		{day(2024, 10, 28), time.Friday, "last", day(2024, 10, 25)},
+		{day(2024, 10, 26), time.Sunday, "this", day(2024, 10, 27)},
+	} {
+		got := relativeWeekday(test.today, test.weekday, test.direction)
+		if !got.Equal(test.want) {
+			t.Errorf("relativeWeekday(%v, %v, %q) = %v, want %v", test.today.Format("2006-01-02 Mon"), test.weekday, test.direction, got, test.want)
+		}
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	loc := inLocation(t, "Europe/London")
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, loc)
+	}
+	for _, test := range []struct {
+		arg        string
+		start, end time.Time
+		duration   time.Duration
+	}{
+		{"2024-01-05 09:00-11:30", at(2024, 1, 5, 9, 0), at(2024, 1, 5, 11, 30), 150 * time.Minute},
+		{"2024-01-05 9am-1pm", at(2024, 1, 5, 9, 0), at(2024, 1, 5, 13, 0), 4 * time.Hour},
+		{"on 2024-01-05 noon-13:15", at(2024, 1, 5, 12, 0), at(2024, 1, 5, 13, 15), 75 * time.Minute},
+		//crossing midnight, and with it the end of a month or a year
+		{"2024-02-28 22:00-02:00", at(2024, 2, 28, 22, 0), at(2024, 2, 29, 2, 0), 4 * time.Hour},
+		{"2024-02-29 23:30-00:30", at(2024, 2, 29, 23, 30), at(2024, 3, 1, 0, 30), time.Hour},
+		{"2024-12-31 23:00-1am", at(2024, 12, 31, 23, 0), at(2025, 1, 1, 1, 0), 2 * time.Hour},
+		//the same clock times are an hour shorter or longer when the clocks change
+		{"2024-03-31 00:00-12:00", at(2024, 3, 31, 0, 0), at(2024, 3, 31, 12, 0), 11 * time.Hour},
+		{"2024-03-30 22:00-02:30", at(2024, 3, 30, 22, 0), at(2024, 3, 31, 2, 30), 3*time.Hour + 30*time.Minute},
+		{"2024-10-27 00:00-12:00", at(2024, 10, 27, 0, 0), at(2024, 10, 27, 12, 0), 13 * time.Hour},
+		{"2024-10-26 23:00-03:00", at(2024, 10, 26, 23, 0), at(2024, 10, 27, 3, 0), 5 * time.Hour},
+		//equal times are not taken to cross midnight
+		{"2024-01-05 09:00-09:00", at(2024, 1, 5, 9, 0), at(2024, 1, 5, 9, 0), 0},
+	} {
+		start, end, ok := parseInterval(test.arg)
+		if !ok || !start.Equal(test.start) || !end.Equal(test.end) || end.Sub(start) != test.duration {
+			t.Errorf("parseInterval(%q) = %v, %v, %v; want %v, %v (%v)", test.arg, start, end, ok, test.start, test.end, test.duration)
+		}
+	}
+
+	now := time.Now()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, loc)
+	start, end, ok := parseInterval("yesterday 9am-5pm")
+	if now.Day() == time.Now().Day() && (!ok || !start.Equal(yesterday.Add(9*time.Hour)) || end.Hour() != 17 || end.Day() != yesterday.Day()) {
+		t.Errorf("parseInterval(\"yesterday 9am-5pm\") = %v, %v, %v", start, end, ok)
+	}
+
+	for _, arg := range []string{
+		"",
+		"09:00",
+		"9-17",
+		"09:00-",
+		"-17:00",
+		"09:00-10:00-11:00",
+		"25:00-26:00",
+		"09:00-13pm",
+		"someday 09:00-10:00",
+		"2024-02-30 09:00-10:00",
+		"2024-01-05 09:00 - 10:00",
+	} {
+		if start, end, ok := parseInterval(arg); ok {
+			t.Errorf("parseInterval(%q) = %v, %v, want it rejected", arg, start, end)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"sync"
+)
+
+//noteWorkers is how many notes are read at once, from --workers or the read_workers
+//setting. Reading notes concurrently hides the latency of network filesystems.
+func noteWorkers(t task, opts options) int {
+	arg := opts.get("workers", t.setting("read_workers", "8"))
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		panic(errors.New("Invalid --workers: " + arg))
+	}
+	return n
+}
+
+//readNotes reads the notes of the logs with up to workers files open at once, returning
+//them in the same order as the logs
+func readNotes(ls logs, workers int) []string {
+	notes := make([]string, len(ls))
+	errs := make([]error, len(ls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(ls); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b, err := ioutil.ReadFile(ls[i].path())
+				notes[i], errs[i] = string(b), err
+			}
+		}()
+	}
+	for i := range ls {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	checkCanceled()
+	for _, err := range errs {
+		if err != nil {
+			panic(err)
+		}
+	}
+	return notes
+}
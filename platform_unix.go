@@ -0,0 +1,57 @@
+//go:build !windows
+
+package main
+
+//the parts of horolog which differ between Unix and Windows (see platform_windows.go)
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+//lockFile takes an exclusive lock on f without waiting for it
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+//lockBusy reports whether lockFile failed because another process holds the lock
+func lockBusy(err error) bool {
+	return err == syscall.EWOULDBLOCK
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+//ownProcessGroup starts cmd in a process group of its own, so that Ctrl-C in the terminal
+//reaches only horolog, which ends the command when it is ready to
+func ownProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+//wtsDetector is only available on Windows
+type wtsDetector struct{}
+
+func (wtsDetector) available() bool { return false }
+
+func (wtsDetector) watch(ctx context.Context, opts idleOptions, changed func(bool, time.Time)) error {
+	return errors.New("Windows session notifications are only available on Windows")
+}
+
+//systemBatteryCritical is for systems batteryCritical does not know how to ask, which are
+//taken to have no battery
+func systemBatteryCritical(percent int) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+//the parts of horolog which differ on Windows (see platform_unix.go)
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32        = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx  = kernel32.NewProc("LockFileEx")
+	procUnlockFile  = kernel32.NewProc("UnlockFileEx")
+	procPowerStatus = kernel32.NewProc("GetSystemPowerStatus")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+	stillActive             = 259
+)
+
+//lockFile takes an exclusive lock on the first byte of f without waiting for it
+func lockFile(f *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func unlockFile(f *os.File) {
+	var overlapped syscall.Overlapped
+	procUnlockFile.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+}
+
+//lockBusy reports whether lockFile failed because another process holds the lock
+func lockBusy(err error) bool {
+	return err == errorLockViolation
+}
+
+//processAlive asks whether the process is still running, as Windows processes cannot be signalled
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	return syscall.GetExitCodeProcess(h, &code) == nil && code == stillActive
+}
+
+//ownProcessGroup starts cmd in a process group of its own, so that Ctrl-C in the console
+//reaches only horolog, which ends the command when it is ready to
+func ownProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+type systemPowerStatus struct {
+	acLineStatus        byte
+	batteryFlag         byte
+	batteryLifePercent  byte
+	systemStatusFlag    byte
+	batteryLifeTime     uint32
+	batteryFullLifeTime uint32
+}
+
+//systemBatteryCritical asks Windows whether the computer is running on a battery with no
+//more than percent left
+func systemBatteryCritical(percent int) (bool, error) {
+	var status systemPowerStatus
+	if r, _, err := procPowerStatus.Call(uintptr(unsafe.Pointer(&status))); r == 0 {
+		return false, err
+	}
+	//255 means unknown
+	return status.acLineStatus == 0 && status.batteryLifePercent != 255 && int(status.batteryLifePercent) <= percent, nil
+}
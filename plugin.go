@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"./format"
+)
+
+//plugins are programs named horolog-<name> on the PATH, which horolog <name> runs (as git
+//does) when name is neither a command nor an existing task. A plugin is given the same
+//arguments, and is told about the tree in the environment:
+//	HOROLOG                 this horolog, to run for anything else, e.g. "$HOROLOG" export
+//	HOROLOG_ROOT            the absolute path of the root of the tree
+//	HOROLOG_TASK            the task, relative to the root: the first argument if it is a
+//	                        directory, as with horolog's own commands, or else the current one
+//	HOROLOG_FROM, _TO       the bounds of --period in RFC 3339, if it was given and bounded
+//	HOROLOG_FORMAT_VERSION  the format version (see FORMAT.md)
+//	HOROLOG_VERBOSITY       0 with --quiet, 1 normally, and more with --verbose
+//	HOROLOG_REQUEST         a JSON file holding all of the above as a pluginRequest, along
+//	                        with the excludes and, if --period was given, the logs in it
+//	                        (as from export --format=json)
+
+const pluginPrefix = "horolog-"
+
+//a pluginRequest is the JSON in HOROLOG_REQUEST
+type pluginRequest struct {
+	FormatVersion int          `json:"format_version"`
+	Horolog       string       `json:"horolog"`
+	Root          string       `json:"root"`
+	Task          string       `json:"task"`
+	Args          []string     `json:"args"`
+	From          *time.Time   `json:"from,omitempty"`
+	To            *time.Time   `json:"to,omitempty"`
+	Exclude       []string     `json:"exclude"`
+	Verbosity     int          `json:"verbosity"`
+	Logs          *[]exportRow `json:"logs,omitempty"`
+}
+
+//findPlugin returns the plugin horolog name would run, if there is one
+func findPlugin(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	//a task of the same name comes first, so that a new plugin never hides one
+	if _, err := os.Stat(name); err == nil {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+//newPluginRequest resolves the tree, task and period for a plugin given args
+func newPluginRequest(args []string) pluginRequest {
+	opts, positional := parseOptions(args)
+	exe, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	dir := "."
+	if len(positional) > 0 {
+		if fi, err := os.Stat(positional[0]); err == nil && fi.IsDir() {
+			dir = positional[0]
+		}
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	root, err := filepath.Abs(treeRoot(dir))
+	if err != nil {
+		panic(err)
+	}
+	abs, err := filepath.Abs(t.path())
+	if err != nil {
+		panic(err)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		panic(err)
+	}
+	r := pluginRequest{
+		FormatVersion: format.Version,
+		Horolog:       exe,
+		Root:          root,
+		Task:          filepath.ToSlash(rel),
+		Args:          args,
+		Exclude:       append([]string{}, excludes...),
+		Verbosity:     verbosity,
+	}
+	if opts.has("period") {
+		from, to, err := parsePeriod(opts.get("period", ""))
+		if err != nil {
+			panic(err)
+		}
+		if from != never {
+			r.From = &from
+		}
+		if to != never {
+			r.To = &to
+		}
+		rows, err := exportRows(t, between(from, to))
+		if err != nil {
+			panic(err)
+		}
+		r.Logs = &rows
+	}
+	return r
+}
+
+//environment is the request as variables, for plugins which are shell scripts
+func (r pluginRequest) environment(requestPath string) []string {
+	env := []string{
+		"HOROLOG=" + r.Horolog,
+		"HOROLOG_ROOT=" + r.Root,
+		"HOROLOG_TASK=" + r.Task,
+		"HOROLOG_FORMAT_VERSION=" + strconv.Itoa(r.FormatVersion),
+		"HOROLOG_VERBOSITY=" + strconv.Itoa(r.Verbosity),
+		"HOROLOG_REQUEST=" + requestPath,
+	}
+	if r.From != nil {
+		env = append(env, "HOROLOG_FROM="+r.From.Format(time.RFC3339))
+	}
+	if r.To != nil {
+		env = append(env, "HOROLOG_TO="+r.To.Format(time.RFC3339))
+	}
+	return env
+}
+
+//runPlugin runs a plugin with args, exiting with its status
+func runPlugin(path string, args []string) {
+	r := newPluginRequest(args)
+	f, err := ioutil.TempFile("", "horolog-request-*.json")
+	if err != nil {
+		panic(err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	err = enc.Encode(r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		panic(err)
+	}
+
+	debug("running plugin", path)
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), r.environment(f.Name())...)
+	//the plugin gets Ctrl-C from the terminal too, and is left to handle it
+	err = cmd.Run()
+	os.Remove(f.Name())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+//plugins returns the plugins on the PATH by name, with the first of each name winning
+//as it would when run
+func plugins() map[string]string {
+	answer := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range files {
+			if !strings.HasPrefix(fi.Name(), pluginPrefix) || fi.IsDir() {
+				continue
+			}
+			name := strings.TrimPrefix(fi.Name(), pluginPrefix)
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if path, err := exec.LookPath(filepath.Join(dir, fi.Name())); err == nil && answer[name] == "" {
+				answer[name] = path
+			}
+		}
+	}
+	return answer
+}
+
+//pluginsCommand lists the plugins on the PATH
+func pluginsCommand(args []string) {
+	found := plugins()
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, found[name])
+	}
+	w.Flush()
+}
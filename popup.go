@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//zenity runs a zenity dialog and returns what it printed, and whether it was accepted
+func zenity(args ...string) (string, bool, error) {
+	cmd := exec.Command("zenity", args...)
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.New("popup requires zenity: " + err.Error())
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+//popupNote shows an editable note while the session runs, which ends when it is closed
+func popupNote(t task, fpath string) error {
+	note, ok, err := zenity("--text-info", "--editable", "--title=horolog: "+t.path(), "--ok-label=Stop", "--cancel-label=Stop without note")
+	if err != nil || !ok {
+		return err
+	}
+	return ioutil.WriteFile(fpath, []byte(note+"\n"), 0600)
+}
+
+func popupCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	root, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	entries := []string{"--entry", "--title=horolog", "--text=Task to log"}
+	for _, t := range root.recursiveSubtasks() {
+		rel, err := filepath.Rel(root.path(), t.path())
+		if err == nil {
+			entries = append(entries, rel)
+		}
+	}
+	name, ok, err := zenity(entries...)
+	if err != nil {
+		panic(err)
+	}
+	if !ok || name == "" {
+		return
+	}
+
+	t, err := loadOrCreateTask(filepath.Join(root.path(), name))
+	if err != nil {
+		panic(err)
+	}
+	err = t.createLog(sessionOptions{popup: true, allowParallel: opts.has("allow-parallel"), context: sessionContext(t, opts)})
+	if err != nil {
+		panic(err)
+	}
+}
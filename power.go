@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//when the battery is about to run out, or the computer is about to be hibernated because
+//of it, watch (with the power_events setting) records how far each running session got,
+//so that if the computer dies the session can still be logged up to then
+
+//powerDir is where the sessions which may be cut short are recorded, which unlike the
+//session directory survives a restart: $XDG_STATE_HOME/horolog/power (usually
+//~/.local/state/horolog/power)
+func powerDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "horolog", "power")
+}
+
+func (s session) powerPath() string {
+	return filepath.Join(powerDir(), strconv.Itoa(s.pid))
+}
+
+//markPowerLoss records that the session may end now, along with its note so far
+func (s session) markPowerLoss(end time.Time) error {
+	if err := os.MkdirAll(powerDir(), 0700); err != nil {
+		return err
+	}
+	note, _ := ioutil.ReadFile(s.note)
+	text := "task = \"" + s.task + "\"\nstart = " + s.start.Format(time.RFC3339Nano) + "\nend = " + end.Format(time.RFC3339Nano) + "\n"
+	if err := writeFileAtomic(s.powerPath()+".note", note, 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.powerPath(), []byte(text), 0600)
+}
+
+func (s session) clearPowerLoss() {
+	os.Remove(s.powerPath())
+	os.Remove(s.powerPath() + ".note")
+}
+
+//recoverPowerLosses logs the sessions which were recorded as the battery ran out, and
+//which are no longer running, up to the last time they were recorded. The notes are
+//marked with ended: power in their front matter. A session which cannot be logged, e.g.
+//because its task is in a locked period, is left for next time rather than stopping new
+//sessions from starting, and one which has already been logged is forgotten.
+func recoverPowerLosses() {
+	files, _ := ioutil.ReadDir(powerDir())
+	running := map[int]bool{}
+	for _, s := range loadSessions() {
+		running[s.pid] = true
+	}
+	for _, f := range files {
+		pid, err := strconv.Atoi(f.Name())
+		if err != nil || running[pid] {
+			continue
+		}
+		s := session{pid: pid}
+		c := loadConfig(s.powerPath())
+		start, err1 := time.Parse(time.RFC3339Nano, c.get("start", ""))
+		end, err2 := time.Parse(time.RFC3339Nano, c.get("end", ""))
+		if err1 != nil || err2 != nil || c.get("task", "") == "" {
+			debug("removing invalid power loss", s.powerPath())
+			s.clearPowerLoss()
+			continue
+		}
+		path, err := s.logPowerLoss(c.get("task", ""), start, end)
+		if os.IsExist(err) {
+			debug("power loss already logged", path)
+			s.clearPowerLoss()
+			continue
+		}
+		if err != nil {
+			debug("cannot log power loss", s.powerPath()+":", err)
+			continue
+		}
+		s.clearPowerLoss()
+		inform("Logged", formatHoursMinutes(end.Sub(start)), "in", c.get("task", ""), "from a session cut short by the battery running out")
+	}
+}
+
+//logPowerLoss logs a session recorded as the battery ran out, turning panics (such as a
+//tree in another format version) into errors
+func (s session) logPowerLoss(dir string, start, end time.Time) (path string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	note, _ := ioutil.ReadFile(s.powerPath() + ".note")
+	t, err := loadOrCreateTask(dir)
+	if err != nil {
+		return "", err
+	}
+	defer lockTree(t.path())()
+	path = t.logPath(start, end)
+	if _, err := writeNewLog(path, addFrontMatter(note, "ended", "power"), false); err != nil {
+		return path, err
+	}
+	audit(t.path(), "power", treePath(path))
+	return path, nil
+}
+
+//batteryPercent matches the charge in pmset -g batt
+var batteryPercent = regexp.MustCompile(`([0-9]+)%; *discharging`)
+
+//batteryCritical reports whether the computer is running on a battery which has no more
+//than percent left
+func batteryCritical(percent int) (bool, error) {
+	switch runtime.GOOS {
+	case "linux":
+		dirs, _ := filepath.Glob("/sys/class/power_supply/*")
+		for _, dir := range dirs {
+			kind, _ := ioutil.ReadFile(filepath.Join(dir, "type"))
+			status, _ := ioutil.ReadFile(filepath.Join(dir, "status"))
+			capacity, err := ioutil.ReadFile(filepath.Join(dir, "capacity"))
+			if strings.TrimSpace(string(kind)) != "Battery" || strings.TrimSpace(string(status)) != "Discharging" || err != nil {
+				continue
+			}
+			if n, err := strconv.Atoi(strings.TrimSpace(string(capacity))); err == nil && n <= percent {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "darwin":
+		var out bytes.Buffer
+		cmd := exec.Command("pmset", "-g", "batt")
+		cmd.Stdout = &out
+		if err := cmd.Run(); err != nil {
+			return false, err
+		}
+		m := batteryPercent.FindStringSubmatch(out.String())
+		if m == nil {
+			return false, nil
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n <= percent, nil
+	}
+	return systemBatteryCritical(percent)
+}
+
+//watchPower records the running sessions each time it finds the battery critical, until
+//ctx is done, and forgets them once the computer is plugged in
+func watchPower(every time.Duration, percent int) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	marked := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		critical, err := batteryCritical(percent)
+		if err != nil {
+			debug("cannot read the battery:", err)
+			continue
+		}
+		if !critical && !marked {
+			continue
+		}
+		if critical && !marked {
+			debug("battery critical, recording sessions")
+		}
+		for _, s := range loadSessions() {
+			if !critical {
+				s.clearPowerLoss()
+			} else if err := s.markPowerLoss(time.Now()); err != nil {
+				debug("cannot record", s.task+":", err)
+			}
+		}
+		marked = critical
+	}
+}
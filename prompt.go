@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//promptFormats are the ready-made formats for prompt frameworks, which show nothing when a
+//command prints nothing, and draw their own colours and separators around the text
+var promptFormats = map[string]string{
+	"starship":  "⏱ {task} {duration}",
+	"powerline": "\uf017 {task} {elapsed}",
+}
+
+//shortName truncates a task name to width characters, marking it with an ellipsis like
+//starship's truncation_symbol, or leaves it alone if width is 0
+func shortName(name string, width int) string {
+	runes := []rune(name)
+	if width > 0 && len(runes) > width {
+		runes = append(runes[:width-1], '…')
+	}
+	return string(runes)
+}
+
+//promptCommand prints the task being logged and its elapsed time for a shell prompt. It is
+//run every time the prompt is drawn, so it only reads the session files (the newest one,
+//without checking the others or tidying up after dead sessions), never the tree.
+func promptCommand(args []string) {
+	opts, _ := parseOptions(args)
+	format := opts.get("format", "{task} {elapsed}")
+	width := 0
+	if ready, ok := promptFormats[format]; ok {
+		format = ready
+		width = 20
+	}
+	if opts.has("width") {
+		var err error
+		if width, err = strconv.Atoi(opts.get("width", "")); err != nil || width < 0 {
+			panic(errors.New("Invalid --width: " + opts.get("width", "")))
+		}
+	}
+	files, err := ioutil.ReadDir(sessionDir())
+	if err != nil {
+		return
+	}
+	var newest session
+	for _, f := range files {
+		pid, err := strconv.Atoi(f.Name())
+		if err != nil {
+			continue
+		}
+		s, err := loadSession(pid)
+		if err != nil || !processAlive(pid) {
+			continue
+		}
+		if s.start.After(newest.start) {
+			newest = s
+		}
+	}
+	if newest.task == "" {
+		return
+	}
+	minutes := int(newest.elapsed().Minutes())
+	//like starship's cmd_duration, e.g. 1h5m or 5m
+	duration := strconv.Itoa(minutes%60) + "m"
+	if minutes >= 60 {
+		duration = strconv.Itoa(minutes/60) + "h" + duration
+	}
+	line := strings.NewReplacer(
+		"{task}", shortName(filepath.Base(newest.task), width),
+		"{path}", newest.task,
+		"{elapsed}", fmt.Sprintf("%d:%02d", minutes/60, minutes%60),
+		"{duration}", duration,
+		"{minutes}", strconv.Itoa(minutes),
+	).Replace(format)
+	if strings.Contains(line, "{") {
+		panic(errors.New("Invalid --format: " + format + " (use starship, powerline, or {task}, {path}, {elapsed}, {duration} and {minutes})"))
+	}
+	fmt.Println(line)
+}
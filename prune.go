@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//prunedNote is what is left of a note after pruning: its front matter, which holds
+//structure rather than free text (unless allText), and the first truncate characters
+//of the rest
+func prunedNote(note string, truncate int, allText bool) string {
+	fm, body := parseFrontMatter(note)
+	header := ""
+	if !allText && len(fm) > 0 {
+		header = strings.TrimSuffix(note, body)
+	}
+	kept := ""
+	if runes := []rune(strings.TrimSpace(body)); truncate > 0 && len(runes) > 0 {
+		if len(runes) > truncate {
+			runes = runes[:truncate]
+		}
+		kept = strings.TrimSpace(string(runes)) + "\n"
+	}
+	return header + kept
+}
+
+//pruneNotesCommand removes the text of notes older than the retention period, leaving
+//the logs (and so their times and durations) in place, or removes the logs with --delete
+func pruneNotesCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	retention := opts.get("older-than", t.setting("note_retention", ""))
+	if retention == "" {
+		panic(errors.New("No retention period given, use e.g. --older-than=3y (or set note_retention)"))
+	}
+	age, err := parseDuration(retention)
+	if err != nil || age <= 0 {
+		panic(errors.New("Invalid --older-than: " + retention))
+	}
+	truncate := 0
+	if opts.has("truncate") {
+		if truncate, err = strconv.Atoi(opts.get("truncate", "")); err != nil || truncate < 0 {
+			panic(errors.New("Invalid --truncate: " + opts.get("truncate", "")))
+		}
+	}
+	if opts.has("delete") && opts.has("keep-durations") {
+		panic(errors.New("--delete removes the logs, so their durations cannot be kept"))
+	}
+	dryRun := opts.has("dry-run")
+	if !dryRun {
+		defer lockTree(dir)()
+	}
+	hashes, err := loadIntegrity(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	cutoff := time.Now().Add(-age)
+	pruned := 0
+	//the manifest is saved even if a locked period stops pruning part way through, so that
+	//verify-integrity does not report the logs pruned before it
+	defer func() {
+		if pruned > 0 && !dryRun {
+			if err := saveIntegrity(dir, hashes); err != nil {
+				panic(err)
+			}
+		}
+	}()
+	for _, l := range t.recursiveLogsMatching(func(l log) bool { return l.end().Before(cutoff) }) {
+		note := l.text()
+		path := treePath(l.path())
+		//like amend and delete, pruning changes logs, so locked periods are left alone without
+		//--force
+		if opts.has("delete") {
+			inform("delete:", l.path())
+			if !dryRun {
+				forced, err := removeLog(l.path(), opts.has("force"))
+				if err != nil {
+					panic(err)
+				}
+				delete(hashes, path)
+				action := "prune --delete"
+				if forced {
+					action += " --force"
+				}
+				audit(dir, action, path)
+			}
+			pruned++
+			continue
+		}
+		kept := prunedNote(note, truncate, opts.has("all-text"))
+		if kept == note {
+			continue
+		}
+		inform("prune:", l.path())
+		pruned++
+		if dryRun {
+			continue
+		}
+		forced, err := rewriteLog(l.path(), []byte(kept), opts.has("force"))
+		if err != nil {
+			panic(err)
+		}
+		//pruning is intended, so verify-integrity should not report it
+		if _, ok := hashes[path]; ok {
+			if hashes[path], err = hashFile(l.path()); err != nil {
+				panic(err)
+			}
+		}
+		action := "prune"
+		if forced {
+			action += " --force"
+		}
+		audit(dir, action, path)
+	}
+	inform("Pruned", pruned, "logs ending before", cutoff.Format("2006-01-02"))
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//a query is a small expression evaluated against every log beneath a task, e.g.
+//	sum(duration) where task ~ "clients/acme" and weekday in (sat,sun) since 2024-01-01
+//
+//aggregates are sum, avg, min and max of duration, and count(*)
+//conditions compare a field (task, text, user, weekday, hour, date, duration, or
+//meta.key for a key of the front matter) using =, !=, <, <=, >, >=, ~ (regular
+//expression), !~ or in (a,b,c), and can be combined with and, or, not and parentheses.
+//A front matter list matches if any of its values does.
+type query struct {
+	aggregate string
+	where     condition
+	since     time.Time
+	until     time.Time
+	groupBy   string
+}
+
+//queryLog is a log along with the path of its task relative to the queried task
+type queryLog struct {
+	log
+	task string
+}
+
+type condition func(ql queryLog) bool
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeQuery(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, errors.New("Unterminated string in query")
+			}
+			tokens = append(tokens, token{text: s[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case strings.IndexByte("(),", c) >= 0:
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case strings.IndexByte("=!<>~", c) >= 0:
+			j := i + 1
+			for j < len(s) && strings.IndexByte("=~", s[j]) >= 0 && j-i < 2 {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\n\"'(),=!<>~", s[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return strings.ToLower(p.tokens[p.pos].text)
+}
+
+func (p *queryParser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, errors.New("Unexpected end of query")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *queryParser) expect(text string) error {
+	if p.peek() != text {
+		if p.pos >= len(p.tokens) {
+			return errors.New("Expected " + text + " at end of query")
+		}
+		return errors.New("Expected " + text + " but found " + p.tokens[p.pos].text)
+	}
+	p.pos++
+	return nil
+}
+
+func parseQuery(s string) (query, error) {
+	tokens, err := tokenizeQuery(s)
+	if err != nil {
+		return query{}, err
+	}
+	p := &queryParser{tokens: tokens}
+	q := query{where: func(queryLog) bool { return true }}
+
+	agg, err := p.next()
+	if err != nil {
+		return q, err
+	}
+	q.aggregate = strings.ToLower(agg.text)
+	switch q.aggregate {
+	case "sum", "avg", "min", "max", "count":
+	default:
+		return q, errors.New("Unknown aggregate: " + agg.text)
+	}
+	if p.peek() == "(" {
+		p.pos++
+		arg, err := p.next()
+		if err != nil {
+			return q, err
+		}
+		if arg.text != "duration" && !(q.aggregate == "count" && arg.text == "*") {
+			return q, errors.New("Cannot aggregate " + arg.text)
+		}
+		if err := p.expect(")"); err != nil {
+			return q, err
+		}
+	}
+
+	for p.pos < len(p.tokens) {
+		switch p.peek() {
+		case "where":
+			p.pos++
+			q.where, err = p.parseOr()
+		case "since", "until":
+			clause := p.peek()
+			p.pos++
+			var t token
+			t, err = p.next()
+			if err != nil {
+				break
+			}
+			var when time.Time
+			when, err = parseQueryTime(t.text)
+			if clause == "since" {
+				q.since = when
+			} else {
+				q.until = when
+			}
+		case "group":
+			p.pos++
+			if err = p.expect("by"); err != nil {
+				break
+			}
+			var t token
+			t, err = p.next()
+			q.groupBy = strings.ToLower(t.text)
+			if _, ok := queryGroups[q.groupBy]; !ok && !strings.HasPrefix(q.groupBy, "meta.") && err == nil {
+				err = errors.New("Cannot group by " + t.text)
+			}
+		default:
+			err = errors.New("Unexpected " + p.tokens[p.pos].text + " in query")
+		}
+		if err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+//since and until accept a date or a duration before now
+func parseQueryTime(s string) (time.Time, error) {
+	t, err := parseDate(s)
+	if err == nil {
+		return t, nil
+	}
+	dur, err2 := parseDuration(s)
+	if err2 != nil {
+		return never, err
+	}
+	return time.Now().Add(-dur), nil
+}
+
+func (p *queryParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ql queryLog) bool { return l(ql) || right(ql) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ql queryLog) bool { return l(ql) && right(ql) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (condition, error) {
+	switch p.peek() {
+	case "not":
+		p.pos++
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(ql queryLog) bool { return !c(ql) }, nil
+	case "(":
+		p.pos++
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (condition, error) {
+	f, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(f.text)
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	if strings.ToLower(op.text) == "in" {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			v, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v.text)
+			if p.peek() == ")" {
+				p.pos++
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		v, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v.text)
+	}
+
+	if strings.HasPrefix(name, "meta.") {
+		return metaComparison(strings.TrimPrefix(name, "meta."), strings.ToLower(op.text), values)
+	}
+	if value, ok := stringFields[name]; ok {
+		return stringComparison(value, strings.ToLower(op.text), values)
+	}
+	if field, ok := numericFields[name]; ok {
+		return numericComparison(field, strings.ToLower(op.text), values)
+	}
+	return nil, errors.New("Unknown field: " + f.text)
+}
+
+var stringFields = map[string]func(ql queryLog) string{
+	"task": func(ql queryLog) string { return ql.task },
+	"text": func(ql queryLog) string { return ql.text() },
+	"user": func(ql queryLog) string { return ql.author() },
+	"type": func(ql queryLog) string { return ql.entryType() },
+}
+
+func stringComparison(value func(queryLog) string, op string, values []string) (condition, error) {
+	switch op {
+	case "=":
+		return func(ql queryLog) bool { return value(ql) == values[0] }, nil
+	case "!=":
+		return func(ql queryLog) bool { return value(ql) != values[0] }, nil
+	case "~", "!~":
+		re, err := regexp.Compile(values[0])
+		if err != nil {
+			return nil, err
+		}
+		negate := op == "!~"
+		return func(ql queryLog) bool { return re.MatchString(value(ql)) != negate }, nil
+	case "in":
+		return func(ql queryLog) bool {
+			v := value(ql)
+			for _, v2 := range values {
+				if v == v2 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, errors.New("Invalid operator for text: " + op)
+}
+
+//metaComparison compares each value of a front matter key, e.g. meta.tags = urgent
+func metaComparison(key, op string, values []string) (condition, error) {
+	var match func(v string) bool
+	switch op {
+	case "=", "!=":
+		match = func(v string) bool { return v == values[0] }
+	case "~", "!~":
+		re, err := regexp.Compile(values[0])
+		if err != nil {
+			return nil, err
+		}
+		match = re.MatchString
+	case "in":
+		match = func(v string) bool {
+			for _, v2 := range values {
+				if v == v2 {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return nil, errors.New("Invalid operator for text: " + op)
+	}
+	negate := strings.HasPrefix(op, "!")
+	return func(ql queryLog) bool {
+		for _, v := range ql.frontMatter()[key] {
+			if match(v) {
+				return !negate
+			}
+		}
+		return negate
+	}, nil
+}
+
+type numericField struct {
+	value func(ql queryLog) int64
+	parse func(s string) (int64, error)
+}
+
+var numericFields = map[string]numericField{
+	"weekday": {
+		value: func(ql queryLog) int64 { return int64(ql.start().Weekday()) },
+		parse: func(s string) (int64, error) {
+			wd, err := parseWeekday(s)
+			return int64(wd), err
+		},
+	},
+	"hour": {
+		value: func(ql queryLog) int64 { return int64(ql.start().Hour()) },
+		parse: func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) },
+	},
+	"date": {
+		value: func(ql queryLog) int64 {
+			s := ql.start()
+			return time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, time.Local).Unix()
+		},
+		parse: func(s string) (int64, error) {
+			t, err := parseDate(s)
+			return t.Unix(), err
+		},
+	},
+	"duration": {
+		value: func(ql queryLog) int64 { return int64(ql.duration()) },
+		parse: func(s string) (int64, error) {
+			dur, err := parseDuration(s)
+			return int64(dur), err
+		},
+	},
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if len(s) >= 3 && strings.HasPrefix(name, s) {
+			return wd, nil
+		}
+	}
+	return 0, errors.New("Invalid Weekday: " + s)
+}
+
+func numericComparison(f numericField, op string, values []string) (condition, error) {
+	var parsed []int64
+	for _, v := range values {
+		n, err := f.parse(v)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, n)
+	}
+	n := parsed[0]
+	switch op {
+	case "=":
+		return func(ql queryLog) bool { return f.value(ql) == n }, nil
+	case "!=":
+		return func(ql queryLog) bool { return f.value(ql) != n }, nil
+	case "<":
+		return func(ql queryLog) bool { return f.value(ql) < n }, nil
+	case "<=":
+		return func(ql queryLog) bool { return f.value(ql) <= n }, nil
+	case ">":
+		return func(ql queryLog) bool { return f.value(ql) > n }, nil
+	case ">=":
+		return func(ql queryLog) bool { return f.value(ql) >= n }, nil
+	case "in":
+		return func(ql queryLog) bool {
+			v := f.value(ql)
+			for _, n2 := range parsed {
+				if v == n2 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, errors.New("Invalid operator for number: " + op)
+}
+
+var queryGroups = map[string]func(ql queryLog) string{
+	"task": func(ql queryLog) string { return ql.task },
+	"user": func(ql queryLog) string {
+		if ql.author() == "" {
+			return "(unknown)"
+		}
+		return ql.author()
+	},
+	"day":     func(ql queryLog) string { return ql.start().Format("2006-01-02") },
+	"weekday": func(ql queryLog) string { return ql.start().Weekday().String() },
+	"hour":    func(ql queryLog) string { return ql.start().Format("15") },
+	"week": func(ql queryLog) string {
+		year, week := ql.start().ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+	"month": func(ql queryLog) string { return ql.start().Format("2006-01") },
+	"type": func(ql queryLog) string {
+		if ql.entryType() == "" {
+			return "(none)"
+		}
+		return ql.entryType()
+	},
+}
+
+func (q query) matches(ql queryLog) bool {
+	if q.since != never && !ql.end().After(q.since) {
+		return false
+	}
+	if q.until != never && !ql.start().Before(q.until) {
+		return false
+	}
+	return q.where(ql)
+}
+
+//evaluate runs the query over the logs of one or more trees, reading the trees at once
+func (q query) evaluate(roots []summaryRoot) []string {
+	matching := make([][]queryLog, len(roots))
+	eachRoot(roots, func(i int, r summaryRoot) {
+		for _, l := range r.task.recursiveLogsWithin(0) {
+			rel, err := filepath.Rel(r.task.path(), l.dir())
+			if err != nil {
+				rel = l.dir()
+			}
+			ql := queryLog{log: l, task: rootTaskName(r, rel)}
+			if q.matches(ql) {
+				matching[i] = append(matching[i], ql)
+			}
+		}
+	})
+
+	groups := map[string]logs{}
+	for _, qls := range matching {
+		for _, ql := range qls {
+			key := ""
+			switch {
+			case strings.HasPrefix(q.groupBy, "meta."):
+				if key = ql.frontMatter().get(strings.TrimPrefix(q.groupBy, "meta.")); key == "" {
+					key = "(none)"
+				}
+			case q.groupBy != "":
+				key = queryGroups[q.groupBy](ql)
+			}
+			groups[key] = append(groups[key], ql.log)
+		}
+	}
+
+	if q.groupBy == "" {
+		return []string{q.aggregateLogs(groups[""])}
+	}
+	var keys []string
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var answer []string
+	for _, k := range keys {
+		answer = append(answer, k+"\t"+q.aggregateLogs(groups[k]))
+	}
+	return answer
+}
+
+func (q query) aggregateLogs(ls logs) string {
+	if q.aggregate == "count" {
+		return strconv.Itoa(len(ls))
+	}
+	var answer time.Duration
+	for i, l := range ls {
+		d := l.duration()
+		switch {
+		case q.aggregate == "sum" || q.aggregate == "avg":
+			answer += d
+		case i == 0:
+			answer = d
+		case q.aggregate == "min" && d < answer:
+			answer = d
+		case q.aggregate == "max" && d > answer:
+			answer = d
+		}
+	}
+	if q.aggregate == "avg" && len(ls) > 0 {
+		answer /= time.Duration(len(ls))
+	}
+	return answer.String()
+}
+
+func queryCommand(args []string) {
+	if len(args) == 0 {
+		panic(errors.New("No query specified"))
+	}
+	q, err := parseQuery(args[0])
+	if err != nil {
+		panic(err)
+	}
+	opts, positional := parseOptions(args[1:])
+	roots := summaryRoots(opts, positional)
+	var dirs []string
+	for _, r := range roots {
+		dirs = append(dirs, r.task.path())
+	}
+	fmt.Print(cachedOutput(dirs, cacheTTL(roots[0].task, opts), func() string {
+		var output strings.Builder
+		for _, line := range q.evaluate(roots) {
+			output.WriteString(line + "\n")
+		}
+		return output.String()
+	}))
+}
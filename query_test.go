@@ -0,0 +1,195 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"./format"
+)
+
+//testQueryLogs writes four logs beneath dir, named A to D:
+//	A clients/acme   Fri 2024-03-01 09:00-10:00 alice, tagged urgent and review
+//	B clients/acme   Sat 2024-03-02 14:00-14:30 bob
+//	C clients/globex Sun 2024-03-03 23:30 to Mon 00:30 alice
+//	D admin          Mon 2024-03-04 09:00-11:00, with no author
+func testQueryLogs(t testing.TB, dir string) map[string]queryLog {
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 3, day, hour, min, 0, 0, time.Local)
+	}
+	logs := []struct {
+		name, task, author, note string
+		start, end               time.Time
+	}{
+		{"A", "clients/acme", "alice", "---\ntags: [urgent, review]\n---\nFixed the build\n", at(1, 9, 0), at(1, 10, 0)},
+		{"B", "clients/acme", "bob", "Reviewed the release\n", at(2, 14, 0), at(2, 14, 30)},
+		{"C", "clients/globex", "alice", "Restarted the servers\n", at(3, 23, 30), at(4, 0, 30)},
+		{"D", "admin", "", "Invoices\n", at(4, 9, 0), at(4, 11, 0)},
+	}
+	answer := map[string]queryLog{}
+	for _, l := range logs {
+		path := filepath.Join(dir, l.task, format.EncodeName(l.start, l.end, l.author, false)+".txt")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(l.note), 0644); err != nil {
+			t.Fatal(err)
+		}
+		answer[l.name] = queryLog{log: log(path), task: l.task}
+	}
+	return answer
+}
+
+//queryMatches is the names of the logs which the query matches, in order
+func queryMatches(q query, logs map[string]queryLog) string {
+	var names []string
+	for name, ql := range logs {
+		if q.matches(ql) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "")
+}
+
+func testQueries(t *testing.T, tests []struct{ query, want string }) {
+	logs := testQueryLogs(t, t.TempDir())
+	for _, test := range tests {
+		q, err := parseQuery(test.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", test.query, err)
+			continue
+		}
+		if got := queryMatches(q, logs); got != test.want {
+			t.Errorf("%q matches %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, test := range []struct{ query, want string }{
+		{"", "Unexpected end of query"},
+		{"total(duration)", "Unknown aggregate: total"},
+		{"sum(text)", "Cannot aggregate text"},
+		{"sum(*)", "Cannot aggregate *"},
+		{"sum(duration", "Expected ) at end of query"},
+		{"sum where", "Unexpected end of query"},
+		{"sum where task", "Unexpected end of query"},
+		{"sum where task =", "Unexpected end of query"},
+		{"sum where project = acme", "Unknown field: project"},
+		{"sum where task < acme", "Invalid operator for text: <"},
+		{"sum where hour ~ 9", "Invalid operator for number: ~"},
+		{"sum where hour = nine", "invalid syntax"},
+		{"sum where task ~ \"(\"", "missing closing )"},
+		{"sum where task = \"acme", "Unterminated string in query"},
+		{"sum where (task = acme", "Expected ) at end of query"},
+		{"sum where task in acme", "Expected ( but found acme"},
+		{"sum where task in (acme globex)", "Expected , but found globex"},
+		{"sum where weekday in (mon, funday)", "Invalid Weekday: funday"},
+		{"sum where weekday = mo", "Invalid Weekday: mo"},
+		{"sum where date = someday", "Invalid Date: someday"},
+		{"sum since someday", "Invalid Date: someday"},
+		{"sum until", "Unexpected end of query"},
+		{"sum group task", "Expected by but found task"},
+		{"sum group by colour", "Cannot group by colour"},
+		{"sum duration", "Unexpected duration in query"},
+		{"sum where task = acme)", "Unexpected ) in query"},
+	} {
+		_, err := parseQuery(test.query)
+		if err == nil {
+			t.Errorf("parseQuery(%q) succeeded, want %q", test.query, test.want)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("parseQuery(%q): %v, want %q", test.query, err, test.want)
+		}
+	}
+}
+
+func TestQueryPrecedence(t *testing.T) {
+	testQueries(t, []struct{ query, want string }{
+		{"count", "ABCD"},
+		{"count(*) where task = admin", "D"},
+		//and binds more tightly than or, and not more tightly than and
+		{"count where task = admin or task ~ acme and hour = 14", "BD"},
+		{"count where (task = admin or task ~ acme) and hour = 14", "B"},
+		{"count where task ~ acme and hour = 14 or task = admin", "BD"},
+		{"count where task ~ acme and (hour = 14 or task = admin)", "B"},
+		{"count where not task ~ acme and hour = 9", "D"},
+		{"count where not (task ~ acme and hour = 9)", "BCD"},
+		{"count where not not user = alice", "AC"},
+		{"count where task = admin or task = \"clients/globex\" or user = bob", "BCD"},
+		{"COUNT WHERE hour = 9 AND NOT user = alice", "D"},
+		{"count where ((hour = 9))", "AD"},
+		{"count where duration >= 1h and duration < 2h", "AC"},
+		{"count where text ~ \"(?i)release\" or meta.tags = urgent", "AB"},
+		{"count where meta.tags != urgent", "BCD"},
+		{"count where meta.tags in (review, billing)", "A"},
+		{"count where user in (bob, '')", "BD"},
+		{"count where task !~ '^clients/'", "D"},
+	})
+}
+
+func TestQueryWeekdays(t *testing.T) {
+	testQueries(t, []struct{ query, want string }{
+		{"count where weekday in (sat,sun)", "BC"},
+		{"count where weekday in (Saturday, MON)", "BD"},
+		{"count where weekday in (fri)", "A"},
+		{"count where weekday = fri", "A"},
+		{"count where weekday != fri", "BCD"},
+		{"count where not weekday in (sat, sun)", "AD"},
+		//weekdays are numbered from Sunday
+		{"count where weekday >= fri", "AB"},
+		{"count where weekday < tue", "CD"},
+		//by the day each log started on
+		{"count where weekday = mon", "D"},
+	})
+}
+
+func TestQueryDateBounds(t *testing.T) {
+	testQueries(t, []struct{ query, want string }{
+		//since keeps the logs which end after it, and until those which start before it
+		{"count since 2024-03-02", "BCD"},
+		{"count until 2024-03-02", "A"},
+		{"count since 2024-03-01T10:00", "BCD"},
+		{"count until 2024-03-01T09:00", ""},
+		{"count until 2024-03-01T09:01", "A"},
+		//across midnight
+		{"count since 2024-03-04", "CD"},
+		{"count since 2024-03-03 until 2024-03-04", "C"},
+		{"count until \"2024-03-03 23:30\"", "AB"},
+		{"count since 2024-03-05", ""},
+		{"count until 2024-03-01 since 2024-03-05", ""},
+		//date is the day each log started on
+		{"count where date = 2024-03-03", "C"},
+		{"count where date >= 2024-03-02 and date <= 2024-03-03", "BC"},
+		{"count where date in (2024-03-01, 2024-03-04) since 2024-03-02", "D"},
+	})
+}
+
+//FuzzParseQuery checks that any query either fails to parse or can be matched against
+//logs without panicking
+func FuzzParseQuery(f *testing.F) {
+	for _, seed := range []string{
+		"sum(duration) where task ~ \"clients/acme\" and weekday in (sat,sun) since 2024-01-01",
+		"count(*) where not (hour < 9 or hour >= 17) group by week",
+		"avg where meta.tags in (urgent, review) until 2w group by meta.client",
+		"max(duration) where text !~ '^\\[interrupt\\]' and date != yesterday",
+		"min where user = '' or type = meeting group by type",
+	} {
+		f.Add(seed)
+	}
+	logs := testQueryLogs(f, f.TempDir())
+	f.Fuzz(func(t *testing.T, s string) {
+		q, err := parseQuery(s)
+		if err != nil {
+			return
+		}
+		if _, ok := queryGroups[q.groupBy]; !ok && q.groupBy != "" && !strings.HasPrefix(q.groupBy, "meta.") {
+			t.Errorf("parseQuery(%q) groups by %q", s, q.groupBy)
+		}
+		queryMatches(q, logs)
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//serve also takes logs from phones, e.g. an iOS Shortcut or a Tasker task which asks for
+//the task and note by voice, as POST /quick-add?task=clients/acme&minutes=30&note=...
+//with the quick_add_token setting as a bearer token. The log ends when it is sent.
+
+type quickAdder struct {
+	root  task
+	token string
+}
+
+//add writes the log, turning panics (such as a locked period) into errors
+func (q quickAdder) add(name, note, context string, d time.Duration) (path string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	t, err := loadOrCreateTask(importTaskPath(q.root.path(), name))
+	if err != nil {
+		return "", err
+	}
+	defer lockTree(t.path())()
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-d)
+	path = t.logPath(start, end)
+	if _, err := os.Stat(path); err == nil {
+		return "", errors.New("Log Already Exists: " + treePath(path))
+	}
+	text := []byte(strings.TrimSpace(note) + "\n")
+	if context != "" {
+		text = addFrontMatter(text, "context", context)
+	}
+	if _, err := writeNewLog(path, text, false); err != nil {
+		return "", err
+	}
+	audit(t.path(), "quick-add", treePath(path))
+	return path, nil
+}
+
+//ServeHTTP answers in plain text, which a shortcut can show or speak
+func (q quickAdder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if q.token == "" {
+		http.Error(w, "Set quick_add_token to use quick-add", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(q.token)) != 1 {
+		http.Error(w, "Wrong or missing token", http.StatusUnauthorized)
+		return
+	}
+	//the values may be in the query or a form
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("task")
+	if strings.TrimSpace(name) == "" {
+		http.Error(w, "Missing task", http.StatusBadRequest)
+		return
+	}
+	var d time.Duration
+	if minutes := r.FormValue("minutes"); minutes != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(minutes))
+		if err != nil {
+			http.Error(w, "Invalid minutes: "+minutes, http.StatusBadRequest)
+			return
+		}
+		d = time.Duration(n) * time.Minute
+	} else if duration := r.FormValue("duration"); duration != "" {
+		var err error
+		if d, err = parseDuration(duration); err != nil {
+			http.Error(w, "Invalid duration: "+duration, http.StatusBadRequest)
+			return
+		}
+	}
+	if d < time.Minute || d > 24*time.Hour {
+		http.Error(w, "Give minutes (or a duration such as half an hour) between 1 minute and a day", http.StatusBadRequest)
+		return
+	}
+	path, err := q.add(name, r.FormValue("note"), r.FormValue("context"), d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "Logged "+formatHoursMinutes(d)+" in "+treePath(filepath.Dir(path))+"\n")
+}
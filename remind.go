@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+//reminders are recurring slots in which a task is expected to be logged, each set in
+//its own section of the config, e.g.
+//	[remind.retro]
+//	task = "meetings/retro"
+//	days = "fri"
+//	at = "15:00"
+//	duration = "1h"
+//	every = "2w"
+//	from = "2024-01-05"
+//every defaults to 1w, and with a longer interval from is a day in a week which has the slot
+type reminder struct {
+	name     string
+	task     string
+	days     map[time.Weekday]bool
+	hour     int
+	min      int
+	duration time.Duration
+	weeks    int
+	from     time.Time
+}
+
+func loadReminders(c config) ([]reminder, error) {
+	names := map[string]bool{}
+	for key := range c {
+		if strings.HasPrefix(key, "remind.") && strings.Count(key, ".") == 2 {
+			names[strings.Split(key, ".")[1]] = true
+		}
+	}
+	var answer []reminder
+	for name := range names {
+		get := func(key, def string) string { return c.get("remind."+name+"."+key, def) }
+		invalid := func(key string) error {
+			return errors.New("Invalid " + key + " for reminder " + name + ": " + get(key, ""))
+		}
+		r := reminder{name: name, task: get("task", ""), days: map[time.Weekday]bool{}}
+		if r.task == "" {
+			return nil, errors.New("No task for reminder " + name)
+		}
+		for _, day := range strings.Split(get("days", "mon,tue,wed,thu,fri"), ",") {
+			wd, err := parseWeekday(strings.TrimSpace(day))
+			if err != nil {
+				return nil, invalid("days")
+			}
+			r.days[wd] = true
+		}
+		var ok bool
+		if r.hour, r.min, _, ok = parseClock(get("at", "")); !ok {
+			return nil, invalid("at")
+		}
+		var err error
+		if r.duration, err = parseDuration(get("duration", "15m")); err != nil {
+			return nil, invalid("duration")
+		}
+		every, err := parseDuration(get("every", "1w"))
+		if err != nil || every%(7*24*time.Hour) != 0 || every <= 0 {
+			return nil, invalid("every")
+		}
+		r.weeks = int(every / (7 * 24 * time.Hour))
+		if r.from, err = time.ParseInLocation("2006-01-02", get("from", "2000-01-03"), time.Local); err != nil {
+			return nil, invalid("from")
+		}
+		answer = append(answer, r)
+	}
+	sort.Slice(answer, func(i, j int) bool { return answer[i].name < answer[j].name })
+	return answer, nil
+}
+
+//slot returns when the reminder's slot is on the given day, if it has one
+func (r reminder) slot(day time.Time) (start, end time.Time, ok bool) {
+	if !r.days[day.Weekday()] {
+		return never, never, false
+	}
+	weeks := int(weekStart(day).Sub(weekStart(r.from)).Hours()/24+0.5) / 7
+	if weeks%r.weeks != 0 {
+		return never, never, false
+	}
+	start = time.Date(day.Year(), day.Month(), day.Day(), r.hour, r.min, 0, 0, time.Local)
+	return start, start.Add(r.duration), true
+}
+
+//remindCommand lists the slots in the period which have passed without a log in their
+//task that day, optionally as desktop notifications, and exits 1 if there are any. It
+//is meant to be run regularly, e.g. from cron.
+func remindCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	from, to, err := parsePeriod(opts.get("period", "today"))
+	if err != nil {
+		panic(err)
+	}
+	if from == never || to == never {
+		panic(errors.New("Reminders can only be checked for a period with a start and an end"))
+	}
+	reminders, err := loadReminders(loadGlobalConfig())
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	missed := 0
+	for _, r := range reminders {
+		day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+		for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+			start, end, ok := r.slot(day)
+			if !ok || end.After(now) {
+				continue
+			}
+			done := false
+			if t, err := loadTask(dir + "/" + r.task); err == nil {
+				done = t.hasLogsMatching(between(day, day.AddDate(0, 0, 1)))
+			}
+			if done {
+				continue
+			}
+			missed++
+			message := "No log in " + r.task + " for " + r.name + " at " + start.Format("2006-01-02 15:04")
+			inform(message)
+			if opts.has("notify") {
+				if err := exec.Command("notify-send", "horolog", message).Run(); err != nil {
+					panic(errors.New("--notify requires notify-send: " + err.Error()))
+				}
+			}
+		}
+	}
+	if missed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//reportData is what report templates are executed with
+type reportData struct {
+	Root   string
+	Period string
+	From   time.Time
+	To     time.Time
+	Total  time.Duration
+	Tasks  []reportTask
+	Logs   []reportLog
+	Users  map[string]time.Duration
+	//Milestones are those reached in the period, oldest first
+	Milestones []reportMilestone
+}
+
+//a reportMilestone is a milestone with the time logged since the one before
+type reportMilestone struct {
+	Name string
+	Task string
+	At   time.Time
+	Time time.Duration
+}
+
+//a reportTask is a task with logs in the period, listed parents first
+type reportTask struct {
+	Name  string
+	Depth int
+	Total time.Duration
+	Logs  []reportLog
+}
+
+type reportLog struct {
+	Task     string
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+	Author   string
+	Note     string
+	Meta     map[string]string
+	//Markdown is whether the note is written in Markdown
+	Markdown bool
+}
+
+func newReportLog(t task, l log) reportLog {
+	rel, err := filepath.Rel(t.path(), l.dir())
+	if err != nil {
+		rel = l.dir()
+	}
+	fm, note := parseFrontMatter(l.text())
+	return reportLog{
+		Task:     filepath.ToSlash(rel),
+		Start:    l.start(),
+		End:      l.end(),
+		Duration: l.duration(),
+		Author:   l.author(),
+		Note:     note,
+		Meta:     fm.flatten(),
+		Markdown: l.markdown(),
+	}
+}
+
+func newReportData(t task, period string, from, to time.Time) reportData {
+	data := reportData{Root: t.path(), Period: period, From: from, To: to, Users: map[string]time.Duration{}}
+	for _, line := range t.summaryLines(between(from, to)) {
+		rt := reportTask{Name: filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(line.task.path(), t.path()), "/")), Total: line.logs.duration()}
+		if rt.Name == "" {
+			rt.Name = "."
+		} else {
+			rt.Depth = strings.Count(rt.Name, "/") + 1
+		}
+		sort.Sort(logsByStart(line.logs))
+		for _, l := range line.logs {
+			rl := newReportLog(t, l)
+			rt.Logs = append(rt.Logs, rl)
+			data.Logs = append(data.Logs, rl)
+			data.Users[l.author()] += l.duration()
+		}
+		data.Total += rt.Total
+		data.Tasks = append(data.Tasks, rt)
+	}
+	sort.SliceStable(data.Logs, func(i, j int) bool { return data.Logs[i].Start.Before(data.Logs[j].Start) })
+	ms, err := loadMilestones(t)
+	if err != nil {
+		panic(err)
+	}
+	for i, d := range sinceMilestones(t, ms) {
+		if (from == never || !ms[i].at.Before(from)) && (to == never || ms[i].at.Before(to)) {
+			data.Milestones = append(data.Milestones, reportMilestone{ms[i].name, ms[i].task, ms[i].at, d})
+		}
+	}
+	return data
+}
+
+var reportFuncs = template.FuncMap{
+	"hours": func(d time.Duration) string { return fmt.Sprintf("%.2f", d.Hours()) },
+	"clock": formatHoursMinutes,
+	"firstLine": func(s string) string {
+		return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+	},
+	"title":  noteTitle,
+	"indent": func(depth int) string { return strings.Repeat("  ", depth) },
+	"join":   strings.Join,
+	"trim":   strings.TrimSpace,
+}
+
+const defaultReportTemplate = `Report for {{.Root}} ({{.Period}})
+Total: {{clock .Total}}
+{{range .Tasks}}{{$depth := .Depth}}
+{{indent $depth}}{{.Name}} {{clock .Total}}
+{{- range .Logs}}
+{{indent $depth}}  {{.Start.Format "Mon 02 Jan 15:04"}} {{clock .Duration}} {{firstLine .Note}}
+{{- end}}
+{{end}}
+{{- if .Milestones}}
+Milestones
+{{- range .Milestones}}
+  {{.At.Format "Mon 02 Jan 15:04"}} {{.Name}} ({{clock .Time}} since the last)
+{{- end}}
+{{end}}`
+
+//reportTemplateDir is where report looks for templates named on the command line
+func reportTemplateDir() string {
+	return filepath.Join(configDir(), "templates")
+}
+
+func loadReportTemplate(name string) (*template.Template, error) {
+	if name == "" {
+		return template.New("default").Funcs(reportFuncs).Parse(defaultReportTemplate)
+	}
+	path := name
+	if !strings.ContainsRune(name, os.PathSeparator) && !strings.HasSuffix(name, ".tmpl") {
+		path = filepath.Join(reportTemplateDir(), name+".tmpl")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Cannot read template " + name + ": " + err.Error())
+	}
+	return template.New(filepath.Base(path)).Funcs(reportFuncs).Parse(string(b))
+}
+
+func reportCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	period := opts.get("period", "this-week")
+	from, to, err := parsePeriod(period)
+	if err != nil {
+		panic(err)
+	}
+	switch opts.get("format", "text") {
+	case "text":
+	case "html":
+		if err := writeHTMLReport(os.Stdout, t, period, from, to); err != nil {
+			panic(err)
+		}
+		return
+	default:
+		panic(errors.New("Invalid --format: " + opts.get("format", "") + " (use text or html)"))
+	}
+	tmpl, err := loadReportTemplate(opts.get("template", ""))
+	if err != nil {
+		panic(err)
+	}
+	err = tmpl.Execute(os.Stdout, newReportData(t, period, from, to))
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//a reviewer walks through logs asking what to do with each, reading answers from stdin
+type reviewer struct {
+	root   task
+	reader *bufio.Reader
+	force  bool
+}
+
+//ask prints a question and returns the answer, or "" at the end of the input
+func (r reviewer) ask(question string) string {
+	fmt.Fprint(os.Stderr, question)
+	answer, err := r.reader.ReadString('\n')
+	if err != nil && answer == "" {
+		return "q"
+	}
+	return strings.TrimSpace(answer)
+}
+
+//move renames a log to the given task and times, keeping its author, and returns the new log
+func (r reviewer) move(l log, t task, start, end time.Time, action string) log {
+	if !end.After(start) {
+		panic(errors.New("Invalid Times: " + end.Format("15:04") + " is not after " + start.Format("15:04")))
+	}
+	task(strings.TrimSuffix(l.dir(), "/")).checkUnlocked(l.start(), l.end(), r.force)
+	t.checkUnlocked(start, end, r.force)
+	path := t.authoredLogPath(start, end, l.author())
+	if path == l.path() {
+		return l
+	}
+	if _, err := renameLog(l.path(), path, r.force); err != nil {
+		panic(err)
+	}
+	audit(r.root.path(), action, treePath(l.path()), treePath(path))
+	return log(path)
+}
+
+//merge replaces two logs with one in the first's task, from the start of the first to the
+//end of whichever ends last, with both notes
+func (r reviewer) merge(prev, l log) log {
+	end := prev.end()
+	if l.end().After(end) {
+		end = l.end()
+	}
+	t := task(strings.TrimSuffix(prev.dir(), "/"))
+	task(strings.TrimSuffix(l.dir(), "/")).checkUnlocked(l.start(), l.end(), r.force)
+	t.checkUnlocked(prev.start(), end, r.force)
+	note := strings.TrimRight(prev.text(), "\n")
+	if extra := strings.TrimSpace(l.text()); extra != "" {
+		if note != "" {
+			note += "\n"
+		}
+		note += extra
+	}
+	if note != "" {
+		note += "\n"
+	}
+	path := t.authoredLogPath(prev.start(), end, prev.author())
+	write := writeNewLog
+	if path == prev.path() || path == l.path() {
+		write = rewriteLog
+	}
+	if _, err := write(path, []byte(note), r.force); err != nil {
+		panic(err)
+	}
+	for _, old := range []log{prev, l} {
+		if old.path() != path {
+			if _, err := removeLog(old.path(), r.force); err != nil {
+				panic(err)
+			}
+		}
+	}
+	audit(r.root.path(), "merge", treePath(prev.path()), treePath(l.path()), treePath(path))
+	return log(path)
+}
+
+//confirm removes the provisional marker from a log which has been reviewed
+func (r reviewer) confirm(l log) {
+	if !provisional(l.path()) {
+		return
+	}
+	if _, err := rewriteLog(l.path(), removeFrontMatter([]byte(l.text()), "provisional"), r.force); err != nil {
+		panic(err)
+	}
+	audit(r.root.path(), "confirm", treePath(l.path()))
+}
+
+//describe is a one line summary of a log for review
+func (r reviewer) describe(l log) string {
+	rel, err := filepath.Rel(r.root.path(), l.dir())
+	if err != nil {
+		rel = l.dir()
+	}
+	note := strings.SplitN(strings.TrimSpace(l.note()), "\n", 2)[0]
+	return l.start().Format("15:04") + "-" + l.end().Format("15:04") + " " + formatHoursMinutes(l.duration()) + " " + filepath.ToSlash(rel) + " " + note
+}
+
+//reviewCommand walks through the logs of a day (or period), asking whether to keep each,
+//move it to another task, change its times or merge it into the one before
+func reviewCommand(args []string) {
+	opts, positional := parseOptions(args)
+	period, dir := "today", "."
+	if len(positional) > 0 {
+		period = positional[0]
+	}
+	if len(positional) > 1 {
+		dir = positional[1]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(period)
+	if err != nil {
+		panic(err)
+	}
+	defer lockTree(dir)()
+	f := between(from, to)
+	if opts.has("provisional") {
+		f = func(l log) bool { return between(from, to)(l) && provisional(l.path()) }
+	}
+	ls := t.recursiveLogsMatching(f)
+	sort.Sort(logsByStart(ls))
+	if len(ls) == 0 {
+		inform("No logs to review in", t.path())
+		return
+	}
+
+	r := reviewer{root: t, reader: bufio.NewReader(os.Stdin), force: opts.has("force")}
+	var prev log
+	for i := 0; i < len(ls); i++ {
+		l := ls[i]
+		fmt.Println(r.describe(l))
+		switch strings.ToLower(r.ask("Keep, retag, adjust times, merge with previous, or quit? [k/r/a/m/q] ")) {
+		case "", "k", "keep":
+		case "r", "retag":
+			name := r.ask("Task (beneath " + t.path() + "): ")
+			if name == "" {
+				i--
+				continue
+			}
+			target, err := loadOrCreateTask(importTaskPath(t.path(), name))
+			if err != nil {
+				panic(err)
+			}
+			l = r.move(l, target, l.start(), l.end(), "retag")
+		case "a", "adjust":
+			arg := r.ask("Times (e.g. 09:15-10:30): ")
+			start, end, ok := parseInterval(l.start().Format("2006-01-02") + " " + arg)
+			if !ok {
+				fmt.Fprintln(os.Stderr, "Invalid Times:", arg)
+				i--
+				continue
+			}
+			l = r.move(l, task(strings.TrimSuffix(l.dir(), "/")), start, end, "adjust")
+		case "m", "merge":
+			if prev == "" {
+				fmt.Fprintln(os.Stderr, "There is no previous log to merge with")
+				i--
+				continue
+			}
+			l = r.merge(prev, l)
+		case "q", "quit":
+			return
+		default:
+			i--
+			continue
+		}
+		if opts.has("provisional") {
+			r.confirm(l)
+		}
+		fmt.Println("  ", r.describe(l))
+		prev = l
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+)
+
+//eachRoot calls fn for each of several trees at once, so that trees on different disks
+//or network filesystems are scanned in parallel rather than one after another, and then
+//panics with the first error any of them had
+func eachRoot(roots []summaryRoot, fn func(i int, r summaryRoot)) {
+	//read before starting, rather than by whichever goroutine needs it first
+	loadGlobalConfig()
+	failures := make([]interface{}, len(roots))
+	var wg sync.WaitGroup
+	for i, r := range roots {
+		wg.Add(1)
+		go func(i int, r summaryRoot) {
+			defer wg.Done()
+			defer func() { failures[i] = recover() }()
+			fn(i, r)
+		}(i, r)
+	}
+	wg.Wait()
+	for _, failure := range failures {
+		if failure != nil {
+			panic(failure)
+		}
+	}
+}
+
+//lockedFilter lets several trees be scanned at once with a filter which caches what it
+//has looked up, such as defaultWindows
+func lockedFilter(f filter) filter {
+	var mu sync.Mutex
+	return func(l log) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return f(l)
+	}
+}
+
+//rootTaskName is the name of a task in a query or summary over several trees, prefixed
+//with the name of its tree
+func rootTaskName(r summaryRoot, rel string) string {
+	switch {
+	case r.name == "":
+		return rel
+	case rel == ".":
+		return r.name
+	}
+	return r.name + "/" + rel
+}
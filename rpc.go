@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+//editor plugins run horolog rpc once, as a job, and talk to it with JSON-RPC 2.0, one
+//message per line on its stdin and stdout, rather than running horolog for each action.
+//A session started over rpc belongs to the rpc process, which writes the note it is
+//sent, and logs the session when it is stopped or the editor goes away.
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+//the error codes from the JSON-RPC 2.0 specification, with rpcFailed for everything else
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcFailed         = -32000
+)
+
+//rpcStatus describes a session, as returned by start, stop and status
+type rpcStatus struct {
+	Running bool   `json:"running"`
+	Own     bool   `json:"own,omitempty"`
+	Task    string `json:"task,omitempty"`
+	Dir     string `json:"dir,omitempty"`
+	Start   string `json:"start,omitempty"`
+	Seconds int64  `json:"elapsed_seconds,omitempty"`
+	Note    string `json:"note,omitempty"`
+	Paused  bool   `json:"paused,omitempty"`
+}
+
+func statusOf(s session, own bool) rpcStatus {
+	_, paused := lockedSince()
+	if intr, ok := loadInterruption(); ok && intr.paused == s.pid {
+		paused = true
+	}
+	return rpcStatus{
+		Running: true,
+		Own:     own,
+		Task:    treePath(s.task),
+		Dir:     s.task,
+		Start:   s.start.Format(time.RFC3339),
+		Seconds: int64(s.elapsed().Seconds()),
+		Note:    s.note,
+		Paused:  paused,
+	}
+}
+
+//rpcStartParams.Task is a directory, like the task given on the command line, and
+//Context overrides the context setting
+type rpcStartParams struct {
+	Task          string  `json:"task"`
+	Context       *string `json:"context"`
+	AllowParallel bool    `json:"allow_parallel"`
+	Break         bool    `json:"break"`
+}
+
+//rpcStopParams.Action is keep (the default), discard or break
+type rpcStopParams struct {
+	Action string `json:"action"`
+}
+
+//rpcNoteParams.Text replaces the note, unless Append is set
+type rpcNoteParams struct {
+	Text   string `json:"text"`
+	Append bool   `json:"append"`
+}
+
+//rpcServer runs at most one session at a time
+type rpcServer struct {
+	session *session
+	until   chan string
+	done    chan error
+}
+
+func (r *rpcServer) start(params rpcStartParams) (interface{}, error) {
+	if r.session != nil {
+		return nil, errors.New("Session Already Running: " + r.session.task + ", stop it first")
+	}
+	if params.Task == "" {
+		params.Task = "."
+	}
+	t, err := loadOrCreateTask(params.Task)
+	if err != nil {
+		return nil, err
+	}
+	opts := options{}
+	if params.Context != nil {
+		opts["context"] = []string{*params.Context}
+	}
+	started := make(chan session, 1)
+	until := make(chan string)
+	done := make(chan error, 1)
+	so := sessionOptions{
+		allowParallel: params.AllowParallel,
+		asBreak:       params.Break,
+		context:       sessionContext(t, opts),
+		until:         until,
+		started:       func(s session) { started <- s },
+	}
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("%v", p)
+			}
+		}()
+		done <- t.createLog(so)
+	}()
+	select {
+	case s := <-started:
+		r.session, r.until, r.done = &s, until, done
+		return statusOf(s, true), nil
+	case err := <-done:
+		return nil, err
+	}
+}
+
+func (r *rpcServer) stop(params rpcStopParams) (interface{}, error) {
+	if r.session == nil {
+		return nil, errors.New("No Session Running")
+	}
+	switch params.Action {
+	case "":
+		params.Action = "keep"
+	case "keep", "discard", "break":
+	default:
+		return nil, errors.New("Invalid action: " + params.Action + " (use keep, discard or break)")
+	}
+	answer := statusOf(*r.session, true)
+	answer.Running = false
+	r.until <- params.Action
+	err := <-r.done
+	r.session = nil
+	return answer, err
+}
+
+func (r *rpcServer) note(params rpcNoteParams) (interface{}, error) {
+	if r.session == nil {
+		return nil, errors.New("No Session Running")
+	}
+	flags := os.O_WRONLY | os.O_TRUNC
+	if params.Append {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(r.session.note, flags, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(f, params.Text)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return nil, err
+}
+
+//status describes this process's session, or else the newest one running elsewhere
+func (r *rpcServer) status() (interface{}, error) {
+	if r.session != nil {
+		return statusOf(*r.session, true), nil
+	}
+	sessions := loadSessions()
+	if len(sessions) == 0 {
+		return rpcStatus{}, nil
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].start.After(sessions[j].start) })
+	return statusOf(sessions[0], false), nil
+}
+
+//call runs a method, turning panics into errors
+func (r *rpcServer) call(req rpcRequest) (result interface{}, rerr *rpcError) {
+	defer func() {
+		if p := recover(); p != nil {
+			result, rerr = nil, &rpcError{rpcFailed, fmt.Sprint(p)}
+		}
+	}()
+	params := func(v interface{}) *rpcError {
+		if len(req.Params) == 0 || string(req.Params) == "null" {
+			return nil
+		}
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			return &rpcError{rpcInvalidParams, "Invalid params: " + err.Error()}
+		}
+		return nil
+	}
+	var err error
+	switch req.Method {
+	case "start":
+		var p rpcStartParams
+		if rerr := params(&p); rerr != nil {
+			return nil, rerr
+		}
+		result, err = r.start(p)
+	case "stop":
+		var p rpcStopParams
+		if rerr := params(&p); rerr != nil {
+			return nil, rerr
+		}
+		result, err = r.stop(p)
+	case "note":
+		var p rpcNoteParams
+		if rerr := params(&p); rerr != nil {
+			return nil, rerr
+		}
+		result, err = r.note(p)
+	case "status":
+		result, err = r.status()
+	default:
+		return nil, &rpcError{rpcMethodNotFound, "Unknown method: " + req.Method + " (use start, stop, note or status)"}
+	}
+	if err != nil {
+		return nil, &rpcError{rpcFailed, err.Error()}
+	}
+	return result, nil
+}
+
+func rpcCommand(args []string) {
+	//anything printed along the way, such as by inform, would corrupt the replies
+	out := json.NewEncoder(os.Stdout)
+	os.Stdout = os.Stderr
+	reply := func(id json.RawMessage, result interface{}, rerr *rpcError) {
+		message := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+		if rerr != nil {
+			message["error"] = rerr
+		} else {
+			message["result"] = result
+		}
+		if err := out.Encode(message); err != nil {
+			debug("cannot reply:", err)
+		}
+	}
+
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		in := bufio.NewReader(os.Stdin)
+		for {
+			line, err := in.ReadBytes('\n')
+			lines <- line
+			if err != nil {
+				return
+			}
+		}
+	}()
+	r := &rpcServer{}
+	handle := func(line []byte) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			reply(json.RawMessage("null"), nil, &rpcError{rpcParseError, "Parse error: " + err.Error()})
+		} else if result, rerr := r.call(req); len(req.ID) > 0 {
+			//requests without an id are notifications, which get no reply
+			reply(req.ID, result, rerr)
+		}
+	}
+loop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				break loop
+			}
+			handle(line)
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	//the editor has gone (or horolog was interrupted), so keep what it was logging
+	if r.session != nil {
+		if _, err := r.stop(rpcStopParams{}); err != nil {
+			panic(err)
+		}
+	}
+}
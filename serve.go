@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//serveCommand serves read-only views of a task (or of several trees, with --roots) over
+//HTTP, by default only to this machine, and records activity events and quick-add logs
+//in the task
+func serveCommand(args []string) {
+	opts, positional := parseOptions(args)
+	roots := summaryRoots(opts, positional)
+	t := roots[0].task
+	addr := opts.get("addr", "localhost:8080")
+	trees := make([]*tree, len(roots))
+	var paths []string
+	eachRoot(roots, func(i int, r summaryRoot) {
+		trees[i] = loadTree(r.task)
+	})
+	for i, tr := range trees {
+		go tr.watch(2*time.Second, nil, ctx.Done())
+		paths = append(paths, roots[i].task.path())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.atom", func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parsePeriod(r.URL.Query().Get("period"))
+		if r.URL.Query().Get("period") == "" {
+			from, to, err = parsePeriod("30d")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ls logs
+		for i, tr := range trees {
+			ls = append(ls, tr.logsMatching(roots[i].task, between(from, to))...)
+		}
+		b, err := t.feed(ls)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write(b)
+	})
+
+	mux.HandleFunc("/summary", func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parsePeriod(r.URL.Query().Get("period"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var total time.Duration
+		var header, body string
+		for i, tr := range trees {
+			d, lines := tr.summary(from, to, roots[i].name)
+			total += d
+			body += lines
+			if roots[i].name != "" {
+				header += roots[i].name + " (" + d.String() + ")\n"
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "Total: "+total.String()+"\n"+header+"\n"+body+"\n")
+	})
+
+	mux.Handle("/activity", newActivityRecorder(t))
+	mux.Handle("/quick-add", quickAdder{t, t.setting("quick_add_token", "")})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	inform("Serving " + strings.Join(paths, ", ") + " at http://" + addr + "/feed.atom and /summary, taking activity at /activity")
+	sdNotify("READY=1")
+	if err := server.Serve(ln); err != http.ErrServerClosed {
+		panic(err)
+	}
+	sdNotify("STOPPING=1")
+}
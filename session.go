@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//a session is a log being recorded, which is registered in a file named after the
+//process recording it, so that other tools can show what is being worked on
+type session struct {
+	pid   int
+	task  string
+	start time.Time
+	//interactive sessions are those whose note is written in an editor, prompt or popup
+	interactive bool
+	//note is the file the note is being written in
+	note string
+}
+
+//sessionDir is $XDG_RUNTIME_DIR/horolog/sessions, or a per-user directory in the temp dir
+func sessionDir() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "horolog-"+strconv.Itoa(os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "horolog")
+	}
+	return filepath.Join(dir, "sessions")
+}
+
+func (s session) path() string {
+	return filepath.Join(sessionDir(), strconv.Itoa(s.pid))
+}
+
+func startSession(t task, start time.Time, interactive bool, note string) (session, error) {
+	abs, err := filepath.Abs(t.path())
+	if err != nil {
+		return session{}, err
+	}
+	s := session{pid: os.Getpid(), task: abs, start: start, interactive: interactive, note: note}
+	err = os.MkdirAll(sessionDir(), 0700)
+	if err != nil {
+		return s, err
+	}
+	text := "task = \"" + s.task + "\"\nstart = " + s.start.Format(time.RFC3339Nano) + "\n"
+	text += "interactive = " + strconv.FormatBool(s.interactive) + "\n"
+	text += "note = \"" + s.note + "\"\n"
+	return s, ioutil.WriteFile(s.path(), []byte(text), 0600)
+}
+
+//environ is the environment of programs run for the session, such as the editor, which
+//describes the session
+func (s session) environ() []string {
+	return append(os.Environ(),
+		"HOROLOG_TASK="+treePath(s.task),
+		"HOROLOG_TASK_DIR="+s.task,
+		"HOROLOG_START="+s.start.Format(time.RFC3339),
+		"HOROLOG_NOTE_PATH="+s.note,
+	)
+}
+
+func (s session) end() {
+	s.clearPowerLoss()
+	os.Remove(s.path())
+	os.Remove(s.pausesPath())
+}
+
+func (s session) elapsed() time.Duration {
+	return time.Since(s.start)
+}
+
+//loadSessions returns the sessions in progress, removing any left behind by processes which have died
+func loadSessions() []session {
+	var answer []session
+	files, _ := ioutil.ReadDir(sessionDir())
+	for _, f := range files {
+		pid, err := strconv.Atoi(f.Name())
+		if err != nil {
+			continue
+		}
+		s, err := loadSession(pid)
+		if err != nil || !processAlive(pid) {
+			debug("removing stale session", s.path())
+			s.end()
+			continue
+		}
+		answer = append(answer, s)
+	}
+	return answer
+}
+
+func loadSession(pid int) (session, error) {
+	s := session{pid: pid}
+	c := loadConfig(s.path())
+	s.task = c.get("task", "")
+	s.interactive = c.get("interactive", "false") == "true"
+	s.note = c.get("note", "")
+	var err error
+	s.start, err = time.Parse(time.RFC3339Nano, c.get("start", ""))
+	if err != nil || s.task == "" {
+		return s, errors.New("Invalid Session: " + s.path())
+	}
+	return s, nil
+}
+
+//formatClock formats a duration as h:mm:ss
+func formatClock(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+//showStopwatch keeps the terminal title (or tmux pane title) updated with the elapsed
+//time until the returned function is called, which restores the previous title
+func showStopwatch(t task, start time.Time) func() {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return func() {}
+	}
+	//save the current title on the terminal's title stack
+	fmt.Fprint(tty, "\033[22;0t")
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			fmt.Fprint(tty, "\033]2;"+t.path()+" "+formatClock(time.Since(start))+"\007")
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		fmt.Fprint(tty, "\033[23;0t")
+		tty.Close()
+	}
+}
+
+//statusCommand shows the most recently started session, or with --all every session in
+//progress, as several may be running at once (e.g. a build timed in one terminal while
+//reviewing in another)
+func statusCommand(args []string) {
+	opts, _ := parseOptions(args)
+	sessions := loadSessions()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].start.After(sessions[j].start) })
+	if opts.has("all") {
+		for _, s := range sessions {
+			fmt.Println(s.task, formatClock(s.elapsed()), "since", s.start.Format("15:04:05"), "(pid "+strconv.Itoa(s.pid)+")")
+		}
+		return
+	}
+	if i, ok := loadInterruption(); ok {
+		fmt.Println(i.task, formatClock(time.Since(i.start)), "(interruption: "+i.reason+")")
+	}
+	if len(sessions) > 0 {
+		s := sessions[0]
+		if len(sessions) > 1 {
+			fmt.Println(s.task, formatClock(s.elapsed()), "(+"+strconv.Itoa(len(sessions)-1)+")")
+		} else {
+			fmt.Println(s.task, formatClock(s.elapsed()))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+//watchSleep notices when the computer is suspended during a session, as the monotonic
+//clock stops while it sleeps but the wall clock does not. It returns a function which
+//stops watching and returns when the computer slept.
+func watchSleep() func() []interval {
+	var sleeps []interval
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			now := time.Now()
+			//Round(0) strips the monotonic reading, so this is the time the wall clock skipped
+			if asleep := now.Round(0).Sub(last.Round(0)) - now.Sub(last); asleep > 30*time.Second {
+				sleeps = append(sleeps, interval{last.Round(0), now.Round(0)})
+			}
+			last = now
+		}
+	}()
+	return func() []interval {
+		close(done)
+		<-stopped
+		return sleeps
+	}
+}
+
+//sleepAction decides, from the on_sleep setting or by asking, whether a session during
+//which the computer slept should be kept whole or split into the times it was awake
+func (t task) sleepAction(sleeps []interval) string {
+	action := t.setting("on_sleep", "ask")
+	if action != "ask" {
+		return action
+	}
+	if src, err := os.Stdin.Stat(); err != nil || src.Mode()&os.ModeCharDevice == 0 {
+		return "keep"
+	}
+	var slept []string
+	for _, s := range sleeps {
+		slept = append(slept, s.start.Format("15:04")+"-"+s.end.Format("15:04"))
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "The computer was asleep ("+strings.Join(slept, ", ")+"). Count that time in "+t.path()+"? [y/n] ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "keep"
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return "keep"
+		case "", "n", "no":
+			return "split"
+		}
+	}
+}
+
+//awake returns the parts of the interval from start to end outside of the sleeps
+func awake(start, end time.Time, sleeps []interval) []interval {
+	var answer []interval
+	for _, s := range sleeps {
+		if s.start.After(start) {
+			answer = append(answer, interval{start, s.start})
+		}
+		if s.end.After(start) {
+			start = s.end
+		}
+	}
+	if end.After(start) {
+		answer = append(answer, interval{start, end})
+	}
+	return answer
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//snapshotPath fills in the placeholders of --out: {{date}}, {{time}}, {{week}},
+//{{month}} and {{view}}
+func snapshotPath(pattern, view string, now time.Time) string {
+	year, week := now.ISOWeek()
+	return strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format("150405"),
+		"{{week}}", fmt.Sprintf("%d-W%02d", year, week),
+		"{{month}}", now.Format("2006-01"),
+		"{{view}}", view,
+	).Replace(pattern)
+}
+
+//snapshotLog appends a line about a snapshot to the file given by --log or the
+//snapshot_log setting, if either is set, since nobody is watching the output
+func snapshotLog(opts options, line string) {
+	path := expandHome(opts.get("log", task(".").setting("snapshot_log", "")))
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(time.Now().Format(time.RFC3339) + "\t" + line + "\n"); err != nil {
+		panic(err)
+	}
+}
+
+//snapshotCommand runs a saved view unattended, e.g. from cron or a systemd timer, and
+//writes what it prints to a file. It never prompts, as the view is run without a
+//terminal, and exits with
+//	0 if the snapshot was written
+//	1 if it was written, but the view reported a problem (as check and forecast do)
+//	2 if the snapshot could not be taken, e.g. the options were invalid
+//	3 if the view failed, in which case nothing is written
+func snapshotCommand(args []string) {
+	opts, _ := parseOptions(args)
+	name := opts.get("view", "")
+	if name == "" || !opts.has("out") {
+		panic(errors.New("Usage: horolog snapshot --view=<name> --out=<file> [task] [--log=<file>]"))
+	}
+	found := false
+	for _, v := range loadViews(loadGlobalConfig()) {
+		found = found || v.name == name
+	}
+	if !found {
+		panic(errors.New("No Such View: " + name + " (see horolog views)"))
+	}
+	out := expandHome(snapshotPath(opts.get("out", ""), name, time.Now()))
+
+	exe, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	//any other arguments are passed on to the view
+	runArgs := []string{"--quiet", "view", name}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--view=") && !strings.HasPrefix(arg, "--out=") && !strings.HasPrefix(arg, "--log=") {
+			runArgs = append(runArgs, arg)
+		}
+	}
+	//--quiet keeps confirmations out of the snapshot, and without stdin nothing can prompt
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(exe, runArgs...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	status := 0
+	if exit, ok := err.(*exec.ExitError); ok {
+		status = exit.ExitCode()
+	} else if err != nil {
+		panic(err)
+	}
+	problem := strings.TrimSpace(stderr.String())
+	if problem != "" {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if status != 0 && status != 1 {
+		snapshotLog(opts, name+"\tfailed\t"+strings.Replace(problem, "\n", " ", -1))
+		os.Exit(3)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		panic(err)
+	}
+	if err := writeFileAtomic(out, stdout.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+	result := "written"
+	if status == 1 {
+		result = "written with problems"
+	}
+	snapshotLog(opts, name+"\t"+result+"\t"+out)
+	debug("snapshot of", name, result, "to", out)
+	if status == 1 {
+		os.Exit(1)
+	}
+}
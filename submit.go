@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//submissions are manifests of the logs in a period, in sha256sum format with
+//comments recording what was submitted, e.g.
+//	# task clients
+//	# period 2024-01-08T00:00:00+01:00 2024-01-15T00:00:00+01:00
+//	9f86d081...  clients/acme/2024-01-08 10:00:00+01:00=>2024-01-08 11:00:00+01:00.txt
+//paths are relative to the root of the tree, and open ended periods are recorded as -
+type submission struct {
+	path   string
+	task   string
+	from   time.Time
+	to     time.Time
+	hashes map[string]string
+}
+
+func hashFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func formatPeriodTime(t time.Time) string {
+	if t == never {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func parsePeriodTime(s string) (time.Time, error) {
+	if s == "-" {
+		return never, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func loadSubmission(path string) (submission, error) {
+	s := submission{path: path, task: ".", hashes: map[string]string{}}
+	f, err := os.Open(path)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# task "):
+			s.task = strings.TrimPrefix(line, "# task ")
+		case strings.HasPrefix(line, "# period "):
+			fromTo := strings.Fields(strings.TrimPrefix(line, "# period "))
+			if len(fromTo) != 2 {
+				return s, errors.New("Invalid Submission: " + path)
+			}
+			if s.from, err = parsePeriodTime(fromTo[0]); err != nil {
+				return s, err
+			}
+			if s.to, err = parsePeriodTime(fromTo[1]); err != nil {
+				return s, err
+			}
+		case strings.HasPrefix(line, "#") || line == "":
+		default:
+			hashPath := strings.SplitN(line, "  ", 2)
+			if len(hashPath) != 2 {
+				return s, errors.New("Invalid Submission: " + path)
+			}
+			s.hashes[hashPath[1]] = hashPath[0]
+		}
+	}
+	return s, scanner.Err()
+}
+
+func loadSubmissions(root string) ([]submission, error) {
+	var answer []submission
+	paths, err := filepath.Glob(filepath.Join(root, stateDirName, "submissions", "*.sha256"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		s, err := loadSubmission(path)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, s)
+	}
+	return answer, nil
+}
+
+func submitCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	if !opts.has("period") {
+		panic(errors.New("No period specified, use --period="))
+	}
+	from, to, err := parsePeriod(opts.get("period", ""))
+	if err != nil {
+		panic(err)
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	root := treeRoot(dir)
+	rel, err := filepath.Rel(root, t.path())
+	if err != nil {
+		panic(err)
+	}
+
+	defer lockTree(dir)()
+	ls := t.recursiveLogsMatching(between(from, to))
+	sort.Sort(logsByStart(ls))
+	manifest := "# task " + rel + "\n"
+	manifest += "# period " + formatPeriodTime(from) + " " + formatPeriodTime(to) + "\n"
+	for _, l := range ls {
+		hash, err := hashFile(l.path())
+		if err != nil {
+			panic(err)
+		}
+		rel, err := filepath.Rel(root, l.path())
+		if err != nil {
+			panic(err)
+		}
+		manifest += hash + "  " + rel + "\n"
+	}
+
+	path := filepath.Join(stateDir(root, "submissions"), time.Now().Format("2006-01-02T15-04-05")+".sha256")
+	err = writeFileAtomic(path, []byte(manifest), 0600)
+	if err != nil {
+		panic(err)
+	}
+	inform("Submitted", len(ls), "logs ("+ls.duration().String()+") in", path)
+}
+
+func verifyCommand(args []string) {
+	_, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	root := treeRoot(dir)
+	submissions, err := loadSubmissions(root)
+	if err != nil {
+		panic(err)
+	}
+
+	problems := 0
+	for _, s := range submissions {
+		report := func(problem, path string) {
+			fmt.Println(problem+":", path, "(submitted in "+filepath.Base(s.path)+")")
+			problems++
+		}
+		var paths []string
+		for path := range s.hashes {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			hash, err := hashFile(filepath.Join(root, path))
+			if err != nil {
+				report("removed", path)
+			} else if hash != s.hashes[path] {
+				report("modified", path)
+			}
+		}
+
+		t, err := loadTask(filepath.Join(root, s.task))
+		if err != nil {
+			continue
+		}
+		for _, l := range t.recursiveLogsMatching(between(s.from, s.to)) {
+			rel, err := filepath.Rel(root, l.path())
+			if err != nil {
+				panic(err)
+			}
+			if _, ok := s.hashes[rel]; !ok {
+				report("added", rel)
+			}
+		}
+	}
+
+	if problems > 0 {
+		os.Exit(1)
+	}
+	inform("Verified", len(submissions), "submissions")
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//a summaryLine is a task which has logs matching the summary's filter
+type summaryLine struct {
+	task task
+	logs logs
+}
+
+//summaryLines lists the task and those of its subtasks which have matching logs, parents first
+func (t task) summaryLines(f filter) []summaryLine {
+	var answer []summaryLine
+	if ls := t.logsMatching(f); len(ls) > 0 {
+		answer = append(answer, summaryLine{task: t, logs: ls})
+	}
+	for _, t2 := range t.subtasks() {
+		answer = append(answer, t2.summaryLines(f)...)
+	}
+	return answer
+}
+
+//rollUp merges the lines of tasks more than depth levels below root into their ancestor at that depth
+func rollUp(lines []summaryLine, root task, depth int) []summaryLine {
+	var answer []summaryLine
+	index := map[string]int{}
+	for _, line := range lines {
+		t := line.task
+		rel, err := filepath.Rel(root.path(), t.path())
+		if err == nil && rel != "." {
+			parts := strings.Split(filepath.ToSlash(rel), "/")
+			if depth == 0 {
+				t = root
+			} else if len(parts) > depth {
+				t = task(root.path() + "/" + strings.Join(parts[:depth], "/"))
+			}
+		}
+		i, ok := index[t.path()]
+		if !ok {
+			i = len(answer)
+			index[t.path()] = i
+			answer = append(answer, summaryLine{task: t})
+		}
+		answer[i].logs = append(answer[i].logs, line.logs...)
+	}
+	return answer
+}
+
+//sortLines orders summary lines by duration (longest first), name, or recent (latest activity first)
+func sortLines(lines []summaryLine, by string) error {
+	var less func(a, b summaryLine) bool
+	switch by {
+	case "":
+		return nil
+	case "duration":
+		less = func(a, b summaryLine) bool { return a.logs.duration() > b.logs.duration() }
+	case "name":
+		less = func(a, b summaryLine) bool { return a.task.path() < b.task.path() }
+	case "recent":
+		less = func(a, b summaryLine) bool { return a.logs.lastEnd().After(b.logs.lastEnd()) }
+	default:
+		return errors.New("Invalid --sort: " + by + " (use duration, name or recent)")
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return less(lines[i], lines[j]) })
+	return nil
+}
+
+//a summaryRoot is one of the trees in a summary, and the name its tasks are prefixed with
+type summaryRoot struct {
+	name string
+	task task
+}
+
+//parseRoots parses name:path,name:path, naming unnamed paths after their directory
+func parseRoots(arg string) ([]summaryRoot, error) {
+	var roots []summaryRoot
+	for _, spec := range strings.Split(arg, ",") {
+		if spec == "" {
+			continue
+		}
+		nameDir := strings.SplitN(spec, ":", 2)
+		if len(nameDir) == 1 {
+			nameDir = []string{filepath.Base(spec), spec}
+		}
+		t, err := loadTask(expandHome(nameDir[1]))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, summaryRoot{name: nameDir[0], task: t})
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("No roots specified")
+	}
+	return roots, nil
+}
+
+func summaryCommand(args []string) {
+	opts, positional := parseOptions(args)
+	from, to, err := parsePeriod(opts.get("period", ""))
+	if err != nil {
+		panic(err)
+	}
+	f := between(from, to)
+	if !opts.has("period") && !opts.has("all") {
+		f = defaultWindows()
+	}
+	printSummary(f, opts, positional)
+}
+
+//summaryRoots returns the trees given by --roots (or the roots setting, for --roots
+//alone), or else the task given as an argument
+func summaryRoots(opts options, positional []string) []summaryRoot {
+	if opts.has("roots") {
+		arg := opts.get("roots", "")
+		if arg == "" {
+			arg = loadGlobalConfig().get("roots", "")
+		}
+		roots, err := parseRoots(arg)
+		if err != nil {
+			panic(err)
+		}
+		return roots
+	}
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	return []summaryRoot{{task: t}}
+}
+
+func printSummary(f filter, opts options, positional []string) {
+	roots := summaryRoots(opts, positional)
+	var dirs []string
+	for _, r := range roots {
+		dirs = append(dirs, r.task.path())
+	}
+	fmt.Print(cachedOutput(dirs, cacheTTL(roots[0].task, opts), func() string {
+		return summaryText(f, opts, positional)
+	}))
+}
+
+func summaryText(f filter, opts options, positional []string) string {
+	roots := summaryRoots(opts, positional)
+	depth := -1
+	if opts.has("depth") {
+		var err error
+		depth, err = strconv.Atoi(opts.get("depth", ""))
+		if err != nil || depth < 0 {
+			panic(errors.New("Invalid --depth: " + opts.get("depth", "")))
+		}
+	}
+
+	if opts.has("no-copies") {
+		copies, inner := notCopies(), f
+		f = func(l log) bool { return inner(l) && copies(l) }
+	}
+
+	var all logs
+	rootLines := make([][]summaryLine, len(roots))
+	if len(roots) > 1 {
+		f = lockedFilter(f)
+	}
+	eachRoot(roots, func(i int, r summaryRoot) {
+		rootLines[i] = r.task.summaryLines(f)
+		if depth >= 0 {
+			rootLines[i] = rollUp(rootLines[i], r.task, depth)
+		}
+		if err := sortLines(rootLines[i], opts.get("sort", "")); err != nil {
+			panic(err)
+		}
+	})
+	for i := range roots {
+		for _, line := range rootLines[i] {
+			all = append(all, line.logs...)
+		}
+	}
+	total := all.duration()
+
+	var header, body string
+	for i, r := range roots {
+		var rootLogs logs
+		recursive := recursiveTotals(rootLines[i], r.task)
+		for _, line := range rootLines[i] {
+			rootLogs = append(rootLogs, line.logs...)
+			if opts.has("group-by") {
+				continue
+			}
+			name := line.task.path()
+			if r.name != "" {
+				name = r.name + strings.TrimPrefix(name, r.task.path())
+			}
+			if opts.has("large") {
+				body += formatHoursMinutes(line.logs.duration()) + " " + strings.TrimPrefix(name, "./") + "\n"
+				continue
+			}
+			body += name + " (" + line.logs.duration().String()
+			if opts.has("percent") {
+				body += ", " + percent(line.logs.duration(), total) + " of total"
+				if parent := parentPath(line.task.path(), r.task.path()); parent != r.task.path() && parent != "" {
+					body += ", " + percent(recursive[line.task.path()], recursive[parent]) + " of " + strings.TrimPrefix(parent, r.task.path()+"/")
+				}
+			}
+			body += ")\n"
+			if opts.has("by-user") {
+				body += line.logs.summaryByUser("\t")
+			}
+			if opts.has("titles") {
+				for j, note := range readNotes(line.logs, noteWorkers(r.task, opts)) {
+					body += "\t" + titleLine(line.logs[j], note) + "\n"
+				}
+			}
+		}
+		if opts.has("group-by") {
+			if r.name != "" {
+				body += r.name + ":\n"
+			}
+			body += groupedSummary(rootLogs, r.task, opts.get("group-by", ""))
+		}
+		if r.name != "" {
+			header += r.name + " (" + rootLogs.duration().String()
+			if opts.has("percent") {
+				header += ", " + percent(rootLogs.duration(), total)
+			}
+			header += ")\n"
+		}
+	}
+	if opts.has("by-user") {
+		header += all.summaryByUser("")
+	}
+	if opts.has("overlap") {
+		header += all.overlapByUser()
+	}
+
+	if opts.has("large") {
+		return bigText(formatHoursMinutes(total)) + "\n" + body
+	}
+	return "Total: " + total.String() + "\n" + header + "\n" + body + "\n"
+}
+
+//parentPath returns the path of the task above the given one, or "" for the root
+func parentPath(path, root string) string {
+	if path == root {
+		return ""
+	}
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return root
+	}
+	return path[:i]
+}
+
+//recursiveTotals is the time in each task including its subtasks, keyed by path
+func recursiveTotals(lines []summaryLine, root task) map[string]time.Duration {
+	totals := map[string]time.Duration{}
+	for _, line := range lines {
+		d := line.logs.duration()
+		for path := line.task.path(); path != ""; path = parentPath(path, root.path()) {
+			totals[path] += d
+		}
+	}
+	return totals
+}
+
+func percent(part, whole time.Duration) string {
+	if whole == 0 {
+		return "-%"
+	}
+	return strconv.FormatFloat(100*float64(part)/float64(whole), 'f', 1, 64) + "%"
+}
+
+//overlap is how much the total duration of the logs exceeds the time they cover,
+//because some of them were recorded in parallel
+func (ls logs) overlap() time.Duration {
+	sorted := append(logs(nil), ls...)
+	sort.Sort(logsByStart(sorted))
+	var answer time.Duration
+	var end time.Time
+	for _, l := range sorted {
+		if l.start().Before(end) {
+			overlapEnd := l.end()
+			if end.Before(overlapEnd) {
+				overlapEnd = end
+			}
+			answer += overlapEnd.Sub(l.start())
+		}
+		if l.end().After(end) {
+			end = l.end()
+		}
+	}
+	return answer
+}
+
+//overlapByUser lists the time each user logged in parallel sessions, if any
+func (ls logs) overlapByUser() string {
+	byUser := map[string]logs{}
+	var users []string
+	for _, l := range ls {
+		user := l.author()
+		if user == "" {
+			user = "(unknown)"
+		}
+		if _, ok := byUser[user]; !ok {
+			users = append(users, user)
+		}
+		byUser[user] = append(byUser[user], l)
+	}
+	sort.Strings(users)
+	var answer string
+	for _, user := range users {
+		if overlap := byUser[user].overlap(); overlap > 0 {
+			answer += user + " (" + overlap.String() + " in parallel)\n"
+		}
+	}
+	return answer
+}
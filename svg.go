@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+//charts can also be drawn as SVG, which unlike the PNG charts can be labelled with any text,
+//for reports shared outside the terminal
+
+var chartPalette = []string{"#4a90d9", "#e8a33d", "#5cb85c", "#d9534f", "#9b59b6", "#1abc9c", "#e67e22", "#95a5a6"}
+
+func svgHeader(w io.Writer, width, height int) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+}
+
+//svgBars draws the bars vertically, labelled with the dates they start and gridlines in hours
+func svgBars(w io.Writer, bars []chartBar) error {
+	const height, left, bottom, top = 320, 50, 40, 15
+	barWidth := 600 / len(bars)
+	if barWidth < 4 {
+		barWidth = 4
+	} else if barWidth > 60 {
+		barWidth = 60
+	}
+	width := left + len(bars)*barWidth + 20
+	svgHeader(w, width, height)
+
+	var max time.Duration
+	for _, b := range bars {
+		if b.value > max {
+			max = b.value
+		}
+	}
+	step := chartScale(max)
+	ceiling := math.Ceil(max.Hours()/step) * step
+	if ceiling == 0 {
+		ceiling = step
+	}
+	plot := float64(height - bottom - top)
+	y := func(hours float64) float64 { return float64(height-bottom) - hours/ceiling*plot }
+	for h := 0.0; h <= ceiling; h += step {
+		fmt.Fprintf(w, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e1e1e1"/>`+"\n", left, y(h), width-10, y(h))
+		fmt.Fprintf(w, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", left-6, y(h), formatHoursMinutes(time.Duration(h*float64(time.Hour))))
+	}
+	labelEvery := int(math.Ceil(70 / float64(barWidth)))
+	for i, b := range bars {
+		x := left + i*barWidth
+		fmt.Fprintf(w, `<rect x="%d" y="%.1f" width="%d" height="%.1f" fill="%s"><title>%s %s</title></rect>`+"\n",
+			x+1, y(b.value.Hours()), barWidth-2, float64(height-bottom)-y(b.value.Hours()), chartPalette[0], b.start.Format("2006-01-02"), formatHoursMinutes(b.value))
+		if i%labelEvery == 0 {
+			fmt.Fprintf(w, `<text x="%d" y="%d">%s</text>`+"\n", x, height-bottom+16, b.start.Format("2006-01-02"))
+		}
+		if len(b.milestones) > 0 {
+			fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4 3"><title>%s</title></line>`+"\n",
+				x+barWidth/2, top, x+barWidth/2, height-bottom, chartPalette[3], html.EscapeString(strings.Join(b.milestones, ", ")))
+		}
+	}
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#3c3c3c"/>`+"\n", left, height-bottom, width-10, height-bottom)
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+//heatmap is the time logged in each hour of each day of the week, Monday first
+func heatmap(ls logs) [7][24]time.Duration {
+	var cells [7][24]time.Duration
+	for _, l := range ls {
+		for t := l.start().Local(); t.Before(l.end()); {
+			next := t.Truncate(time.Hour).Add(time.Hour)
+			if next.After(l.end()) {
+				next = l.end()
+			}
+			cells[(int(t.Weekday())+6)%7][t.Hour()] += next.Sub(t)
+			t = next
+		}
+	}
+	return cells
+}
+
+//svgHeatmap shades each hour of each weekday by the time logged in it
+func svgHeatmap(w io.Writer, ls logs) error {
+	const cell, left, top = 22, 40, 20
+	cells := heatmap(ls)
+	var max time.Duration
+	for _, row := range cells {
+		for _, d := range row {
+			if d > max {
+				max = d
+			}
+		}
+	}
+	svgHeader(w, left+24*cell+10, top+7*cell+10)
+	for h := 0; h < 24; h += 3 {
+		fmt.Fprintf(w, `<text x="%d" y="%d">%02d</text>`+"\n", left+h*cell+3, top-6, h)
+	}
+	for day, row := range cells {
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", left-6, top+day*cell+cell/2, time.Weekday((day + 1) % 7).String()[:3])
+		for h, d := range row {
+			opacity := 0.0
+			if max > 0 {
+				opacity = float64(d) / float64(max)
+			}
+			fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="%.2f" stroke="#f0f0f0"><title>%s %02d:00 %s</title></rect>`+"\n",
+				left+h*cell, top+day*cell, cell, cell, chartPalette[0], opacity, time.Weekday((day + 1) % 7).String()[:3], h, formatHoursMinutes(d))
+		}
+	}
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+//a pieSlice is a task's share of the time
+type pieSlice struct {
+	label string
+	value time.Duration
+}
+
+//taskSlices is the time in each of the task's subtasks (including their own subtasks),
+//and in the task itself, largest first
+func taskSlices(t task, f filter) []pieSlice {
+	var answer []pieSlice
+	for _, line := range rollUp(t.summaryLines(f), t, 1) {
+		label := strings.TrimPrefix(strings.TrimPrefix(line.task.path(), t.path()), "/")
+		if label == "" {
+			label = "."
+		}
+		answer = append(answer, pieSlice{label, line.logs.duration()})
+	}
+	sort.Slice(answer, func(i, j int) bool { return answer[i].value > answer[j].value })
+	return answer
+}
+
+//svgPie draws each slice's share of the total, with a legend
+func svgPie(w io.Writer, slices []pieSlice) error {
+	const r, cx, cy = 100, 120, 120
+	var total time.Duration
+	for _, s := range slices {
+		total += s.value
+	}
+	height := 2 * cy
+	if h := 30 + 18*len(slices); h > height {
+		height = h
+	}
+	svgHeader(w, 480, height)
+	angle := -math.Pi / 2
+	for i, s := range slices {
+		colour := chartPalette[i%len(chartPalette)]
+		share := float64(s.value) / float64(total)
+		title := html.EscapeString(s.label) + " " + formatHoursMinutes(s.value) + " (" + percent(s.value, total) + ")"
+		if share >= 0.9999 {
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="%s"><title>%s</title></circle>`+"\n", cx, cy, r, colour, title)
+		} else if share > 0 {
+			end := angle + share*2*math.Pi
+			large := 0
+			if share > 0.5 {
+				large = 1
+			}
+			fmt.Fprintf(w, `<path d="M%d,%d L%.2f,%.2f A%d,%d 0 %d 1 %.2f,%.2f Z" fill="%s" stroke="white"><title>%s</title></path>`+"\n",
+				cx, cy, cx+r*math.Cos(angle), cy+r*math.Sin(angle), r, r, large, cx+r*math.Cos(end), cy+r*math.Sin(end), colour, title)
+			angle = end
+		}
+		y := 20 + i*18
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n", 2*cx+10, y, colour)
+		fmt.Fprintf(w, `<text x="%d" y="%d">%s</text>`+"\n", 2*cx+28, y+10, title)
+	}
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+//svgString renders a chart into a string, for embedding in HTML
+func svgString(draw func(w io.Writer) error) string {
+	var b strings.Builder
+	if err := draw(&b); err != nil {
+		panic(err)
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//followLink returns what an entry of t's directory points to if it is a symbolic link, so
+//that tasks can be linked into several places in a tree, or else fi itself. It returns nil
+//for broken links, and for links to a directory containing t, which would make the tree
+//infinitely deep.
+func (t task) followLink(path string, fi os.FileInfo) os.FileInfo {
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return fi
+	}
+	target, err := os.Stat(path)
+	if err != nil {
+		debug("skipping broken link", path)
+		return nil
+	}
+	if target.IsDir() && t.linksBack(path) {
+		skip(path, errors.New("Symlink Cycle: "+path+" leads back to a task containing it"))
+		return nil
+	}
+	return target
+}
+
+//linksBack reports whether the link at path leads to t or a directory above it. The
+//directories above t are those of its path as given, even where it passed through other
+//links, so cycles through several links are found too.
+func (t task) linksBack(path string) bool {
+	target, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		target, err = filepath.Abs(target)
+	}
+	if err != nil {
+		return false
+	}
+	dir, err := filepath.Abs(t.path())
+	if err != nil {
+		return false
+	}
+	for {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			if rel, err := filepath.Rel(target, real); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+				return true
+			}
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+		dir = filepath.Dir(dir)
+	}
+}
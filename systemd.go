@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//sdNotify tells systemd about the state of a service started with Type=notify, e.g.
+//READY=1, and does nothing when horolog was not started by systemd
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if strings.HasPrefix(socket, "@") {
+		//an abstract socket
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		debug("cannot notify systemd:", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		debug("cannot notify systemd:", err)
+	}
+}
+
+//systemdQuote quotes an argument of ExecStart if it needs it
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%;") {
+		return arg
+	}
+	return strconv.Quote(strings.Replace(strings.Replace(arg, "%", "%%", -1), "$", "$$", -1))
+}
+
+func systemdExec(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+//a scheduledView is a view with a schedule, whose snapshots are taken by a timer
+type scheduledView struct {
+	name     string
+	schedule string
+	out      string
+}
+
+//scheduledViews returns the views whose config sets a schedule (a systemd calendar
+//event) and where to write their snapshots
+func scheduledViews() []scheduledView {
+	var answer []scheduledView
+	c := loadGlobalConfig()
+	for _, v := range loadViews(c) {
+		schedule := c.get("view."+v.name+".schedule", "")
+		if schedule == "" {
+			continue
+		}
+		out := c.get("view."+v.name+".out", "")
+		if out == "" {
+			panic(errors.New("No out for scheduled view " + v.name + " (e.g. out = \"~/reports/{{date}}.html\")"))
+		}
+		answer = append(answer, scheduledView{v.name, schedule, out})
+	}
+	return answer
+}
+
+//systemdUnits returns the user units to run horolog's services for the tree at root: the
+//daemon (serve), the idle watcher (watch), and a timer for each view with a schedule
+func systemdUnits(exe, root string, opts options) map[string]string {
+	units := map[string]string{}
+	units["horolog-serve.service"] = `[Unit]
+Description=horolog feed and summaries for ` + root + `
+
+[Service]
+Type=notify
+WorkingDirectory=` + root + `
+ExecStart=` + systemdExec(exe, "serve", root, "--addr="+opts.get("addr", "localhost:8080")) + `
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`
+	units["horolog-watch.service"] = `[Unit]
+Description=horolog idle watcher, pausing sessions while the screen is locked
+After=graphical-session.target
+PartOf=graphical-session.target
+
+[Service]
+Type=notify
+ExecStart=` + systemdExec(exe, "watch") + `
+# watch logs the sessions it started for autostart rules as it stops
+KillMode=mixed
+Restart=on-failure
+
+[Install]
+WantedBy=graphical-session.target
+`
+	for _, v := range scheduledViews() {
+		name := "horolog-snapshot-" + v.name
+		units[name+".service"] = `[Unit]
+Description=horolog snapshot of ` + v.name + `
+
+[Service]
+Type=oneshot
+WorkingDirectory=` + root + `
+ExecStart=` + systemdExec(exe, "snapshot", "--view="+v.name, "--out="+v.out) + `
+`
+		units[name+".timer"] = `[Unit]
+Description=horolog snapshot of ` + v.name + ` (` + v.schedule + `)
+
+[Timer]
+OnCalendar=` + v.schedule + `
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+	}
+	return units
+}
+
+//installServiceCommand writes systemd user units (or on macOS, launchd agents) for
+//horolog's services, which can then be enabled with systemctl --user (or launchctl)
+func installServiceCommand(args []string) {
+	opts, positional := parseOptions(args)
+	if !opts.has("user") {
+		panic(errors.New("Only user services are supported, use horolog install-service --user"))
+	}
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	root, err := filepath.Abs(treeRoot(t.path()))
+	if err != nil {
+		panic(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	initSystem := opts.get("init", "systemd")
+	if !opts.has("init") && runtime.GOOS == "darwin" {
+		initSystem = "launchd"
+	}
+	var units map[string]string
+	unitDir := opts.get("dir", "")
+	switch initSystem {
+	case "systemd":
+		if unitDir == "" {
+			unitDir = filepath.Join(filepath.Dir(configDir()), "systemd", "user")
+		}
+		units = systemdUnits(exe, root, opts)
+	case "launchd":
+		if unitDir == "" {
+			home, _ := os.UserHomeDir()
+			unitDir = filepath.Join(home, "Library", "LaunchAgents")
+		}
+		units = launchdAgents(exe, root, opts)
+	default:
+		panic(errors.New("Invalid --init: " + initSystem + " (use systemd or launchd)"))
+	}
+	var names []string
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if opts.has("dry-run") {
+		for _, name := range names {
+			fmt.Println("#", filepath.Join(unitDir, name))
+			fmt.Println(units[name])
+		}
+		return
+	}
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		panic(err)
+	}
+	var enable []string
+	for _, name := range names {
+		path := filepath.Join(unitDir, name)
+		if err := writeFileAtomic(path, []byte(units[name]), 0644); err != nil {
+			panic(err)
+		}
+		inform("Wrote", path)
+		if initSystem == "launchd" {
+			enable = append(enable, path)
+		} else if !strings.HasSuffix(name, ".service") || !strings.HasPrefix(name, "horolog-snapshot-") {
+			enable = append(enable, name)
+		}
+	}
+	if initSystem == "launchd" {
+		inform("Load them with: launchctl load -w " + strings.Join(enable, " "))
+	} else {
+		inform("Enable them with: systemctl --user daemon-reload && systemctl --user enable --now " + strings.Join(enable, " "))
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+)
+
+//logsDirName is a directory in a task which may hold its logs, so that the task's directory
+//can double as a project directory. Logs are always read from both, and new logs are
+//written there when the timelogs setting is on.
+const logsDirName = ".timelogs"
+
+//logsDir is the directory new logs in the task are written to, which is created if it is
+//the task's .timelogs
+func (t task) logsDir() string {
+	if t.setting("timelogs", "false") != "true" {
+		return t.path()
+	}
+	dir := t.path() + "/" + logsDirName
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		panic(err)
+	}
+	return dir
+}
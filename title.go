@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+)
+
+//noteTitle is the first line of a note (after any front matter), which serves as the
+//title of its log, without the # of a Markdown heading
+func noteTitle(note string) string {
+	_, body := parseFrontMatter(note)
+	title := strings.SplitN(strings.TrimSpace(body), "\n", 2)[0]
+	return strings.TrimSpace(strings.TrimLeft(title, "#"))
+}
+
+//titleLine is a log's start, duration and title (following any labels, such as its
+//task) on one line, for --titles
+func titleLine(l log, note string, labels ...string) string {
+	fields := append([]string{l.start().Format("2006-01-02 15:04") + " " + formatHoursMinutes(l.duration())}, labels...)
+	return strings.TrimSpace(strings.Join(append(fields, noteTitle(note)), "\t"))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//tmux runs a tmux command, returning what it printed
+func tmux(args ...string) (string, error) {
+	out, err := exec.Command("tmux", args...).Output()
+	return strings.TrimSuffix(string(out), "\n"), err
+}
+
+//renameTmuxWindow names the tmux window after the task until the returned function is
+//called, which restores its name, or does nothing outside tmux
+func renameTmuxWindow(t task) func() {
+	pane := os.Getenv("TMUX_PANE")
+	if os.Getenv("TMUX") == "" || pane == "" {
+		return func() {}
+	}
+	name, err := tmux("display-message", "-p", "-t", pane, "#W")
+	if err != nil {
+		debug("cannot rename the tmux window:", err)
+		return func() {}
+	}
+	automatic, _ := tmux("display-message", "-p", "-t", pane, "#{automatic-rename}")
+	abs, err := filepath.Abs(t.path())
+	if err != nil {
+		abs = t.path()
+	}
+	if _, err := tmux("rename-window", "-t", pane, filepath.Base(abs)); err != nil {
+		debug("cannot rename the tmux window:", err)
+		return func() {}
+	}
+	return func() {
+		//renaming a window turns off automatic renaming, so turn it back on if it was
+		if automatic == "1" {
+			tmux("set-window-option", "-t", pane, "automatic-rename", "on")
+		} else {
+			tmux("rename-window", "-t", pane, name)
+		}
+	}
+}
+
+//tmuxStatusCommand prints a short line for tmux's status-right, e.g.
+//	set -g status-right '#(horolog tmux-status)'
+//with the task being logged and its elapsed time, or nothing when there is none
+func tmuxStatusCommand(args []string) {
+	opts, _ := parseOptions(args)
+	width, err := strconv.Atoi(opts.get("width", "20"))
+	if err != nil || width < 1 {
+		width = 20
+	}
+	short := func(task string) string {
+		return shortName(filepath.Base(task), width)
+	}
+	clock := func(d time.Duration) string {
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+
+	var parts []string
+	intr, interrupted := loadInterruption()
+	if interrupted {
+		parts = append(parts, "⚡ "+short(intr.task)+" "+clock(time.Since(intr.start)))
+	}
+	sessions := loadSessions()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].start.After(sessions[j].start) })
+	if len(sessions) > 0 {
+		s := sessions[0]
+		line := "⏱ " + short(s.task) + " " + clock(s.elapsed())
+		if len(sessions) > 1 {
+			line += " +" + strconv.Itoa(len(sessions)-1)
+		}
+		if _, locked := lockedSince(); locked || interrupted && intr.paused == s.pid {
+			line += " (paused)"
+		}
+		parts = append(parts, line)
+	}
+	fmt.Println(strings.Join(parts, " "))
+}
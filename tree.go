@@ -0,0 +1,316 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//a tree is a snapshot of a task, its subtasks and their logs, for long running commands
+//such as serve which would otherwise walk the filesystem for every request. Adding,
+//removing or renaming a log or task changes its directory's modification time, so
+//refresh only rereads the directories which have changed.
+//
+//It also keeps the time logged in each task and its subtasks on each day, updated by
+//the difference each reread directory makes, so that totals for a period cost the
+//number of days with logs rather than the number of logs.
+type tree struct {
+	root task
+	mu   sync.RWMutex
+	dirs map[string]treeDir
+	//days[path][day] is the time in logs starting that day in the task and its subtasks
+	days map[string]map[time.Time]time.Duration
+	//longest is the longest log seen, so that logs starting before a period which
+	//overlap it can be found without looking at every log
+	longest time.Duration
+}
+
+//a treeDir is the contents of one task's directory when it was last read, with its logs in order
+type treeDir struct {
+	modTime  time.Time
+	logs     logs
+	subtasks []task
+	days     map[time.Time]time.Duration
+	//logsModTime is that of the task's .timelogs directory, if it has one
+	logsModTime time.Time
+}
+
+func loadTree(t task) *tree {
+	tr := &tree{root: t, dirs: map[string]treeDir{}, days: map[string]map[time.Time]time.Duration{}}
+	tr.refresh()
+	return tr
+}
+
+//startOfDay is midnight at the start of the local day containing t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func readTreeDir(t task, modTime time.Time) treeDir {
+	d := treeDir{modTime: modTime}
+	files, err := ioutil.ReadDir(t.path())
+	if err != nil {
+		skip(t.path(), err)
+	}
+	for _, fi := range files {
+		path := t.path() + "/" + fi.Name()
+		if fi = t.followLink(path, fi); fi == nil || t.ignores(fi.Name(), fi.IsDir()) {
+			continue
+		}
+		if fi.IsDir() && fi.Name() == logsDirName {
+			d.logsModTime = fi.ModTime()
+			d.logs = append(d.logs, task(path).logsMatching(everyLog)...)
+			continue
+		}
+		if fi.IsDir() {
+			//hidden directories such as .horolog and .git are never tasks
+			if !strings.HasPrefix(fi.Name(), ".") && !task(path).excluded() {
+				d.subtasks = append(d.subtasks, task(path))
+			}
+			continue
+		}
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		if l, err := loadLog(path); err == nil {
+			d.logs = append(d.logs, l)
+		} else if looksLikeLog(fi.Name()) {
+			skip(path, err)
+		}
+	}
+	//names are not in order when logs were recorded in different time zones
+	sort.SliceStable(d.logs, func(i, j int) bool { return d.logs[i].start().Before(d.logs[j].start()) })
+	d.days = map[time.Time]time.Duration{}
+	for _, l := range d.logs {
+		d.days[startOfDay(l.start())] += l.duration()
+	}
+	return d
+}
+
+//logsChanged reports whether the task's .timelogs directory has changed since d was read
+func (d treeDir) logsChanged(t task) bool {
+	if d.logsModTime.IsZero() {
+		return false
+	}
+	fi, err := os.Stat(t.path() + "/" + logsDirName)
+	return err != nil || !fi.ModTime().Equal(d.logsModTime)
+}
+
+//addDays adds the time in a directory's logs (or subtracts it, with sign -1) to the totals
+//of its task and every task above it
+func (tr *tree) addDays(path string, days map[time.Time]time.Duration, sign time.Duration) {
+	for ; path != ""; path = parentPath(path, tr.root.path()) {
+		totals := tr.days[path]
+		if totals == nil {
+			totals = map[time.Time]time.Duration{}
+			tr.days[path] = totals
+		}
+		for day, d := range days {
+			if totals[day] += sign * d; totals[day] == 0 {
+				delete(totals, day)
+			}
+		}
+		if len(totals) == 0 {
+			delete(tr.days, path)
+		}
+	}
+}
+
+//refresh rereads the directories which have changed since the last refresh, and returns their tasks
+func (tr *tree) refresh() []task {
+	var changed []task
+	dirs := map[string]treeDir{}
+	tr.mu.RLock()
+	pending := []task{tr.root}
+	for len(pending) > 0 {
+		if ctx.Err() != nil {
+			tr.mu.RUnlock()
+			return nil
+		}
+		t := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		fi, err := os.Stat(t.path())
+		if err != nil {
+			continue
+		}
+		d, ok := tr.dirs[t.path()]
+		if !ok || !d.modTime.Equal(fi.ModTime()) || d.logsChanged(t) {
+			debug("rereading", t.path())
+			d = readTreeDir(t, fi.ModTime())
+			changed = append(changed, t)
+		}
+		dirs[t.path()] = d
+		pending = append(pending, d.subtasks...)
+	}
+	var removed []string
+	for path := range tr.dirs {
+		if _, ok := dirs[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	tr.mu.RUnlock()
+
+	if len(changed) > 0 || len(removed) > 0 {
+		tr.mu.Lock()
+		for _, path := range removed {
+			tr.addDays(path, tr.dirs[path].days, -1)
+		}
+		for _, t := range changed {
+			tr.addDays(t.path(), tr.dirs[t.path()].days, -1)
+			tr.addDays(t.path(), dirs[t.path()].days, 1)
+			for _, l := range dirs[t.path()].logs {
+				if l.duration() > tr.longest {
+					tr.longest = l.duration()
+				}
+			}
+		}
+		tr.dirs = dirs
+		tr.mu.Unlock()
+	}
+	return changed
+}
+
+//watch refreshes the tree every interval, calling changed with the tasks whose
+//directories changed, until stop is closed
+func (tr *tree) watch(interval time.Duration, changed func([]task), stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if ts := tr.refresh(); len(ts) > 0 && changed != nil {
+				changed(ts)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+//tasks returns the tasks in the tree, parents before their subtasks
+func (tr *tree) tasks() []task {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	var answer []task
+	pending := []task{tr.root}
+	for len(pending) > 0 {
+		t := pending[0]
+		pending = pending[1:]
+		answer = append(answer, t)
+		pending = append(pending, tr.dirs[t.path()].subtasks...)
+	}
+	return answer
+}
+
+//logsMatching returns the logs in the task (which must be in the tree) and its subtasks which match f
+func (tr *tree) logsMatching(t task, f filter) logs {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	var answer logs
+	pending := []task{t}
+	for len(pending) > 0 {
+		t := pending[0]
+		pending = pending[1:]
+		d := tr.dirs[t.path()]
+		for _, l := range d.logs {
+			if f(l) {
+				answer = append(answer, l)
+			}
+		}
+		pending = append(pending, d.subtasks...)
+	}
+	return answer
+}
+
+//duration is the time logged in the task and its subtasks which matches f
+func (tr *tree) duration(t task, f filter) time.Duration {
+	return tr.logsMatching(t, f).duration()
+}
+
+//totals is the time in each task of the tree and its subtasks in logs overlapping from..to,
+//as between counts them, keyed by path. Periods of whole days (such as those from
+//parsePeriod) use the running totals, and others read every log.
+func (tr *tree) totals(from, to time.Time) map[string]time.Duration {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	answer := map[string]time.Duration{}
+	aligned := func(t time.Time) bool { return t == never || startOfDay(t).Equal(t) }
+	if !aligned(from) || !aligned(to) {
+		f := between(from, to)
+		for path, d := range tr.dirs {
+			for _, l := range d.logs {
+				if f(l) {
+					for p := path; p != ""; p = parentPath(p, tr.root.path()) {
+						answer[p] += l.duration()
+					}
+				}
+			}
+		}
+		return answer
+	}
+
+	for path, days := range tr.days {
+		for day, d := range days {
+			if (from == never || !day.Before(from)) && (to == never || day.Before(to)) {
+				answer[path] += d
+			}
+		}
+	}
+	if from != never {
+		//logs which start before the period but end during it
+		for path, d := range tr.dirs {
+			i := sort.Search(len(d.logs), func(i int) bool { return !d.logs[i].start().Before(from) })
+			for i--; i >= 0 && d.logs[i].start().After(from.Add(-tr.longest-time.Second)); i-- {
+				if l := d.logs[i]; l.end().After(from) {
+					for p := path; p != ""; p = parentPath(p, tr.root.path()) {
+						answer[p] += l.duration()
+					}
+				}
+			}
+		}
+	}
+	for path, d := range answer {
+		if d == 0 {
+			delete(answer, path)
+		}
+	}
+	return answer
+}
+
+//summary is the total and the lines of summary giving the time logged in each task (not
+//counting its subtasks) from the running totals, for dashboards which refresh often.
+//Tasks are prefixed with name in place of the root when it is given, as with --roots.
+func (tr *tree) summary(from, to time.Time, name string) (time.Duration, string) {
+	totals := tr.totals(from, to)
+	own := map[string]time.Duration{}
+	for path, d := range totals {
+		own[path] += d
+		if parent := parentPath(path, tr.root.path()); parent != "" {
+			own[parent] -= d
+		}
+	}
+	body := ""
+	tr.mu.RLock()
+	pending := []task{tr.root}
+	for len(pending) > 0 {
+		//depth first, as summary lists tasks
+		t := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if d := own[t.path()]; d != 0 {
+			path := t.path()
+			if name != "" {
+				path = name + strings.TrimPrefix(path, tr.root.path())
+			}
+			body += path + " (" + d.String() + ")\n"
+		}
+		subtasks := tr.dirs[t.path()].subtasks
+		for i := len(subtasks) - 1; i >= 0; i-- {
+			pending = append(pending, subtasks[i])
+		}
+	}
+	tr.mu.RUnlock()
+	return totals[tr.root.path()], body
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+//typeMarker matches a marker such as [meeting], [focus] or [interrupt] at the start of a
+//note, which gives the type of the log
+var typeMarker = regexp.MustCompile(`^\[([\w-]+)\]`)
+
+//typeSymbols caches the type_markers setting of each directory
+var typeSymbols = map[string]map[string]string{}
+
+//taskTypeSymbols is the type_markers setting of a task, which maps symbols (usually emoji)
+//at the start of notes to types, e.g. "📅=meeting, 🎯=focus"
+func taskTypeSymbols(dir string) map[string]string {
+	if symbols, ok := typeSymbols[dir]; ok {
+		return symbols
+	}
+	symbols := map[string]string{}
+	for _, pair := range strings.Split(task(dir).setting("type_markers", ""), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		symbolType := strings.SplitN(pair, "=", 2)
+		if len(symbolType) != 2 || strings.TrimSpace(symbolType[0]) == "" {
+			panic(errors.New("Invalid type_markers: " + pair + " (use e.g. 📅=meeting)"))
+		}
+		symbols[strings.TrimSpace(symbolType[0])] = strings.TrimSpace(symbolType[1])
+	}
+	typeSymbols[dir] = symbols
+	return symbols
+}
+
+//noteType is the type of a note given by its marker, or "" if it has none
+func noteType(body, dir string) string {
+	body = strings.TrimSpace(body)
+	if m := typeMarker.FindStringSubmatch(body); m != nil {
+		return strings.ToLower(m[1])
+	}
+	for symbol, t := range taskTypeSymbols(dir) {
+		if strings.HasPrefix(body, symbol) {
+			return t
+		}
+	}
+	return ""
+}
+
+//entryType is the type of the log, from the type key of its front matter or else the
+//marker at the start of its note, or "" if it has none
+func (l log) entryType() string {
+	fm, body := parseFrontMatter(l.text())
+	if t := fm.get("type"); t != "" {
+		return strings.ToLower(t)
+	}
+	return noteType(body, l.dir())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+//billable matches logs in tasks with the billable setting, which is usually set
+//once for a client's task in its .horolog.conf and inherited by its subtasks, unless
+//a log's front matter says otherwise
+func billable() filter {
+	settings := map[string]bool{}
+	return func(l log) bool {
+		if b := l.frontMatter().get("billable"); b != "" {
+			return b == "true" || b == "yes"
+		}
+		b, ok := settings[l.dir()]
+		if !ok {
+			b = task(l.dir()).setting("billable", "false") == "true"
+			settings[l.dir()] = b
+		}
+		return b
+	}
+}
+
+//weekStart is the Monday at the start of the week containing t
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+func utilizationCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "this-month"))
+	if err != nil {
+		panic(err)
+	}
+
+	isBillable := billable()
+	totals := map[time.Time]time.Duration{}
+	billed := map[time.Time]time.Duration{}
+	for _, l := range t.recursiveLogsMatching(between(from, to)) {
+		week := weekStart(l.start())
+		totals[week] += l.duration()
+		if isBillable(l) {
+			billed[week] += l.duration()
+		}
+	}
+	var weeks []time.Time
+	for week := range totals {
+		weeks = append(weeks, week)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Before(weeks[j]) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Week\tBillable\tTotal\tUtilization")
+	var sumTotal, sumBilled time.Duration
+	for _, week := range weeks {
+		sumTotal += totals[week]
+		sumBilled += billed[week]
+		fmt.Fprintln(w, week.Format("2006-01-02")+"\t"+formatHoursMinutes(billed[week])+"\t"+formatHoursMinutes(totals[week])+"\t"+percent(billed[week], totals[week]))
+	}
+	fmt.Fprintln(w, "Total\t"+formatHoursMinutes(sumBilled)+"\t"+formatHoursMinutes(sumTotal)+"\t"+percent(sumBilled, sumTotal))
+	w.Flush()
+}
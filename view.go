@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//views are saved invocations of horolog, each set in its own section of the config, e.g.
+//	[view.weekly-client-report]
+//	command = "summary clients --period=last-week --group-by=group"
+//	description = "Time per client group last week"
+//and run with horolog view weekly-client-report
+type view struct {
+	name        string
+	args        []string
+	description string
+}
+
+func loadViews(c config) []view {
+	var answer []view
+	for key, value := range c {
+		if strings.HasPrefix(key, "view.") && strings.HasSuffix(key, ".command") && strings.Count(key, ".") == 2 {
+			name := strings.Split(key, ".")[1]
+			answer = append(answer, view{name, splitCommand(value), c.get("view."+name+".description", "")})
+		}
+	}
+	sort.Slice(answer, func(i, j int) bool { return answer[i].name < answer[j].name })
+	return answer
+}
+
+//expand returns the arguments to run the view with, given any more on the command line.
+//Their options come after the view's, so that they take precedence, and if they name a
+//task it replaces the view's.
+func (v view) expand(args []string) []string {
+	_, positional := parseOptions(args)
+	answer := []string{v.args[0]}
+	for _, arg := range v.args[1:] {
+		if len(positional) > 0 && !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		answer = append(answer, arg)
+	}
+	return append(answer, args...)
+}
+
+//viewArgs returns the arguments of the named view, followed by the rest of args
+func viewArgs(args []string) []string {
+	if len(args) == 0 {
+		panic(errors.New("Usage: horolog view <name> [task] [options]"))
+	}
+	for _, v := range loadViews(loadGlobalConfig()) {
+		if v.name == args[0] {
+			if len(v.args) == 0 {
+				panic(errors.New("No command for view " + v.name))
+			}
+			if v.args[0] == "view" {
+				panic(errors.New("Invalid command for view " + v.name + ": views cannot run other views"))
+			}
+			debug("view", v.name, "runs", strings.Join(v.args, " "))
+			return takeExcludeArgs(v.expand(args[1:]))
+		}
+	}
+	panic(errors.New("No Such View: " + args[0] + " (see horolog views)"))
+}
+
+//viewsCommand lists the saved views with what they run
+func viewsCommand(args []string) {
+	views := loadViews(loadGlobalConfig())
+	if len(views) == 0 {
+		inform("No views (add a [view.name] section with a command to the config)")
+		return
+	}
+	for _, v := range views {
+		fmt.Println(v.name + "\t" + strings.Join(v.args, " "))
+		if v.description != "" {
+			fmt.Println("\t" + v.description)
+		}
+	}
+}
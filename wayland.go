@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//Wayland compositors such as sway tell clients when the user goes idle with the
+//ext-idle-notify-v1 protocol, which watch speaks over the compositor's socket directly
+
+//waylandConn is a connection to the compositor, which numbers the objects it creates
+type waylandConn struct {
+	conn net.Conn
+	last uint32
+}
+
+//the objects and opcodes of the requests and events used, from wayland.xml and
+//ext-idle-notify-v1.xml
+const (
+	waylandDisplay          = 1
+	waylandDisplaySync      = 0
+	waylandDisplayRegistry  = 1
+	waylandDisplayError     = 0
+	waylandRegistryBind     = 0
+	waylandRegistryGlobal   = 0
+	waylandCallbackDone     = 0
+	waylandNotifierGet      = 1
+	waylandNotificationIdle = 0
+	waylandNotificationBack = 1
+)
+
+func waylandSocket() string {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" || filepath.IsAbs(display) {
+		return display
+	}
+	return filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), display)
+}
+
+func dialWayland() (*waylandConn, error) {
+	path := waylandSocket()
+	if path == "" {
+		return nil, errors.New("Not in a Wayland session")
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &waylandConn{conn: conn, last: waylandDisplay}, nil
+}
+
+func (w *waylandConn) newID() uint32 {
+	w.last++
+	return w.last
+}
+
+//send writes a request, whose arguments are uint32s (including object ids) or strings.
+//Wayland uses the byte order of the machine, which is little endian almost everywhere.
+func (w *waylandConn) send(object uint32, opcode uint16, args ...interface{}) error {
+	var body []byte
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			body = binary.LittleEndian.AppendUint32(body, v)
+		case string:
+			body = binary.LittleEndian.AppendUint32(body, uint32(len(v)+1))
+			body = append(body, v...)
+			body = append(body, make([]byte, 4-len(v)%4)...)
+		}
+	}
+	header := binary.LittleEndian.AppendUint32(nil, object)
+	header = binary.LittleEndian.AppendUint32(header, uint32(8+len(body))<<16|uint32(opcode))
+	_, err := w.conn.Write(append(header, body...))
+	return err
+}
+
+//receive reads the next event
+func (w *waylandConn) receive() (object uint32, opcode uint16, body []byte, err error) {
+	header := make([]byte, 8)
+	if _, err = io.ReadFull(w.conn, header); err != nil {
+		return
+	}
+	object = binary.LittleEndian.Uint32(header)
+	sizeOpcode := binary.LittleEndian.Uint32(header[4:])
+	opcode = uint16(sizeOpcode)
+	if size := sizeOpcode >> 16; size > 8 {
+		body = make([]byte, size-8)
+		_, err = io.ReadFull(w.conn, body)
+	}
+	return
+}
+
+//waylandString reads a string argument from the start of body, returning the rest
+func waylandString(body []byte) (string, []byte) {
+	if len(body) < 4 {
+		return "", nil
+	}
+	n := int(binary.LittleEndian.Uint32(body))
+	padded := (n + 3) / 4 * 4
+	if n == 0 || len(body) < 4+padded {
+		return "", nil
+	}
+	return string(body[4 : 4+n-1]), body[4+padded:]
+}
+
+//a waylandGlobal is an interface the compositor offers
+type waylandGlobal struct {
+	name    uint32
+	version uint32
+}
+
+//globals lists the interfaces the compositor offers, returning the registry
+func (w *waylandConn) globals() (uint32, map[string]waylandGlobal, error) {
+	registry, callback := w.newID(), w.newID()
+	if err := w.send(waylandDisplay, waylandDisplayRegistry, registry); err != nil {
+		return 0, nil, err
+	}
+	//the callback is done once every global has been announced
+	if err := w.send(waylandDisplay, waylandDisplaySync, callback); err != nil {
+		return 0, nil, err
+	}
+	answer := map[string]waylandGlobal{}
+	for {
+		object, opcode, body, err := w.receive()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch {
+		case object == callback && opcode == waylandCallbackDone:
+			return registry, answer, nil
+		case object == waylandDisplay && opcode == waylandDisplayError:
+			return 0, nil, waylandError(body)
+		case object == registry && opcode == waylandRegistryGlobal && len(body) >= 4:
+			name := binary.LittleEndian.Uint32(body)
+			iface, rest := waylandString(body[4:])
+			if len(rest) >= 4 {
+				if _, ok := answer[iface]; !ok {
+					answer[iface] = waylandGlobal{name, binary.LittleEndian.Uint32(rest)}
+				}
+			}
+		}
+	}
+}
+
+func waylandError(body []byte) error {
+	message := ""
+	if len(body) >= 8 {
+		message, _ = waylandString(body[8:])
+	}
+	return errors.New("Wayland Error: " + message)
+}
+
+//bind creates an object for a global
+func (w *waylandConn) bind(registry uint32, iface string, g waylandGlobal) (uint32, error) {
+	id := w.newID()
+	return id, w.send(registry, waylandRegistryBind, g.name, iface, uint32(1), id)
+}
+
+//waylandDetector notices when there has been no input for the idle timeout, using
+//ext-idle-notify-v1, which compositors such as sway and KDE support
+type waylandDetector struct{}
+
+func (waylandDetector) available() bool {
+	w, err := dialWayland()
+	if err != nil {
+		return false
+	}
+	defer w.conn.Close()
+	_, globals, err := w.globals()
+	_, ok := globals["ext_idle_notifier_v1"]
+	return err == nil && ok
+}
+
+func (waylandDetector) watch(ctx context.Context, opts idleOptions, changed func(bool, time.Time)) error {
+	w, err := dialWayland()
+	if err != nil {
+		return err
+	}
+	defer w.conn.Close()
+	registry, globals, err := w.globals()
+	if err != nil {
+		return err
+	}
+	notifierGlobal, ok := globals["ext_idle_notifier_v1"]
+	seatGlobal, hasSeat := globals["wl_seat"]
+	if !ok || !hasSeat {
+		return errors.New("The compositor does not support ext-idle-notify-v1")
+	}
+	notifier, err := w.bind(registry, "ext_idle_notifier_v1", notifierGlobal)
+	if err != nil {
+		return err
+	}
+	seat, err := w.bind(registry, "wl_seat", seatGlobal)
+	if err != nil {
+		return err
+	}
+	notification := w.newID()
+	if err := w.send(notifier, waylandNotifierGet, notification, uint32(opts.timeout/time.Millisecond), seat); err != nil {
+		return err
+	}
+
+	//closing the connection ends the wait for the next event
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.conn.Close()
+		case <-done:
+		}
+	}()
+	for {
+		object, opcode, body, err := w.receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case object == waylandDisplay && opcode == waylandDisplayError:
+			return waylandError(body)
+		case object == notification && opcode == waylandNotificationIdle:
+			//the user was last active a timeout ago
+			changed(true, time.Now().Add(-opts.timeout))
+		case object == notification && opcode == waylandNotificationBack:
+			changed(false, time.Now())
+		}
+	}
+}
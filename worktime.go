@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//a schedule is the time expected to be worked on each day, from the daily_hours (or
+//weekly_hours) and workdays settings, less the days off listed in the calendar file,
+//the holidays in the holidays .ics files and the absences recorded in the tree
+type schedule struct {
+	hours   map[time.Weekday]time.Duration
+	daysOff map[string]string
+}
+
+//the calendar lists days off, one per line, as a date or a range of dates followed
+//by a description, e.g.
+//	2024-12-25 Christmas Day
+//	2024-08-01..2024-08-14 Vacation
+func loadCalendar(path string, daysOff map[string]string) error {
+	path = expandHome(path)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		description := ""
+		if len(fields) == 2 {
+			description = strings.TrimSpace(fields[1])
+		}
+		fromTo := strings.SplitN(fields[0], "..", 2)
+		from, err := time.ParseInLocation("2006-01-02", fromTo[0], time.Local)
+		if err != nil {
+			return errors.New("Invalid Calendar Entry in " + path + ": " + line)
+		}
+		to := from
+		if len(fromTo) == 2 {
+			to, err = time.ParseInLocation("2006-01-02", fromTo[1], time.Local)
+			if err != nil || to.Before(from) {
+				return errors.New("Invalid Calendar Entry in " + path + ": " + line)
+			}
+		}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			daysOff[day.Format("2006-01-02")] = description
+		}
+	}
+	return scanner.Err()
+}
+
+func (t task) schedule() (schedule, error) {
+	s := schedule{hours: map[time.Weekday]time.Duration{}, daysOff: map[string]string{}}
+	var workdays []time.Weekday
+	for _, name := range strings.Split(t.setting("workdays", "mon,tue,wed,thu,fri"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		wd, err := parseWeekday(name)
+		if err != nil {
+			return s, err
+		}
+		workdays = append(workdays, wd)
+	}
+	daily, err := parseDuration(t.setting("daily_hours", "8h"))
+	if err != nil {
+		return s, errors.New("Invalid daily_hours: " + err.Error())
+	}
+	if weekly := t.setting("weekly_hours", ""); weekly != "" && len(workdays) > 0 {
+		dur, err := parseDuration(weekly)
+		if err != nil {
+			return s, errors.New("Invalid weekly_hours: " + err.Error())
+		}
+		daily = dur / time.Duration(len(workdays))
+	}
+	for _, wd := range workdays {
+		s.hours[wd] = daily
+	}
+	err = loadCalendar(t.setting("calendar", filepath.Join(configDir(), "calendar")), s.daysOff)
+	if err != nil {
+		return s, err
+	}
+	for _, path := range strings.Split(t.setting("holidays", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			if err := loadICS(path, s.daysOff); err != nil {
+				return s, err
+			}
+		}
+	}
+	return s, loadCalendar(absencesPath(t.path()), s.daysOff)
+}
+
+//expected is the time which should be worked on the given day
+func (s schedule) expected(day time.Time) time.Duration {
+	if _, ok := s.daysOff[day.Format("2006-01-02")]; ok {
+		return 0
+	}
+	return s.hours[day.Weekday()]
+}
+
+//overtimeCommand compares the time logged each week with the schedule, up to the end of today
+func overtimeCommand(args []string) {
+	opts, positional := parseOptions(args)
+	dir := "."
+	if len(positional) > 0 {
+		dir = positional[0]
+	}
+	t, err := loadTask(dir)
+	if err != nil {
+		panic(err)
+	}
+	from, to, err := parsePeriod(opts.get("period", "this-month"))
+	if err != nil {
+		panic(err)
+	}
+	if from == never {
+		panic(errors.New("Cannot calculate overtime for a period without a start"))
+	}
+	now := time.Now()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local)
+	if to == never || to.After(tomorrow) {
+		to = tomorrow
+	}
+	s, err := t.schedule()
+	if err != nil {
+		panic(err)
+	}
+
+	expected := map[time.Time]time.Duration{}
+	worked := map[time.Time]time.Duration{}
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	for day := start; day.Before(to); day = day.AddDate(0, 0, 1) {
+		expected[weekStart(day)] += s.expected(day)
+	}
+	for _, l := range t.recursiveLogsMatching(between(from, to)) {
+		worked[weekStart(l.start())] += l.duration()
+	}
+	var weeks []time.Time
+	for week := range expected {
+		weeks = append(weeks, week)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Before(weeks[j]) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Week\tExpected\tWorked\tBalance")
+	var sumExpected, sumWorked time.Duration
+	for _, week := range weeks {
+		sumExpected += expected[week]
+		sumWorked += worked[week]
+		fmt.Fprintln(w, week.Format("2006-01-02")+"\t"+formatHoursMinutes(expected[week])+"\t"+formatHoursMinutes(worked[week])+"\t"+formatBalance(worked[week]-expected[week]))
+	}
+	fmt.Fprintln(w, "Total\t"+formatHoursMinutes(sumExpected)+"\t"+formatHoursMinutes(sumWorked)+"\t"+formatBalance(sumWorked-sumExpected))
+	w.Flush()
+}
+
+//formatBalance formats a surplus or deficit as h:mm with an explicit sign
+func formatBalance(d time.Duration) string {
+	if d >= 0 {
+		return "+" + formatHoursMinutes(d)
+	}
+	return formatHoursMinutes(d)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+//Windows tells windows registered with WTSRegisterSessionNotification when the session is
+//locked and unlocked, so watch creates a hidden window to receive the notifications
+
+var (
+	user32                           = syscall.NewLazyDLL("user32.dll")
+	wtsapi32                         = syscall.NewLazyDLL("wtsapi32.dll")
+	procRegisterClassEx              = user32.NewProc("RegisterClassExW")
+	procCreateWindowEx               = user32.NewProc("CreateWindowExW")
+	procDestroyWindow                = user32.NewProc("DestroyWindow")
+	procDefWindowProc                = user32.NewProc("DefWindowProcW")
+	procGetMessage                   = user32.NewProc("GetMessageW")
+	procDispatchMessage              = user32.NewProc("DispatchMessageW")
+	procPostMessage                  = user32.NewProc("PostMessageW")
+	procPostQuitMessage              = user32.NewProc("PostQuitMessage")
+	procWTSRegisterSession           = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSession         = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+	procGetModuleHandle              = kernel32.NewProc("GetModuleHandleW")
+	wtsWindowClass                   = syscall.StringToUTF16Ptr("horologWTS")
+	wtsMessageOnly           uintptr = ^uintptr(2) //HWND_MESSAGE, -3
+)
+
+const (
+	wmDestroy            = 0x0002
+	wmClose              = 0x0010
+	wmWTSSessionChange   = 0x02B1
+	wtsSessionLock       = 0x7
+	wtsSessionUnlock     = 0x8
+	notifyForThisSession = 0
+)
+
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   uintptr
+	icon       uintptr
+	cursor     uintptr
+	background uintptr
+	menuName   *uint16
+	className  *uint16
+	iconSm     uintptr
+}
+
+type windowMessage struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	x, y    int32
+	private uint32
+}
+
+//wtsDetector notices when the Windows session is locked and unlocked
+type wtsDetector struct{}
+
+func (wtsDetector) available() bool {
+	return procWTSRegisterSession.Find() == nil
+}
+
+func (wtsDetector) watch(ctx context.Context, opts idleOptions, changed func(bool, time.Time)) error {
+	//a window's messages are received on the thread which created it
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	wndProc := syscall.NewCallback(func(hwnd, msg, wParam, lParam uintptr) uintptr {
+		switch msg {
+		case wmWTSSessionChange:
+			switch wParam {
+			case wtsSessionLock:
+				changed(true, time.Now())
+			case wtsSessionUnlock:
+				changed(false, time.Now())
+			}
+			return 0
+		case wmDestroy:
+			procPostQuitMessage.Call(0)
+			return 0
+		}
+		r, _, _ := procDefWindowProc.Call(hwnd, msg, wParam, lParam)
+		return r
+	})
+	instance, _, _ := procGetModuleHandle.Call(0)
+	class := wndClassEx{wndProc: wndProc, instance: instance, className: wtsWindowClass}
+	class.size = uint32(unsafe.Sizeof(class))
+	if r, _, err := procRegisterClassEx.Call(uintptr(unsafe.Pointer(&class))); r == 0 {
+		return errors.New("Cannot register window class: " + err.Error())
+	}
+	hwnd, _, err := procCreateWindowEx.Call(0, uintptr(unsafe.Pointer(wtsWindowClass)), 0, 0, 0, 0, 0, 0, wtsMessageOnly, 0, instance, 0)
+	if hwnd == 0 {
+		return errors.New("Cannot create window: " + err.Error())
+	}
+	if r, _, err := procWTSRegisterSession.Call(hwnd, notifyForThisSession); r == 0 {
+		procDestroyWindow.Call(hwnd)
+		return errors.New("Cannot register for session notifications: " + err.Error())
+	}
+	defer procWTSUnRegisterSession.Call(hwnd)
+
+	//closing the window ends the message loop
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			procPostMessage.Call(hwnd, wmClose, 0, 0)
+		case <-done:
+		}
+	}()
+	var msg windowMessage
+	for {
+		r, _, err := procGetMessage.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		switch int32(r) {
+		case 0:
+			return nil
+		case -1:
+			return errors.New("Cannot receive session notifications: " + err.Error())
+		}
+		procDispatchMessage.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}